@@ -0,0 +1,322 @@
+package claude
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// BUDGET_LIMIT_TOKENS_PER_CONVERSATION names the per conversation token limit in ErrBudgetExceeded.
+const BUDGET_LIMIT_TOKENS_PER_CONVERSATION = "max_tokens_per_conversation"
+
+// BUDGET_LIMIT_DOLLARS_PER_DAY names the per user daily dollar limit in ErrBudgetExceeded.
+const BUDGET_LIMIT_DOLLARS_PER_DAY = "max_dollars_per_day_per_user"
+
+// BUDGET_LIMIT_REQUESTS_PER_MINUTE names the request rate limit in ErrBudgetExceeded.
+const BUDGET_LIMIT_REQUESTS_PER_MINUTE = "max_requests_per_minute"
+
+// Budget is a set of hard limits. A zero limit is disabled.
+type Budget struct {
+	// MaxTokensPerConversation refuses requests that could take a conversation past this
+	// many tokens.
+	MaxTokensPerConversation int `json:"max_tokens_per_conversation"`
+
+	// MaxDollarsPerDayPerUser refuses requests that could take a user id past spending this
+	// much today (UTC).
+	MaxDollarsPerDayPerUser float64 `json:"max_dollars_per_day_per_user"`
+
+	// MaxRequestsPerMinute refuses requests beyond this many in any 60 second window.
+	MaxRequestsPerMinute int `json:"max_requests_per_minute"`
+
+	// StateFile is where the daily spend and request times are kept so limits hold across
+	// restarts and are shared by every process using the file. If empty the state is kept
+	// in memory only.
+	StateFile string `json:"state_file,omitempty"`
+}
+
+// budgetState is the persisted state of a BudgetTracker.
+type budgetState struct {
+	// Day is the UTC day DailySpend applies to, as YYYY-MM-DD.
+	Day string `json:"day"`
+
+	// DailySpend is the dollars spent today per user id.
+	DailySpend map[string]float64 `json:"daily_spend"`
+
+	// Requests are the start times of the requests in the last minute.
+	Requests []time.Time `json:"requests"`
+}
+
+// BudgetTracker enforces a Budget.
+type BudgetTracker struct {
+	mu     sync.Mutex
+	budget Budget
+	state  *budgetState
+	now    func() time.Time
+
+	// lockFile holds the lock on the state file while the tracker is locked.
+	lockFile *os.File
+}
+
+// NewBudgetTracker creates a tracker for budget, loading its state file if it exists.
+func NewBudgetTracker(budget *Budget) (*BudgetTracker, error) {
+	tracker := &BudgetTracker{
+		budget: *budget,
+		state:  &budgetState{DailySpend: map[string]float64{}},
+		now:    time.Now,
+	}
+
+	if budget.StateFile == "" {
+		return tracker, nil
+	}
+
+	fqpn, err := filepath.Abs(budget.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	tracker.budget.StateFile = fqpn
+
+	// load the state file
+	if err := tracker.lock(); err != nil {
+		return nil, err
+	}
+	tracker.unlock()
+
+	return tracker, nil
+}
+
+// WithBudget enforces budget on every request made through this client.
+func WithBudget(budget *Budget) Option {
+	return func(config *Claude) {
+		config.budgetConfig = budget
+	}
+}
+
+// BudgetRequest describes a request about to be sent, for BudgetTracker.Check.
+type BudgetRequest struct {
+	// UserId is the metadata.user_id of the request.
+	UserId string
+
+	// Model is the model of the request, used to estimate its cost.
+	Model string
+
+	// SpentTokens is the number of tokens the conversation has used so far.
+	SpentTokens int
+
+	// MaxTokens is the max_tokens of the request, the most it can generate.
+	MaxTokens int
+
+	// InputTokens is the expected input of the request, e.g. from Messages.CountTokens or
+	// the usage of the last reply. Zero if unknown.
+	InputTokens int
+}
+
+// Check refuses a request, before it is sent, if it would exceed the budget. The request
+// is assumed to use InputTokens and generate its full MaxTokens, priced at the model's
+// input and output prices. An allowed request counts towards the request rate.
+func (t *BudgetTracker) Check(request *BudgetRequest) error {
+	return CheckBudgets(request, t)
+}
+
+// CheckBudgets checks a request against every tracker, and counts it towards their request
+// rates only if all of them allow it. Nil trackers are skipped. The trackers are locked in
+// the order given.
+func CheckBudgets(request *BudgetRequest, trackers ...*BudgetTracker) error {
+	locked := []*BudgetTracker{}
+	defer func() {
+		for i := len(locked) - 1; i >= 0; i-- {
+			locked[i].unlock()
+		}
+	}()
+	for _, t := range trackers {
+		if t == nil || slices.Contains(locked, t) {
+			continue
+		}
+		if err := t.lock(); err != nil {
+			return err
+		}
+		locked = append(locked, t)
+	}
+
+	for _, t := range locked {
+		if err := t.check(request); err != nil {
+			return err
+		}
+	}
+	for _, t := range locked {
+		if t.budget.MaxRequestsPerMinute > 0 {
+			t.state.Requests = append(t.state.Requests, t.now())
+			if err := t.save(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// check refuses a request that would exceed the budget, without counting it. The caller
+// must hold the tracker lock.
+func (t *BudgetTracker) check(request *BudgetRequest) error {
+	if limit := t.budget.MaxTokensPerConversation; limit > 0 {
+		if tokens := request.SpentTokens + request.InputTokens + request.MaxTokens; tokens > limit {
+			return &ErrBudgetExceeded{Limit: BUDGET_LIMIT_TOKENS_PER_CONVERSATION, Max: float64(limit), Current: float64(tokens)}
+		}
+	}
+
+	if limit := t.budget.MaxDollarsPerDayPerUser; limit > 0 {
+		estimate := Cost(&UsageRecord{Model: request.Model, InputTokens: request.InputTokens, OutputTokens: request.MaxTokens})
+		if spend := t.state.DailySpend[request.UserId] + estimate; spend > limit {
+			return &ErrBudgetExceeded{Limit: BUDGET_LIMIT_DOLLARS_PER_DAY, UserId: request.UserId, Max: limit, Current: spend}
+		}
+	}
+
+	if limit := t.budget.MaxRequestsPerMinute; limit > 0 {
+		if len(t.state.Requests) >= limit {
+			return &ErrBudgetExceeded{Limit: BUDGET_LIMIT_REQUESTS_PER_MINUTE, Max: float64(limit), Current: float64(len(t.state.Requests))}
+		}
+	}
+
+	return nil
+}
+
+// Record adds the cost of a completed request to the daily spend of its user id.
+func (t *BudgetTracker) Record(record *UsageRecord) error {
+	if err := t.lock(); err != nil {
+		return err
+	}
+	defer t.unlock()
+
+	t.state.DailySpend[record.UserId] += record.Cost
+
+	return t.save()
+}
+
+// GetDailySpend returns the dollars spent today by a user id. If the state file cannot be
+// read, the last known spend is returned.
+func (t *BudgetTracker) GetDailySpend(userId string) float64 {
+	if err := t.lock(); err != nil {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		return t.state.DailySpend[userId]
+	}
+	defer t.unlock()
+
+	return t.state.DailySpend[userId]
+}
+
+// lock locks the tracker and its state file, reloads the state other processes may have
+// changed and rolls it forward to now. Nothing is left locked on error.
+func (t *BudgetTracker) lock() error {
+	t.mu.Lock()
+
+	if t.budget.StateFile != "" {
+		f, err := os.OpenFile(t.budget.StateFile+".lock", os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			t.mu.Unlock()
+			return &ErrBudgetState{Err: err}
+		}
+		if err := lockFile(f); err != nil {
+			f.Close()
+			t.mu.Unlock()
+			return &ErrBudgetState{Err: err}
+		}
+		t.lockFile = f
+
+		if err := t.load(); err != nil {
+			t.unlock()
+			return err
+		}
+	}
+
+	t.roll(t.now())
+	return nil
+}
+
+// unlock releases the locks taken by lock.
+func (t *BudgetTracker) unlock() {
+	if t.lockFile != nil {
+		unlockFile(t.lockFile)
+		t.lockFile.Close()
+		t.lockFile = nil
+	}
+	t.mu.Unlock()
+}
+
+// load reads the state file, if it exists. The caller must hold the tracker lock.
+func (t *BudgetTracker) load() error {
+	data, err := os.ReadFile(t.budget.StateFile)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return &ErrBudgetState{Err: err}
+	}
+
+	state := &budgetState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return &ErrBudgetState{Err: err}
+	}
+	if state.DailySpend == nil {
+		state.DailySpend = map[string]float64{}
+	}
+	t.state = state
+	return nil
+}
+
+// roll resets the daily spend on a new day and drops request times older than a minute.
+// The caller must hold the tracker lock.
+func (t *BudgetTracker) roll(now time.Time) {
+	day := now.UTC().Format(time.DateOnly)
+	if t.state.Day != day {
+		t.state.Day = day
+		t.state.DailySpend = map[string]float64{}
+	}
+
+	cutoff := now.Add(-time.Minute)
+	requests := t.state.Requests[:0]
+	for _, request := range t.state.Requests {
+		if request.After(cutoff) {
+			requests = append(requests, request)
+		}
+	}
+	t.state.Requests = requests
+}
+
+// save writes the state file, replacing it atomically. The caller must hold the tracker
+// lock.
+func (t *BudgetTracker) save() error {
+	if t.budget.StateFile == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(t.state)
+	if err != nil {
+		return &ErrBudgetState{Err: err}
+	}
+
+	tmp := t.budget.StateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return &ErrBudgetState{Err: err}
+	}
+	if err := os.Rename(tmp, t.budget.StateFile); err != nil {
+		return &ErrBudgetState{Err: err}
+	}
+	return nil
+}
+
+// CheckBudget checks the client budget, if one is set. See BudgetTracker.Check.
+func (c *Claude) CheckBudget(request *BudgetRequest) error {
+	if c.budget == nil {
+		return nil
+	}
+	return c.budget.Check(request)
+}
+
+// GetBudget returns the client budget tracker, or nil if no budget is set.
+func (c *Claude) GetBudget() *BudgetTracker {
+	return c.budget
+}
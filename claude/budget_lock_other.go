@@ -0,0 +1,16 @@
+//go:build !unix
+
+package claude
+
+import "os"
+
+// lockFile is a no-op where flock is unavailable; the state file is then only safe to
+// share between trackers of one process.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile is a no-op where flock is unavailable.
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,224 @@
+package claude
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const TEST_MODEL = "claude-3-haiku-20240307"
+
+// clock is a settable time source for BudgetTracker.now.
+type clock struct{ time time.Time }
+
+func (c *clock) now() time.Time { return c.time }
+
+func newTracker(t *testing.T, budget *Budget, c *clock) *BudgetTracker {
+	t.Helper()
+	tracker, err := NewBudgetTracker(budget)
+	if err != nil {
+		t.Fatalf("NewBudgetTracker: %v", err)
+	}
+	tracker.now = c.now
+	return tracker
+}
+
+// exceeded returns the limit named by an ErrBudgetExceeded, or "" for nil.
+func exceeded(t *testing.T, err error) string {
+	t.Helper()
+	if err == nil {
+		return ""
+	}
+	var budgetErr *ErrBudgetExceeded
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("got %v, want ErrBudgetExceeded", err)
+	}
+	return budgetErr.Limit
+}
+
+func TestBudgetCheck(t *testing.T) {
+	// the most a request of 1000 output tokens can cost
+	estimate := Cost(&UsageRecord{Model: TEST_MODEL, OutputTokens: 1000})
+	if estimate == 0 {
+		t.Fatalf("no pricing for %s", TEST_MODEL)
+	}
+
+	tests := []struct {
+		name    string
+		budget  Budget
+		spend   float64
+		request BudgetRequest
+		want    string
+	}{
+		{name: "no limits", request: BudgetRequest{SpentTokens: 1_000_000, MaxTokens: 1_000_000}},
+		{name: "tokens within limit", budget: Budget{MaxTokensPerConversation: 2000},
+			request: BudgetRequest{SpentTokens: 1000, MaxTokens: 1000}},
+		{name: "spent tokens alone within limit but max tokens exceed it", budget: Budget{MaxTokensPerConversation: 2000},
+			request: BudgetRequest{SpentTokens: 1500, MaxTokens: 1000}, want: BUDGET_LIMIT_TOKENS_PER_CONVERSATION},
+		{name: "estimate within daily limit", budget: Budget{MaxDollarsPerDayPerUser: estimate * 2},
+			spend: estimate, request: BudgetRequest{UserId: "alice", Model: TEST_MODEL, MaxTokens: 1000}},
+		{name: "spend alone within daily limit but estimate exceeds it", budget: Budget{MaxDollarsPerDayPerUser: estimate * 2},
+			spend: estimate * 1.5, request: BudgetRequest{UserId: "alice", Model: TEST_MODEL, MaxTokens: 1000}, want: BUDGET_LIMIT_DOLLARS_PER_DAY},
+		{name: "spend of another user is ignored", budget: Budget{MaxDollarsPerDayPerUser: estimate * 2},
+			spend: estimate * 1.5, request: BudgetRequest{UserId: "bob", Model: TEST_MODEL, MaxTokens: 1000}},
+		{name: "input tokens count towards the conversation", budget: Budget{MaxTokensPerConversation: 2000},
+			request: BudgetRequest{SpentTokens: 500, InputTokens: 600, MaxTokens: 1000}, want: BUDGET_LIMIT_TOKENS_PER_CONVERSATION},
+		{name: "input tokens count towards the estimate", budget: Budget{MaxDollarsPerDayPerUser: estimate * 2},
+			spend: estimate, request: BudgetRequest{UserId: "alice", Model: TEST_MODEL, InputTokens: 1000, MaxTokens: 1000}, want: BUDGET_LIMIT_DOLLARS_PER_DAY},
+		{name: "unpriced model estimates nothing", budget: Budget{MaxDollarsPerDayPerUser: estimate},
+			spend: estimate, request: BudgetRequest{UserId: "alice", Model: "unpriced", MaxTokens: 1000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newTracker(t, &tt.budget, &clock{time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)})
+			if tt.spend > 0 {
+				if err := tracker.Record(&UsageRecord{UserId: "alice", Cost: tt.spend}); err != nil {
+					t.Fatalf("Record: %v", err)
+				}
+			}
+			if got := exceeded(t, tracker.Check(&tt.request)); got != tt.want {
+				t.Errorf("Check exceeded %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBudgetRequestsPerMinute(t *testing.T) {
+	c := &clock{time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	tracker := newTracker(t, &Budget{MaxRequestsPerMinute: 2}, c)
+
+	steps := []struct {
+		advance time.Duration
+		want    string
+	}{
+		{want: ""},
+		{advance: 10 * time.Second, want: ""},
+		{advance: 10 * time.Second, want: BUDGET_LIMIT_REQUESTS_PER_MINUTE},
+		// the first request leaves the window; refused requests were not counted
+		{advance: 41 * time.Second, want: ""},
+		{want: BUDGET_LIMIT_REQUESTS_PER_MINUTE},
+	}
+
+	for i, step := range steps {
+		c.time = c.time.Add(step.advance)
+		if got := exceeded(t, tracker.Check(&BudgetRequest{})); got != step.want {
+			t.Errorf("request %d exceeded %q, want %q", i, got, step.want)
+		}
+	}
+}
+
+func TestCheckBudgets(t *testing.T) {
+	c := &clock{time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	rate := newTracker(t, &Budget{MaxRequestsPerMinute: 1}, c)
+	tokens := newTracker(t, &Budget{MaxTokensPerConversation: 100}, c)
+
+	// a request refused by the second tracker is not charged to the first
+	if got := exceeded(t, CheckBudgets(&BudgetRequest{MaxTokens: 1000}, rate, tokens, nil)); got != BUDGET_LIMIT_TOKENS_PER_CONVERSATION {
+		t.Errorf("CheckBudgets exceeded %q, want %q", got, BUDGET_LIMIT_TOKENS_PER_CONVERSATION)
+	}
+	if err := CheckBudgets(&BudgetRequest{MaxTokens: 10}, rate, tokens, rate); err != nil {
+		t.Errorf("CheckBudgets after a refused request: %v", err)
+	}
+	if got := exceeded(t, CheckBudgets(&BudgetRequest{MaxTokens: 10}, rate, tokens)); got != BUDGET_LIMIT_REQUESTS_PER_MINUTE {
+		t.Errorf("CheckBudgets exceeded %q, want %q", got, BUDGET_LIMIT_REQUESTS_PER_MINUTE)
+	}
+}
+
+func TestBudgetDailySpendResets(t *testing.T) {
+	c := &clock{time: time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)}
+	tracker := newTracker(t, &Budget{MaxDollarsPerDayPerUser: 1}, c)
+
+	if err := tracker.Record(&UsageRecord{UserId: "alice", Cost: 1}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if got := exceeded(t, tracker.Check(&BudgetRequest{UserId: "alice", Model: TEST_MODEL, MaxTokens: 1})); got != BUDGET_LIMIT_DOLLARS_PER_DAY {
+		t.Errorf("Check exceeded %q, want %q", got, BUDGET_LIMIT_DOLLARS_PER_DAY)
+	}
+
+	// the day is UTC, so a local time zone does not matter
+	c.time = time.Date(2024, 5, 2, 0, 1, 0, 0, time.UTC).In(time.FixedZone("behind", -5*60*60))
+	if spend := tracker.GetDailySpend("alice"); spend != 0 {
+		t.Errorf("GetDailySpend on a new day = %g, want 0", spend)
+	}
+	if err := tracker.Check(&BudgetRequest{UserId: "alice", Model: TEST_MODEL, MaxTokens: 1}); err != nil {
+		t.Errorf("Check on a new day: %v", err)
+	}
+}
+
+func TestBudgetStateFile(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "budget.json")
+	budget := &Budget{MaxDollarsPerDayPerUser: 1, MaxRequestsPerMinute: 1, StateFile: stateFile}
+	c := &clock{time: time.Now()}
+
+	tracker := newTracker(t, budget, c)
+	if err := tracker.Check(&BudgetRequest{UserId: "alice"}); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if err := tracker.Record(&UsageRecord{UserId: "alice", Cost: 0.25}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	// a new tracker, as after a restart, keeps the spend and the request rate
+	restarted := newTracker(t, budget, c)
+	if spend := restarted.GetDailySpend("alice"); spend != 0.25 {
+		t.Errorf("GetDailySpend after restart = %g, want 0.25", spend)
+	}
+	if got := exceeded(t, restarted.Check(&BudgetRequest{UserId: "alice"})); got != BUDGET_LIMIT_REQUESTS_PER_MINUTE {
+		t.Errorf("Check after restart exceeded %q, want %q", got, BUDGET_LIMIT_REQUESTS_PER_MINUTE)
+	}
+
+	// trackers sharing the state file, as in separate processes, see each other's changes
+	other := newTracker(t, budget, c)
+	if err := other.Record(&UsageRecord{UserId: "alice", Cost: 0.5}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if spend := restarted.GetDailySpend("alice"); spend != 0.75 {
+		t.Errorf("GetDailySpend after another tracker recorded = %g, want 0.75", spend)
+	}
+
+	if err := os.WriteFile(stateFile, []byte("not json"), 0644); err != nil {
+		t.Fatalf("writing state file: %v", err)
+	}
+	var stateErr *ErrBudgetState
+	if _, err := NewBudgetTracker(budget); !errors.As(err, &stateErr) {
+		t.Errorf("NewBudgetTracker with a corrupt state file = %v, want ErrBudgetState", err)
+	}
+}
+
+func TestErrBudgetExceededError(t *testing.T) {
+	err := &ErrBudgetExceeded{Limit: "daily spend", UserId: "u1", Max: 1, Current: 2}
+	want := "budget exceeded: daily spend for user u1 (2 of 1)"
+	for i := 0; i < 2; i++ {
+		if got := err.Error(); got != want {
+			t.Errorf("Error call %d = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestBudgetStateFileLock(t *testing.T) {
+	budget := &Budget{MaxRequestsPerMinute: 5, StateFile: filepath.Join(t.TempDir(), "budget.json")}
+	trackers := []*BudgetTracker{newTracker(t, budget, &clock{time: time.Now()}), newTracker(t, budget, &clock{time: time.Now()})}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(tracker *BudgetTracker) {
+			defer wg.Done()
+			if err := tracker.Check(&BudgetRequest{}); err == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}(trackers[i%2])
+	}
+	wg.Wait()
+	if allowed != budget.MaxRequestsPerMinute {
+		t.Errorf("%d requests allowed by trackers sharing a state file, want %d", allowed, budget.MaxRequestsPerMinute)
+	}
+}
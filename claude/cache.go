@@ -0,0 +1,248 @@
+package claude
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CACHE_HIT reports a response served from the cache.
+const CACHE_HIT = "hit"
+
+// CACHE_MISS reports a cacheable response fetched from the API and stored.
+const CACHE_MISS = "miss"
+
+// CACHE_BYPASS reports a request that is not cacheable, e.g. one with a non-zero temperature.
+const CACHE_BYPASS = "bypass"
+
+// Cache stores response bodies by request key.
+type Cache interface {
+	// Get returns the body stored for key, if present and not expired.
+	Get(key string) ([]byte, bool)
+
+	// Set stores the body for key.
+	Set(key string, body []byte)
+}
+
+// ResponseMeta describes how a response was obtained. It is not part of the API.
+type ResponseMeta struct {
+	// CacheStatus is CACHE_HIT, CACHE_MISS or CACHE_BYPASS; it is empty if no cache is configured.
+	CacheStatus string `json:"cache_status,omitempty"`
+
+	// CacheKey is the key of the request in the cache.
+	CacheKey string `json:"cache_key,omitempty"`
+}
+
+// WithCache puts an opt-in response cache in front of Do. Only requests with an explicit
+// temperature of 0 are cached; everything else bypasses the cache.
+func WithCache(cache Cache) Option {
+	return func(config *Claude) {
+		config.cache = cache
+	}
+}
+
+// CacheKey returns the canonical hash of a request. The JSON is re-encoded with sorted
+// keys and the stream flag removed, so streamed and non-streamed requests share entries.
+func CacheKey(url string, jsonData []byte) (string, error) {
+	var request map[string]interface{}
+	if err := json.Unmarshal(jsonData, &request); err != nil {
+		return "", err
+	}
+	delete(request, "stream")
+
+	canonical, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(url))
+	hash.Write([]byte{'\n'})
+	hash.Write(canonical)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// cacheable reports whether a request is deterministic enough to cache: it must set
+// temperature to 0. The API default temperature is 1.
+func cacheable(jsonData []byte) bool {
+	var request struct {
+		Temperature *float64 `json:"temperature"`
+	}
+	if err := json.Unmarshal(jsonData, &request); err != nil {
+		return false
+	}
+	return request.Temperature != nil && *request.Temperature == 0
+}
+
+// CachedResponse looks a request up in the cache. It returns the cached body, or nil, and
+// the metadata describing the lookup.
+func (c *Claude) CachedResponse(url string, jsonData []byte) (*[]byte, *ResponseMeta) {
+	meta := &ResponseMeta{}
+	if c.cache == nil {
+		return nil, meta
+	}
+
+	if !cacheable(jsonData) {
+		meta.CacheStatus = CACHE_BYPASS
+		return nil, meta
+	}
+
+	key, err := CacheKey(url, jsonData)
+	if err != nil {
+		meta.CacheStatus = CACHE_BYPASS
+		return nil, meta
+	}
+	meta.CacheKey = key
+
+	if body, ok := c.cache.Get(key); ok {
+		meta.CacheStatus = CACHE_HIT
+		return &body, meta
+	}
+
+	meta.CacheStatus = CACHE_MISS
+	return nil, meta
+}
+
+// CacheResponse stores a response body for a request looked up with CachedResponse.
+func (c *Claude) CacheResponse(meta *ResponseMeta, body []byte) {
+	if c.cache == nil || meta == nil || meta.CacheStatus != CACHE_MISS {
+		return
+	}
+	c.cache.Set(meta.CacheKey, body)
+}
+
+// MemoryCache is an in-memory least recently used cache.
+type MemoryCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// memoryEntry is a single MemoryCache entry.
+type memoryEntry struct {
+	key     string
+	body    []byte
+	expires time.Time
+}
+
+// NewMemoryCache creates an LRU cache holding at most size entries. Entries expire after
+// ttl; a ttl of 0 never expires.
+func NewMemoryCache(size int, ttl time.Duration) *MemoryCache {
+	return &MemoryCache{
+		size:    max(size, 1),
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get implements Cache.
+func (m *MemoryCache) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*memoryEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		m.order.Remove(element)
+		delete(m.entries, key)
+		return nil, false
+	}
+
+	m.order.MoveToFront(element)
+	// callers may modify the body, e.g. by unmarshaling into it
+	return bytes.Clone(entry.body), true
+}
+
+// Set implements Cache.
+func (m *MemoryCache) Set(key string, body []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := &memoryEntry{key: key, body: bytes.Clone(body)}
+	if m.ttl > 0 {
+		entry.expires = time.Now().Add(m.ttl)
+	}
+
+	if element, ok := m.entries[key]; ok {
+		element.Value = entry
+		m.order.MoveToFront(element)
+		return
+	}
+
+	m.entries[key] = m.order.PushFront(entry)
+	for m.order.Len() > m.size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+// DiskCache stores responses as files in a directory.
+type DiskCache struct {
+	directory string
+	ttl       time.Duration
+}
+
+// NewDiskCache creates a cache in directory, creating it if needed. Entries expire after
+// ttl; a ttl of 0 never expires.
+func NewDiskCache(directory string, ttl time.Duration) (*DiskCache, error) {
+	fqpn, err := filepath.Abs(directory)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(fqpn, 0755); err != nil {
+		return nil, err
+	}
+	return &DiskCache{directory: fqpn, ttl: ttl}, nil
+}
+
+// Get implements Cache.
+func (d *DiskCache) Get(key string) ([]byte, bool) {
+	fqpn := filepath.Join(d.directory, key+".json")
+
+	info, err := os.Stat(fqpn)
+	if err != nil {
+		return nil, false
+	}
+	if d.ttl > 0 && time.Since(info.ModTime()) > d.ttl {
+		os.Remove(fqpn)
+		return nil, false
+	}
+
+	body, err := os.ReadFile(fqpn)
+	if err != nil {
+		return nil, false
+	}
+	return body, true
+}
+
+// Set implements Cache. Write errors are ignored; a failed write is a future cache miss.
+func (d *DiskCache) Set(key string, body []byte) {
+	file, err := os.CreateTemp(d.directory, key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = file.Write(body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filepath.Join(d.directory, key+".json"))
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+}
@@ -0,0 +1,218 @@
+package claude
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const TEST_URL = "https://api.anthropic.com/v1/messages"
+
+func TestCacheKey(t *testing.T) {
+	base := `{"model":"m","temperature":0,"messages":[{"role":"user","content":"hi"}]}`
+
+	tests := []struct {
+		name  string
+		url   string
+		json  string
+		equal bool
+	}{
+		{name: "identical", url: TEST_URL, json: base, equal: true},
+		{name: "key order", url: TEST_URL, json: `{"messages":[{"content":"hi","role":"user"}],"temperature":0,"model":"m"}`, equal: true},
+		{name: "whitespace", url: TEST_URL, json: "{\n  \"model\": \"m\",\n  \"temperature\": 0,\n  \"messages\": [{\"role\": \"user\", \"content\": \"hi\"}]\n}", equal: true},
+		{name: "stream flag", url: TEST_URL, json: `{"model":"m","temperature":0,"stream":true,"messages":[{"role":"user","content":"hi"}]}`, equal: true},
+		{name: "different content", url: TEST_URL, json: `{"model":"m","temperature":0,"messages":[{"role":"user","content":"bye"}]}`},
+		{name: "different model", url: TEST_URL, json: `{"model":"n","temperature":0,"messages":[{"role":"user","content":"hi"}]}`},
+		{name: "different url", url: TEST_URL + "/count_tokens", json: base},
+	}
+
+	want, err := CacheKey(TEST_URL, []byte(base))
+	if err != nil {
+		t.Fatalf("CacheKey: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CacheKey(tt.url, []byte(tt.json))
+			if err != nil {
+				t.Fatalf("CacheKey: %v", err)
+			}
+			if (got == want) != tt.equal {
+				t.Errorf("CacheKey equal = %v, want %v", got == want, tt.equal)
+			}
+		})
+	}
+
+	if _, err := CacheKey(TEST_URL, []byte("not json")); err == nil {
+		t.Errorf("CacheKey of invalid JSON returned no error")
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	tests := []struct {
+		json string
+		want bool
+	}{
+		{json: `{"temperature":0}`, want: true},
+		{json: `{"temperature":0.0,"stream":true}`, want: true},
+		{json: `{"temperature":0.5}`},
+		{json: `{"temperature":null}`},
+		{json: `{}`},
+		{json: `not json`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			if got := cacheable([]byte(tt.json)); got != tt.want {
+				t.Errorf("cacheable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	cache := NewMemoryCache(2, 0)
+	cache.Set("a", []byte("1"))
+	cache.Set("b", []byte("2"))
+
+	// reading a makes b the least recently used
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatalf("a missing")
+	}
+	cache.Set("c", []byte("3"))
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := cache.Get(key); ok != want {
+			t.Errorf("Get(%s) present = %v, want %v", key, ok, want)
+		}
+	}
+
+	// replacing an entry does not evict another
+	cache.Set("c", []byte("4"))
+	if body, ok := cache.Get("c"); !ok || string(body) != "4" {
+		t.Errorf("Get(c) = %q, %v, want 4", body, ok)
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Errorf("a evicted by replacing c")
+	}
+}
+
+func TestMemoryCacheTTL(t *testing.T) {
+	cache := NewMemoryCache(10, 20*time.Millisecond)
+	cache.Set("a", []byte("1"))
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatalf("a missing before it expired")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("a present after it expired")
+	}
+}
+
+func TestMemoryCacheCopies(t *testing.T) {
+	cache := NewMemoryCache(10, 0)
+	body := []byte("1")
+	cache.Set("a", body)
+	body[0] = 'x'
+
+	got, _ := cache.Get("a")
+	got[0] = 'y'
+	if got, _ := cache.Get("a"); string(got) != "1" {
+		t.Errorf("Get(a) = %q after modifying the stored and returned bodies, want 1", got)
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "cache")
+	cache, err := NewDiskCache(directory, time.Hour)
+	if err != nil {
+		t.Fatalf("NewDiskCache: %v", err)
+	}
+
+	if _, ok := cache.Get("missing"); ok {
+		t.Errorf("Get(missing) present")
+	}
+
+	cache.Set("a", []byte(`{"id":"a"}`))
+	if body, ok := cache.Get("a"); !ok || string(body) != `{"id":"a"}` {
+		t.Errorf("Get(a) = %q, %v", body, ok)
+	}
+
+	// another instance on the same directory shares the entries
+	shared, err := NewDiskCache(directory, time.Hour)
+	if err != nil {
+		t.Fatalf("NewDiskCache: %v", err)
+	}
+	if _, ok := shared.Get("a"); !ok {
+		t.Errorf("Get(a) from a second instance missing")
+	}
+
+	// age the entry past the ttl; it is removed on read
+	fqpn := filepath.Join(directory, "a.json")
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(fqpn, old, old); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("Get(a) present after it expired")
+	}
+	if _, err := os.Stat(fqpn); !os.IsNotExist(err) {
+		t.Errorf("expired entry not removed: %v", err)
+	}
+
+	// no temporary files are left behind
+	files, err := filepath.Glob(filepath.Join(directory, "*.tmp"))
+	if err != nil || len(files) != 0 {
+		t.Errorf("temporary files left: %v %v", files, err)
+	}
+}
+
+func TestCachedResponse(t *testing.T) {
+	cacheableJSON := []byte(`{"model":"m","temperature":0}`)
+
+	c, err := New(WithAPIKey("sk-ant-test"), WithCache(NewMemoryCache(10, 0)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	uncached, err := New(WithAPIKey("sk-ant-test"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	steps := []struct {
+		name   string
+		client *Claude
+		json   []byte
+		store  bool
+		status string
+	}{
+		{name: "no cache", client: uncached, json: cacheableJSON, store: true},
+		{name: "non-zero temperature", client: c, json: []byte(`{"model":"m","temperature":1}`), store: true, status: CACHE_BYPASS},
+		{name: "invalid json", client: c, json: []byte(`not json`), store: true, status: CACHE_BYPASS},
+		{name: "first request", client: c, json: cacheableJSON, store: true, status: CACHE_MISS},
+		{name: "repeated request", client: c, json: cacheableJSON, status: CACHE_HIT},
+		{name: "streamed request shares the entry", client: c, json: []byte(`{"stream":true,"temperature":0,"model":"m"}`), status: CACHE_HIT},
+		{name: "bypassed requests were not stored", client: c, json: []byte(`{"model":"m","temperature":1}`), status: CACHE_BYPASS},
+	}
+
+	for i, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			body, meta := step.client.CachedResponse(TEST_URL, step.json)
+			if meta.CacheStatus != step.status {
+				t.Fatalf("CacheStatus = %q, want %q", meta.CacheStatus, step.status)
+			}
+			if (body != nil) != (step.status == CACHE_HIT) {
+				t.Fatalf("body = %v, want one only on a hit", body)
+			}
+			if step.status == CACHE_HIT && string(*body) != "response 3" {
+				t.Errorf("body = %q, want the stored response", *body)
+			}
+			if step.store {
+				step.client.CacheResponse(meta, []byte(fmt.Sprintf("response %d", i)))
+			}
+		})
+	}
+}
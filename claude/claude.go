@@ -5,14 +5,17 @@ package claude
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/davecgh/go-spew/spew"
+	"github.com/rmrfslashbin/ami/instrument"
 	"github.com/tmaxmax/go-sse"
 )
 
@@ -20,6 +23,15 @@ const MODULE_NAME = "claude"
 const ANTHROPIC_VERSION = "2023-06-01"
 const URL = "https://api.anthropic.com"
 
+// STATUS_OVERLOADED is the status code of overloaded_error responses.
+const STATUS_OVERLOADED = 529
+
+// RETRY_BACKOFF is the first wait between retries when the API does not send retry-after.
+const RETRY_BACKOFF = time.Second
+
+// RETRY_MAX_WAIT caps the wait between retries.
+const RETRY_MAX_WAIT = time.Minute
+
 // headers:
 // x-api-key: YOUR_API_KEY"
 // content-type: application/json
@@ -55,26 +67,31 @@ Claude 3 Haiku	| claude-3-haiku-20240307
 */
 
 type Model struct {
-	Name            string `json:"name"`
-	MaxOutputTokens int    `json:"max_output_tokens"`
+	Name            string   `json:"name"`
+	MaxOutputTokens int      `json:"max_output_tokens"`
+	Pricing         *Pricing `json:"pricing"`
 }
 
 var ModelsList = map[string]*Model{
 	"opus": {
 		Name:            "claude-3-opus-20240229",
 		MaxOutputTokens: 4096,
+		Pricing:         &Pricing{Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.50},
 	},
 	"sonnet": {
 		Name:            "claude-3-sonnet-20240229",
 		MaxOutputTokens: 4096,
+		Pricing:         &Pricing{Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
 	},
 	"haiku": {
 		Name:            "claude-3-haiku-20240307",
 		MaxOutputTokens: 4096,
+		Pricing:         &Pricing{Input: 0.25, Output: 1.25, CacheWrite: 0.30, CacheRead: 0.03},
 	},
 	"sonnet35": {
 		Name:            "claude-3-5-sonnet-20240620",
 		MaxOutputTokens: 4096,
+		Pricing:         &Pricing{Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
 	},
 }
 
@@ -82,9 +99,25 @@ type Option func(config *Claude)
 
 // Configuration structure.
 type Claude struct {
-	apikey  *string
-	log     *slog.Logger
-	headers map[string]string
+	apikey     *string
+	log        *slog.Logger
+	headers    map[string]string
+	httpClient *http.Client
+	baseURL    string
+	retries    int
+
+	spendMu        sync.Mutex
+	spend          map[string]*Spend
+	total          Spend
+	usageCallbacks []func(*UsageRecord)
+
+	budgetConfig *Budget
+	budget       *BudgetTracker
+
+	cache Cache
+
+	debugBodies     bool
+	instrumentation instrument.Instrumentation
 }
 
 func New(opts ...func(*Claude)) (*Claude, error) {
@@ -92,6 +125,8 @@ func New(opts ...func(*Claude)) (*Claude, error) {
 
 	// init headers
 	config.headers = make(map[string]string)
+	config.spend = make(map[string]*Spend)
+	config.baseURL = URL
 
 	// apply the list of options to Config
 	for _, opt := range opts {
@@ -102,6 +137,26 @@ func New(opts ...func(*Claude)) (*Claude, error) {
 		return nil, &ErrMissingAPIKey{}
 	}
 
+	if config.httpClient == nil {
+		config.httpClient = &http.Client{}
+	}
+
+	if config.log == nil {
+		config.log = discardLogger()
+	}
+
+	if config.instrumentation == nil {
+		config.instrumentation = instrument.Noop{}
+	}
+
+	if config.budgetConfig != nil {
+		budget, err := NewBudgetTracker(config.budgetConfig)
+		if err != nil {
+			return nil, err
+		}
+		config.budget = budget
+	}
+
 	config.headers["x-api-key"] = *config.apikey
 	config.headers["content-type"] = "application/json"
 	config.headers["anthropic-version"] = ANTHROPIC_VERSION
@@ -125,6 +180,29 @@ func WithLogger(log *slog.Logger) Option {
 	}
 }
 
+// WithHTTPClient sets the HTTP client used for every request, e.g. one with a recording
+// transport from the recorder package.
+func WithHTTPClient(client *http.Client) Option {
+	return func(config *Claude) {
+		config.httpClient = client
+	}
+}
+
+// WithBaseURL points the client at another server, e.g. a claudetest fake. The default is URL.
+func WithBaseURL(baseURL string) Option {
+	return func(config *Claude) {
+		config.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithRetries retries requests refused with 429 (rate limit) or 529 (overloaded) up to
+// retries times, waiting as long as the retry-after header asks or backing off exponentially.
+func WithRetries(retries int) Option {
+	return func(config *Claude) {
+		config.retries = retries
+	}
+}
+
 func (c *Claude) GetModelMaxOutputTokens(modelName string) int {
 	if _, ok := ModelsList[modelName]; !ok {
 		return -1
@@ -138,8 +216,108 @@ func (c *Claude) GetHeaders() map[string]string {
 	return c.headers
 }
 
+// GetBaseURL returns the base URL of the API.
+func (c *Claude) GetBaseURL() string {
+	return c.baseURL
+}
+
+// GetHTTPClient returns the HTTP client used for requests.
+func (c *Claude) GetHTTPClient() *http.Client {
+	return c.httpClient
+}
+
 func (c *Claude) Do(url string, jsonData []byte) (*[]byte, error) {
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	return c.DoContext(context.Background(), url, jsonData)
+}
+
+// DoContext is Do with a context, which also cancels the wait between retries.
+func (c *Claude) DoContext(ctx context.Context, url string, jsonData []byte) (*[]byte, error) {
+	body, _, err := c.DoWithMetaContext(ctx, url, jsonData)
+	return body, err
+}
+
+// DoWithMeta is Do, also returning how the response was obtained.
+func (c *Claude) DoWithMeta(url string, jsonData []byte) (*[]byte, *ResponseMeta, error) {
+	return c.DoWithMetaContext(context.Background(), url, jsonData)
+}
+
+// DoWithMetaContext is DoWithMeta with a context, which also cancels the wait between
+// retries.
+func (c *Claude) DoWithMetaContext(ctx context.Context, url string, jsonData []byte) (*[]byte, *ResponseMeta, error) {
+	return c.DoChecked(ctx, url, jsonData, nil)
+}
+
+// DoChecked is DoWithMetaContext, calling check, if set, only when the request is not
+// answered from the cache. The request is not sent if check fails; e.g. a budget check
+// does not charge cached responses.
+func (c *Claude) DoChecked(ctx context.Context, url string, jsonData []byte, check func() error) (*[]byte, *ResponseMeta, error) {
+	model, stream := requestSummary(jsonData)
+	entry := c.StartRequest(ctx, url, model, stream)
+	defer c.EndRequest(entry)
+
+	body, meta := c.CachedResponse(url, jsonData)
+	entry.CacheStatus = meta.CacheStatus
+	if body == nil {
+		if check != nil {
+			if err := check(); err != nil {
+				entry.Err = err
+				return nil, meta, err
+			}
+		}
+		c.LogRequestBody(jsonData)
+
+		responseBody, err := c.do(ctx, url, jsonData, entry)
+		if err != nil {
+			entry.Err = err
+			return nil, meta, err
+		}
+		c.CacheResponse(meta, *responseBody)
+		body = responseBody
+		c.LogBody("api response body", *body)
+	} else {
+		entry.StatusCode = http.StatusOK
+	}
+
+	responseSummary(entry, *body)
+	return body, meta, nil
+}
+
+// do sends a request to the API, retrying as configured by WithRetries. The status,
+// request id and retry count are recorded in entry.
+func (c *Claude) do(ctx context.Context, url string, jsonData []byte, entry *RequestLog) (*[]byte, error) {
+	for attempt := 0; ; attempt++ {
+		entry.Retries = attempt
+		responseBody, err := c.send(ctx, url, jsonData, entry)
+		if err == nil {
+			return responseBody, nil
+		}
+
+		var httpErr *ErrHTTP
+		if attempt >= c.retries || !errors.As(err, &httpErr) || !retryable(httpErr.StatusCode) {
+			return nil, err
+		}
+
+		wait := httpErr.RetryAfter
+		if wait <= 0 {
+			wait = RETRY_BACKOFF << attempt
+		}
+		wait = min(wait, RETRY_MAX_WAIT)
+		c.log.LogAttrs(ctx, slog.LevelWarn, "api request retrying",
+			slog.String("url", url),
+			slog.Int("status", httpErr.StatusCode),
+			slog.Int("attempt", attempt+1),
+			slog.Duration("wait", wait))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// send sends a single request to the API.
+func (c *Claude) send(ctx context.Context, url string, jsonData []byte, entry *RequestLog) (*[]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
@@ -149,17 +327,17 @@ func (c *Claude) Do(url string, jsonData []byte) (*[]byte, error) {
 		req.Header.Set(key, value)
 	}
 
-	// Create an HTTP client
-	client := &http.Client{}
-
 	// Send the request
-	resp, err := client.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
 
 	defer resp.Body.Close()
 
+	entry.StatusCode = resp.StatusCode
+	entry.RequestId = resp.Header.Get("request-id")
+
 	responseBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
@@ -171,22 +349,55 @@ func (c *Claude) Do(url string, jsonData []byte) (*[]byte, error) {
 			URL:        url,
 			Data:       &jsonData,
 			Body:       &responseBody,
+			RetryAfter: retryAfter(resp.Header.Get("retry-after")),
+			RequestId:  entry.RequestId,
 		}
 	}
 
 	return &responseBody, nil
 }
 
-func (c *Claude) Stream(url string, jsonData []byte) (*[]byte, error) {
-	log := c.log.With(
-		slog.Group("function_info",
-			slog.String("function", "claude/claude.go/Stream()"),
-		),
-	)
+// ValidateStreamResponse checks the response to a streaming request before its events are
+// read, returning an ErrHTTP for error statuses. Use it as the sse.Client ResponseValidator.
+func ValidateStreamResponse(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		responseBody, _ := io.ReadAll(resp.Body)
+		return &ErrHTTP{
+			StatusCode: resp.StatusCode,
+			URL:        resp.Request.URL.String(),
+			Body:       &responseBody,
+			RetryAfter: retryAfter(resp.Header.Get("retry-after")),
+			RequestId:  resp.Header.Get("request-id"),
+		}
+	}
+	return sse.DefaultValidator(resp)
+}
 
-	log.LogAttrs(context.TODO(), slog.LevelError, "Streaming!")
+// retryable reports whether a status code is worth retrying.
+func retryable(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == STATUS_OVERLOADED
+}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+// retryAfter parses a retry-after header given in seconds. It returns 0 if absent or invalid.
+func retryAfter(value string) time.Duration {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// Stream sends a streaming request and returns the raw event stream. Each event is
+// logged at debug level; use messages.Messages.Stream for parsed events.
+func (c *Claude) Stream(url string, jsonData []byte) (*[]byte, error) {
+	model, _ := requestSummary(jsonData)
+	entry := c.StartRequest(context.Background(), url, model, true)
+	defer c.EndRequest(entry)
+	c.LogRequestBody(jsonData)
+
+	// the connection is cancelled once the message is complete; otherwise the SSE
+	// client treats the closed stream as a lost connection and sends the request again
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
@@ -199,18 +410,34 @@ func (c *Claude) Stream(url string, jsonData []byte) (*[]byte, error) {
 		req.Header.Set(key, value)
 	}
 
-	conn := sse.DefaultClient.NewConnection(req)
+	client := &sse.Client{
+		HTTPClient: c.httpClient,
+		ResponseValidator: func(resp *http.Response) error {
+			entry.StatusCode = resp.StatusCode
+			entry.RequestId = resp.Header.Get("request-id")
+			return ValidateStreamResponse(resp)
+		},
+		Backoff: sse.Backoff{MaxRetries: -1},
+	}
+	conn := client.NewConnection(req)
 
+	stream := &bytes.Buffer{}
+	done := false
 	conn.SubscribeToAll(func(event sse.Event) {
-		spew.Dump(event)
+		c.log.LogAttrs(context.Background(), slog.LevelDebug, "stream event",
+			slog.String("type", event.Type))
+		fmt.Fprintf(stream, "event: %s\ndata: %s\n\n", event.Type, event.Data)
+		if event.Type == "message_stop" {
+			done = true
+			cancel()
+		}
 	})
 
-	if err := conn.Connect(); err != nil {
-		log.LogAttrs(context.TODO(), slog.LevelError,
-			"error connecting to streaming service",
-			slog.String("error", err.Error()))
+	if err := conn.Connect(); err != nil && !done {
+		entry.Err = err
 		return nil, err
 	}
 
-	return nil, nil
+	body := stream.Bytes()
+	return &body, nil
 }
@@ -0,0 +1,564 @@
+package claudetest
+
+// path: claude/claudetest/claudetest.go
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rmrfslashbin/ami/claude"
+	"github.com/rmrfslashbin/ami/claude/messages"
+	"github.com/rmrfslashbin/ami/validate"
+)
+
+// MODULE_NAME is the module name
+const MODULE_NAME = "claudetest"
+
+// API_KEY is the API key Client uses. The server accepts any non-empty key.
+const API_KEY = "claudetest-api-key"
+
+// MODEL is the model reported by replies that do not set one.
+const MODEL = "claude-3-haiku-20240307"
+
+// ERROR_TYPES maps status codes to API error types.
+var ERROR_TYPES = map[int]string{
+	400: "invalid_request_error",
+	401: "authentication_error",
+	403: "permission_error",
+	404: "not_found_error",
+	413: "request_too_large",
+	429: "rate_limit_error",
+	500: "api_error",
+	529: "overloaded_error",
+}
+
+// Option is a configuration option.
+type Option func(config *Server)
+
+// Server is an in-process fake of the Messages API, built on httptest. Replies are
+// scripted with Enqueue and served in order; every request is recorded for assertions.
+type Server struct {
+	log         *slog.Logger
+	chunkSize   int
+	delay       time.Duration
+	tokenCounts func(*messages.CountTokensRequest) int
+	checks      []func(*ReceivedRequest) error
+
+	mu       sync.Mutex
+	replies  []*Reply
+	requests []*ReceivedRequest
+	errs     []error
+	nextId   int
+
+	server *httptest.Server
+}
+
+// New starts a new Server. Close it when done.
+func New(opts ...func(*Server)) (*Server, error) {
+	config := &Server{}
+	config.chunkSize = 16
+	config.tokenCounts = estimateTokens
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+messages.PATH, config.handleMessages)
+	mux.HandleFunc("POST "+messages.COUNT_TOKENS_PATH, config.handleCountTokens)
+	config.server = httptest.NewServer(mux)
+
+	return config, nil
+}
+
+// WithChunkSize sets how many characters each streamed text or tool input delta carries.
+func WithChunkSize(chunkSize int) Option {
+	return func(config *Server) {
+		config.chunkSize = max(chunkSize, 1)
+	}
+}
+
+// WithDelay sets the pause between streamed events.
+func WithDelay(delay time.Duration) Option {
+	return func(config *Server) {
+		config.delay = delay
+	}
+}
+
+// WithTokenCounter sets how count_tokens requests are answered. The default estimates
+// one token per four characters of text.
+func WithTokenCounter(counter func(*messages.CountTokensRequest) int) Option {
+	return func(config *Server) {
+		config.tokenCounts = counter
+	}
+}
+
+// WithCheck adds an assertion run on every request. Failures are reported by Verify.
+func WithCheck(check func(*ReceivedRequest) error) Option {
+	return func(config *Server) {
+		config.checks = append(config.checks, check)
+	}
+}
+
+// WithLogger sets the logger.
+func WithLogger(log *slog.Logger) Option {
+	return func(config *Server) {
+		moduleLogger := log.With(
+			slog.Group("module_info",
+				slog.String("module", MODULE_NAME),
+			),
+		)
+		config.log = moduleLogger
+	}
+}
+
+// URL returns the base URL of the server; pass it to claude.WithBaseURL.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Client returns a claude.Claude pointed at the server. Further options are applied after
+// the API key and base URL.
+func (s *Server) Client(opts ...claude.Option) (*claude.Claude, error) {
+	options := []func(*claude.Claude){
+		claude.WithAPIKey(API_KEY),
+		claude.WithBaseURL(s.server.URL),
+	}
+	for _, opt := range opts {
+		options = append(options, opt)
+	}
+	return claude.New(options...)
+}
+
+// Enqueue adds replies to the script.
+func (s *Server) Enqueue(replies ...*Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, replies...)
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []*ReceivedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*ReceivedRequest{}, s.requests...)
+}
+
+// LastRequest returns the most recent request, or nil.
+func (s *Server) LastRequest() *ReceivedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return nil
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+// Verify reports invalid requests, failed checks, requests that found no scripted reply
+// and scripted replies that were never requested.
+func (s *Server) Verify() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errs := append([]error{}, s.errs...)
+	if len(s.replies) > 0 {
+		errs = append(errs, &ErrUnusedReplies{Count: len(s.replies)})
+	}
+	return errors.Join(errs...)
+}
+
+// Text returns a reply with a single text block.
+func Text(text string) *Reply {
+	return &Reply{Response: &messages.Response{
+		Role:       "assistant",
+		Content:    []*messages.Content{{Type: "text", Text: text}},
+		StopReason: "end_turn",
+	}}
+}
+
+// ToolUse returns a reply asking for a tool, with an optional leading text block.
+func ToolUse(text string, id string, name string, input interface{}) *Reply {
+	content := []*messages.Content{}
+	if text != "" {
+		content = append(content, &messages.Content{Type: "text", Text: text})
+	}
+	content = append(content, &messages.Content{Type: "tool_use", Id: id, Name: name, Input: input})
+	return &Reply{Response: &messages.Response{
+		Role:       "assistant",
+		Content:    content,
+		StopReason: "tool_use",
+	}}
+}
+
+// Error returns an error reply. retryAfter is sent as the retry-after header if non-zero.
+func Error(statusCode int, message string, retryAfter time.Duration) *Reply {
+	return &Reply{
+		StatusCode: statusCode,
+		Error:      &messages.Error{Type: ERROR_TYPES[statusCode], Message: message},
+		RetryAfter: retryAfter,
+	}
+}
+
+// RateLimited returns a 429 rate_limit_error reply.
+func RateLimited(retryAfter time.Duration) *Reply {
+	return Error(http.StatusTooManyRequests, "Number of requests has exceeded your rate limit", retryAfter)
+}
+
+// Overloaded returns a 529 overloaded_error reply.
+func Overloaded(retryAfter time.Duration) *Reply {
+	return Error(claude.STATUS_OVERLOADED, "Overloaded", retryAfter)
+}
+
+// handleMessages serves /v1/messages.
+func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
+	received, ok := s.receive(w, r)
+	if !ok {
+		return
+	}
+
+	request := &messages.Request{}
+	if err := json.Unmarshal(received.Body, request); err != nil {
+		s.fail(w, received, http.StatusBadRequest, err)
+		return
+	}
+	received.Message = request
+	if err := validate.Struct(request); err != nil {
+		s.fail(w, received, http.StatusBadRequest, err)
+		return
+	}
+	if !s.check(received) {
+		writeError(w, http.StatusBadRequest, "request failed a claudetest check", 0)
+		return
+	}
+
+	reply := s.next(received)
+	if reply == nil {
+		writeError(w, http.StatusInternalServerError, "claudetest: no scripted reply", 0)
+		return
+	}
+
+	for key, value := range reply.Headers {
+		w.Header().Set(key, value)
+	}
+	w.Header().Set("request-id", fmt.Sprintf("req_claudetest_%d", len(s.Requests())))
+
+	statusCode := reply.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	if reply.StreamError && !request.Stream {
+		statusCode = http.StatusInternalServerError
+	}
+	if statusCode != http.StatusOK || reply.Response == nil {
+		message := ""
+		if reply.Error != nil {
+			message = reply.Error.Message
+		}
+		writeError(w, max(statusCode, http.StatusBadRequest), message, reply.RetryAfter)
+		return
+	}
+
+	response := s.complete(reply.Response, request)
+	if request.Stream {
+		s.stream(w, r, response, reply)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleCountTokens serves /v1/messages/count_tokens.
+func (s *Server) handleCountTokens(w http.ResponseWriter, r *http.Request) {
+	received, ok := s.receive(w, r)
+	if !ok {
+		return
+	}
+
+	request := &messages.CountTokensRequest{}
+	if err := json.Unmarshal(received.Body, request); err != nil {
+		s.fail(w, received, http.StatusBadRequest, err)
+		return
+	}
+	received.CountTokens = request
+	if !s.check(received) {
+		writeError(w, http.StatusBadRequest, "request failed a claudetest check", 0)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(&messages.CountTokensResponse{InputTokens: s.tokenCounts(request)})
+}
+
+// receive records a request and checks its headers.
+func (s *Server) receive(w http.ResponseWriter, r *http.Request) (*ReceivedRequest, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), 0)
+		return nil, false
+	}
+
+	received := &ReceivedRequest{
+		Time:   time.Now(),
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		Body:   body,
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, received)
+	s.mu.Unlock()
+
+	if s.log != nil {
+		s.log.Debug("received request", slog.String("method", r.Method), slog.String("path", r.URL.Path))
+	}
+
+	switch {
+	case r.Header.Get("x-api-key") == "":
+		s.fail(w, received, http.StatusUnauthorized, errors.New("missing x-api-key header"))
+		return nil, false
+	case r.Header.Get("anthropic-version") == "":
+		s.fail(w, received, http.StatusBadRequest, errors.New("missing anthropic-version header"))
+		return nil, false
+	}
+
+	return received, true
+}
+
+// check runs the WithCheck assertions, recording failures.
+func (s *Server) check(received *ReceivedRequest) bool {
+	ok := true
+	for _, check := range s.checks {
+		if err := check(received); err != nil {
+			s.mu.Lock()
+			s.errs = append(s.errs, &ErrInvalidRequest{Err: err, Index: s.index(received)})
+			s.mu.Unlock()
+			ok = false
+		}
+	}
+	return ok
+}
+
+// fail records an invalid request and answers it with an error status.
+func (s *Server) fail(w http.ResponseWriter, received *ReceivedRequest, statusCode int, err error) {
+	s.mu.Lock()
+	s.errs = append(s.errs, &ErrInvalidRequest{Err: err, Index: s.index(received)})
+	s.mu.Unlock()
+	writeError(w, statusCode, err.Error(), 0)
+}
+
+// next pops the next scripted reply, recording an error if there is none.
+func (s *Server) next(received *ReceivedRequest) *Reply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.replies) == 0 {
+		s.errs = append(s.errs, &ErrUnexpectedRequest{Method: received.Method, Path: received.Path})
+		return nil
+	}
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	return reply
+}
+
+// index returns the position of a received request. The caller must hold s.mu.
+func (s *Server) index(received *ReceivedRequest) int {
+	for i, r := range s.requests {
+		if r == received {
+			return i
+		}
+	}
+	return -1
+}
+
+// complete fills in the fields a scripted response leaves empty.
+func (s *Server) complete(scripted *messages.Response, request *messages.Request) *messages.Response {
+	response := *scripted
+
+	s.mu.Lock()
+	s.nextId++
+	id := s.nextId
+	s.mu.Unlock()
+
+	if response.Id == "" {
+		response.Id = fmt.Sprintf("msg_claudetest_%d", id)
+	}
+	if response.Type == "" {
+		response.Type = "message"
+	}
+	if response.Role == "" {
+		response.Role = "assistant"
+	}
+	if response.Model == "" {
+		response.Model = request.Model
+	}
+	if response.Model == "" {
+		response.Model = MODEL
+	}
+	if response.StopReason == "" {
+		response.StopReason = "end_turn"
+	}
+	if response.Usage.InputTokens == 0 {
+		response.Usage.InputTokens = s.tokenCounts(&messages.CountTokensRequest{
+			Model:    request.Model,
+			Messages: request.Messages,
+			System:   request.System,
+		})
+	}
+	if response.Usage.OutputTokens == 0 {
+		response.Usage.OutputTokens = max(1, len(blockText(response.Content))/4)
+	}
+	return &response
+}
+
+// stream sends a response as server-sent events, split into chunks.
+func (s *Server) stream(w http.ResponseWriter, r *http.Request, response *messages.Response, reply *Reply) {
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, data interface{}) bool {
+		if s.delay > 0 {
+			select {
+			case <-time.After(s.delay):
+			case <-r.Context().Done():
+				return false
+			}
+		}
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+
+	start := *response
+	start.Content = []*messages.Content{}
+	start.StopReason = ""
+	start.Usage.OutputTokens = 1
+	if !send("message_start", map[string]interface{}{"type": "message_start", "message": &start}) {
+		return
+	}
+	if !send("ping", map[string]string{"type": "ping"}) {
+		return
+	}
+
+	if reply.StreamError {
+		streamErr := reply.Error
+		if streamErr == nil {
+			streamErr = &messages.Error{Type: ERROR_TYPES[claude.STATUS_OVERLOADED], Message: "Overloaded"}
+		}
+		send("error", &errorBody{Type: "error", Error: streamErr})
+		return
+	}
+
+	for i, content := range response.Content {
+		var block map[string]interface{}
+		var deltas []map[string]interface{}
+
+		switch content.Type {
+		case "tool_use":
+			block = map[string]interface{}{"type": "tool_use", "id": content.Id, "name": content.Name, "input": map[string]interface{}{}}
+			input, err := json.Marshal(content.Input)
+			if err != nil {
+				input = []byte("{}")
+			}
+			for _, chunk := range chunks(string(input), s.chunkSize) {
+				deltas = append(deltas, map[string]interface{}{"type": "input_json_delta", "partial_json": chunk})
+			}
+		default:
+			block = map[string]interface{}{"type": "text", "text": ""}
+			for _, chunk := range chunks(content.Text, s.chunkSize) {
+				deltas = append(deltas, map[string]interface{}{"type": "text_delta", "text": chunk})
+			}
+		}
+
+		if !send("content_block_start", map[string]interface{}{"type": "content_block_start", "index": i, "content_block": block}) {
+			return
+		}
+		for _, delta := range deltas {
+			if !send("content_block_delta", map[string]interface{}{"type": "content_block_delta", "index": i, "delta": delta}) {
+				return
+			}
+		}
+		if !send("content_block_stop", map[string]interface{}{"type": "content_block_stop", "index": i}) {
+			return
+		}
+	}
+
+	if !send("message_delta", map[string]interface{}{
+		"type":  "message_delta",
+		"delta": map[string]interface{}{"stop_reason": response.StopReason, "stop_sequence": nil},
+		"usage": map[string]int{"output_tokens": response.Usage.OutputTokens},
+	}) {
+		return
+	}
+	send("message_stop", map[string]string{"type": "message_stop"})
+}
+
+// writeError writes an API error body.
+func writeError(w http.ResponseWriter, statusCode int, message string, retryAfter time.Duration) {
+	if retryAfter > 0 {
+		w.Header().Set("retry-after", strconv.Itoa(int(retryAfter.Round(time.Second)/time.Second)))
+	}
+	errorType, ok := ERROR_TYPES[statusCode]
+	if !ok {
+		errorType = "api_error"
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(&errorBody{Type: "error", Error: &messages.Error{Type: errorType, Message: message}})
+}
+
+// chunks splits text into pieces of at most size runes. Empty text is a single empty chunk.
+func chunks(text string, size int) []string {
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return []string{""}
+	}
+	pieces := []string{}
+	for start := 0; start < len(runes); start += size {
+		pieces = append(pieces, string(runes[start:min(start+size, len(runes))]))
+	}
+	return pieces
+}
+
+// blockText concatenates the text of content blocks.
+func blockText(content []*messages.Content) string {
+	text := ""
+	for _, block := range content {
+		text += block.Text + block.Content
+	}
+	return text
+}
+
+// estimateTokens is the default token counter: one token per four characters.
+func estimateTokens(request *messages.CountTokensRequest) int {
+	text := request.System
+	for _, message := range request.Messages {
+		text += blockText(message.MessageContent)
+	}
+	return max(1, len(text)/4)
+}
@@ -0,0 +1,267 @@
+package claudetest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/invopop/jsonschema"
+	"github.com/rmrfslashbin/ami/claude"
+	"github.com/rmrfslashbin/ami/claude/claudetest"
+	"github.com/rmrfslashbin/ami/claude/messages"
+)
+
+// newMessages starts a server and returns a haiku Messages client pointed at it.
+func newMessages(t *testing.T, opts ...claude.Option) (*claudetest.Server, *messages.Messages) {
+	t.Helper()
+	server, err := claudetest.New()
+	if err != nil {
+		t.Fatalf("claudetest.New: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	c, err := server.Client(opts...)
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	m, err := messages.New(messages.WithClaude(c), messages.WithHaiku())
+	if err != nil {
+		t.Fatalf("messages.New: %v", err)
+	}
+	return server, m
+}
+
+func TestRetryAfter(t *testing.T) {
+	server, m := newMessages(t, claude.WithRetries(2))
+	server.Enqueue(claudetest.RateLimited(time.Second), claudetest.Text("Hello."))
+
+	m.AddRoleUser("Say hello.")
+	res, err := m.Send()
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if len(res.Content) != 1 || res.Content[0].Text != "Hello." {
+		t.Errorf("Send content = %+v", res.Content)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("server received %d requests, want 2", len(requests))
+	}
+	if wait := requests[1].Time.Sub(requests[0].Time); wait < time.Second {
+		t.Errorf("retried after %s, want at least the 1s retry-after", wait)
+	}
+	if err := server.Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	server, m := newMessages(t)
+	server.Enqueue(claudetest.RateLimited(time.Minute))
+
+	m.AddRoleUser("Say hello.")
+	request := m.GetMessageRequest()
+	request.Messages = m.GetConversation().Messages
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		t.Fatalf("marshaling request: %v", err)
+	}
+	c, err := server.Client(claude.WithRetries(2))
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+
+	// the context ends the wait for the minute long retry-after
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.DoContext(ctx, c.GetBaseURL()+messages.PATH, jsonData); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DoContext = %v, want context.DeadlineExceeded", err)
+	}
+	if wait := time.Since(start); wait > 5*time.Second {
+		t.Errorf("DoContext returned after %s", wait)
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("server received %d requests, want 1", n)
+	}
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	tests := []struct {
+		name     string
+		retries  int
+		replies  []*claudetest.Reply
+		status   int
+		requests int
+	}{
+		{name: "no retries", replies: []*claudetest.Reply{claudetest.Overloaded(time.Second)},
+			status: claude.STATUS_OVERLOADED, requests: 1},
+		{name: "retries used up", retries: 1, replies: []*claudetest.Reply{claudetest.Overloaded(time.Second), claudetest.RateLimited(time.Second)},
+			status: http.StatusTooManyRequests, requests: 2},
+		{name: "not retryable", retries: 3, replies: []*claudetest.Reply{claudetest.Error(http.StatusBadRequest, "bad", 0)},
+			status: http.StatusBadRequest, requests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, m := newMessages(t, claude.WithRetries(tt.retries))
+			server.Enqueue(tt.replies...)
+
+			m.AddRoleUser("Say hello.")
+			_, err := m.Send()
+			var httpErr *claude.ErrHTTP
+			if !errors.As(err, &httpErr) || httpErr.StatusCode != tt.status {
+				t.Fatalf("Send = %v, want ErrHTTP %d", err, tt.status)
+			}
+			if got := len(server.Requests()); got != tt.requests {
+				t.Errorf("server received %d requests, want %d", got, tt.requests)
+			}
+			if err := server.Verify(); err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestToolUseRoundTrip(t *testing.T) {
+	server, m := newMessages(t)
+	server.Enqueue(
+		claudetest.ToolUse("Let me check.", "toolu_1", "get_weather", map[string]interface{}{"city": "Paris"}),
+		claudetest.Text("It is sunny in Paris."),
+	)
+
+	m.AddTool(&messages.Tool{Name: "get_weather", InputSchema: &jsonschema.Schema{Type: "object"}})
+	m.AddRoleUser("What is the weather in Paris?")
+
+	res, err := m.Send()
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if res.StopReason != "tool_use" || len(res.Content) != 2 {
+		t.Fatalf("Send = %s %+v, want a text and a tool_use block", res.StopReason, res.Content)
+	}
+	use := res.Content[1]
+	if use.Type != "tool_use" || use.Id != "toolu_1" || use.Name != "get_weather" {
+		t.Fatalf("tool_use block = %+v", use)
+	}
+	if input, ok := use.Input.(map[string]interface{}); !ok || input["city"] != "Paris" {
+		t.Errorf("tool_use input = %#v", use.Input)
+	}
+
+	if err := m.AddRoleUserToolResult(use.Id, "18C and sunny"); err != nil {
+		t.Fatalf("AddRoleUserToolResult: %v", err)
+	}
+	res, err = m.Send()
+	if err != nil {
+		t.Fatalf("Send with tool result: %v", err)
+	}
+	if len(res.Content) != 1 || res.Content[0].Text != "It is sunny in Paris." {
+		t.Errorf("Send content = %+v", res.Content)
+	}
+
+	// the second request carries the tool_use turn and its result
+	sent := server.LastRequest().Message
+	if len(sent.Messages) != 3 || len(sent.Tools) != 1 {
+		t.Fatalf("second request has %d messages and %d tools, want 3 and 1", len(sent.Messages), len(sent.Tools))
+	}
+	result := sent.Messages[2].MessageContent[0]
+	if result.Type != "tool_result" || result.ToolUseId != "toolu_1" || result.Content != "18C and sunny" {
+		t.Errorf("tool_result block = %+v", result)
+	}
+	if err := server.Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestCountTokens(t *testing.T) {
+	server, m := newMessages(t)
+	m.AddRoleUser("Count these tokens please.")
+
+	tokens, err := m.CountTokens()
+	if err != nil {
+		t.Fatalf("CountTokens: %v", err)
+	}
+	if tokens <= 0 {
+		t.Errorf("CountTokens = %d, want a positive count", tokens)
+	}
+	if got := server.LastRequest().Path; got != messages.COUNT_TOKENS_PATH {
+		t.Errorf("request path = %s, want %s", got, messages.COUNT_TOKENS_PATH)
+	}
+}
+
+// drain reads a stream until its response channel is closed and returns the events.
+func drain(t *testing.T, stream messages.StreamResults) []messages.StreamingMessageResponse {
+	t.Helper()
+	events := []messages.StreamingMessageResponse{}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-stream.Response:
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		case <-timeout:
+			t.Fatalf("stream not closed after %d events", len(events))
+		}
+	}
+}
+
+func TestStreamError(t *testing.T) {
+	server, m := newMessages(t)
+	reply := claudetest.Text("Hello.")
+	reply.StreamError = true
+	server.Enqueue(reply)
+
+	m.SetStreaming(true)
+	m.AddRoleUser("Say hello.")
+	stream := m.Stream(context.Background())
+	events := drain(t, stream)
+	if n := len(events); n == 0 || events[n-1].StreamingError == nil {
+		t.Errorf("events = %+v, want a trailing streaming error", events)
+	}
+
+	// only the first error is delivered
+	var streaming *messages.ErrStreamingMessage
+	if err := <-stream.Error; !errors.As(err, &streaming) {
+		t.Errorf("Error = %v, want ErrStreamingMessage", err)
+	}
+	select {
+	case err := <-stream.Error:
+		t.Errorf("second error %v", err)
+	default:
+	}
+}
+
+func TestStreamCanceled(t *testing.T) {
+	server, err := claudetest.New(claudetest.WithChunkSize(1), claudetest.WithDelay(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("claudetest.New: %v", err)
+	}
+	t.Cleanup(server.Close)
+	c, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	m, err := messages.New(messages.WithClaude(c), messages.WithHaiku(), messages.WithStreaming(true))
+	if err != nil {
+		t.Fatalf("messages.New: %v", err)
+	}
+	server.Enqueue(claudetest.Text("A long answer, streamed one character at a time."))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.AddRoleUser("Say something long.")
+	stream := m.Stream(ctx)
+	<-stream.Response
+	cancel()
+
+	// the abandoned stream stops sending instead of blocking until it is read
+	time.Sleep(100 * time.Millisecond)
+	if events := drain(t, stream); len(events) > 1 {
+		t.Errorf("%d events delivered after cancel, want at most 1", len(events))
+	}
+}
@@ -0,0 +1,60 @@
+package claudetest
+
+import "fmt"
+
+type ErrUnexpectedRequest struct {
+	Err    error
+	Msg    string
+	Method string
+	Path   string
+}
+
+func (e *ErrUnexpectedRequest) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "unexpected request; no scripted reply left"
+	}
+	if e.Method != "" || e.Path != "" {
+		msg += fmt.Sprintf(" %s %s", e.Method, e.Path)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidRequest struct {
+	Err   error
+	Msg   string
+	Index int
+}
+
+func (e *ErrInvalidRequest) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid request"
+	}
+	msg += fmt.Sprintf(" #%d", e.Index)
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrUnusedReplies struct {
+	Err   error
+	Msg   string
+	Count int
+}
+
+func (e *ErrUnusedReplies) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "scripted replies were never requested"
+	}
+	msg += fmt.Sprintf(": %d", e.Count)
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
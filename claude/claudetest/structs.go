@@ -0,0 +1,61 @@
+package claudetest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rmrfslashbin/ami/claude/messages"
+)
+
+// Reply is a scripted answer to a Messages API request.
+type Reply struct {
+	// StatusCode is the HTTP status. The default is 200.
+	StatusCode int
+
+	// Response is the message returned on success. Streaming requests receive it as
+	// server-sent events.
+	Response *messages.Response
+
+	// Error is the error body returned with an error status.
+	Error *messages.Error
+
+	// StreamError sends Error as an "error" event part way through a stream instead of as
+	// an error status. Non-streaming requests receive it as a 500.
+	StreamError bool
+
+	// RetryAfter is sent as the retry-after header, in whole seconds, if set.
+	RetryAfter time.Duration
+
+	// Headers are extra response headers.
+	Headers map[string]string
+}
+
+// ReceivedRequest is a request the server received.
+type ReceivedRequest struct {
+	// Time is when the request arrived.
+	Time time.Time
+
+	// Method is the HTTP method.
+	Method string
+
+	// Path is the URL path.
+	Path string
+
+	// Header are the request headers.
+	Header http.Header
+
+	// Body is the raw request body.
+	Body []byte
+
+	// Message is the decoded body of a /v1/messages request.
+	Message *messages.Request
+
+	// CountTokens is the decoded body of a /v1/messages/count_tokens request.
+	CountTokens *messages.CountTokensRequest
+}
+
+// errorBody is the JSON shape of an API error.
+type errorBody struct {
+	Type  string          `json:"type"`
+	Error *messages.Error `json:"error"`
+}
@@ -0,0 +1,416 @@
+package conversations
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/rmrfslashbin/ami/claude/messages"
+)
+
+// MODULE_NAME is the module name
+const MODULE_NAME = "conversations"
+
+// FILE_EXTENSION is the extension used for conversation files.
+const FILE_EXTENSION = ".gob"
+
+// SNIPPET_LENGTH is the approximate length of a search result snippet.
+const SNIPPET_LENGTH = 120
+
+// Option is a configuration option.
+type Option func(config *Manager)
+
+// Manager manages a directory of conversation files.
+type Manager struct {
+	log       *slog.Logger
+	directory *string
+
+	mu    sync.Mutex
+	index map[string]*indexEntry
+}
+
+// New creates a new Manager.
+func New(opts ...func(*Manager)) (*Manager, error) {
+	config := &Manager{}
+	config.index = make(map[string]*indexEntry)
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.directory == nil {
+		return nil, &ErrMissingDirectory{}
+	}
+
+	fqpn, err := filepath.Abs(*config.directory)
+	if err != nil {
+		return nil, err
+	}
+	config.directory = &fqpn
+
+	if err := os.MkdirAll(fqpn, 0755); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// WithDirectory sets the directory holding the conversation files.
+func WithDirectory(directory string) Option {
+	return func(config *Manager) {
+		config.directory = &directory
+	}
+}
+
+// WithLogger sets the logger.
+func WithLogger(log *slog.Logger) Option {
+	return func(config *Manager) {
+		moduleLogger := log.With(
+			slog.Group("module_info",
+				slog.String("module", MODULE_NAME),
+			),
+		)
+		config.log = moduleLogger
+	}
+}
+
+// Path returns the file path for a conversation id. Pass it to messages.WithConversationFile
+// to continue the conversation.
+func (m *Manager) Path(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", &ErrInvalidId{Id: id}
+	}
+	return filepath.Join(*m.directory, id+FILE_EXTENSION), nil
+}
+
+// Create creates a new, empty conversation and returns its summary.
+func (m *Manager) Create(title string, model string) (*Summary, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	id := hex.EncodeToString(buf)
+
+	fqpn, err := m.Path(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(fqpn); err == nil {
+		return nil, &ErrConversationExists{Id: id}
+	}
+
+	now := time.Now()
+	conversation := &messages.Conversation{
+		Id:      id,
+		Title:   title,
+		Created: now,
+		Updated: now,
+	}
+	if model != "" {
+		conversation.Model = &model
+	}
+
+	if err := messages.SaveConversation(fqpn, conversation); err != nil {
+		return nil, err
+	}
+
+	return summarize(id, conversation), nil
+}
+
+// Open loads a conversation by id.
+func (m *Manager) Open(id string) (*messages.Conversation, error) {
+	fqpn, err := m.Path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(fqpn); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, &ErrConversationNotFound{Id: id}
+		}
+		return nil, err
+	}
+
+	conversation, err := messages.LoadConversation(fqpn)
+	if err != nil {
+		return nil, err
+	}
+
+	// older files may not carry their id
+	if conversation.Id == "" {
+		conversation.Id = id
+	}
+
+	return conversation, nil
+}
+
+// Delete removes a conversation.
+func (m *Manager) Delete(id string) error {
+	fqpn, err := m.Path(id)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(fqpn); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &ErrConversationNotFound{Id: id}
+		}
+		return err
+	}
+
+	m.mu.Lock()
+	delete(m.index, id)
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Rename sets the title of a conversation.
+func (m *Manager) Rename(id string, title string) error {
+	conversation, err := m.Open(id)
+	if err != nil {
+		return err
+	}
+
+	conversation.Title = title
+	conversation.Updated = time.Now()
+
+	fqpn, err := m.Path(id)
+	if err != nil {
+		return err
+	}
+
+	return messages.SaveConversation(fqpn, conversation)
+}
+
+// List returns the summaries of all conversations, most recently updated first.
+func (m *Manager) List() ([]*Summary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.refresh(); err != nil {
+		return nil, err
+	}
+
+	summaries := make([]*Summary, 0, len(m.index))
+	for _, entry := range m.index {
+		summaries = append(summaries, entry.summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Updated.Equal(summaries[j].Updated) {
+			return summaries[i].Id < summaries[j].Id
+		}
+		return summaries[i].Updated.After(summaries[j].Updated)
+	})
+
+	return summaries, nil
+}
+
+// Search runs a case-insensitive full-text search over message text. Every word of
+// the query must appear in a content block for it to match.
+func (m *Manager) Search(query string) ([]*SearchResult, error) {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.refresh(); err != nil {
+		return nil, err
+	}
+
+	results := []*SearchResult{}
+	for id, entry := range m.index {
+		for _, block := range entry.blocks {
+			if !block.matches(terms) {
+				continue
+			}
+			results = append(results, &SearchResult{
+				ConversationId: id,
+				Title:          entry.summary.Title,
+				MessageIndex:   block.messageIndex,
+				ContentIndex:   block.contentIndex,
+				Role:           block.role,
+				Snippet:        snippet(block.text, terms[0]),
+			})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := m.index[results[i].ConversationId], m.index[results[j].ConversationId]
+		if results[i].ConversationId != results[j].ConversationId {
+			if a.summary.Updated.Equal(b.summary.Updated) {
+				return results[i].ConversationId < results[j].ConversationId
+			}
+			return a.summary.Updated.After(b.summary.Updated)
+		}
+		if results[i].MessageIndex != results[j].MessageIndex {
+			return results[i].MessageIndex < results[j].MessageIndex
+		}
+		return results[i].ContentIndex < results[j].ContentIndex
+	})
+
+	return results, nil
+}
+
+// refresh rescans the directory and re-indexes files that changed since the last scan.
+// The caller must hold m.mu.
+func (m *Manager) refresh() error {
+	entries, err := os.ReadDir(*m.directory)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]struct{})
+	for _, dirEntry := range entries {
+		if dirEntry.IsDir() || filepath.Ext(dirEntry.Name()) != FILE_EXTENSION {
+			continue
+		}
+		id := strings.TrimSuffix(dirEntry.Name(), FILE_EXTENSION)
+		seen[id] = struct{}{}
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			return err
+		}
+
+		if cached, ok := m.index[id]; ok && cached.modTime.Equal(info.ModTime()) {
+			continue
+		}
+
+		conversation, err := messages.LoadConversation(filepath.Join(*m.directory, dirEntry.Name()))
+		if err != nil {
+			// skip unreadable files rather than failing the whole listing
+			if m.log != nil {
+				m.log.Warn("skipping unreadable conversation",
+					slog.String("id", id),
+					slog.String("error", err.Error()))
+			}
+			delete(m.index, id)
+			continue
+		}
+
+		m.index[id] = &indexEntry{
+			modTime: info.ModTime(),
+			summary: summarize(id, conversation),
+			blocks:  indexConversation(conversation),
+		}
+	}
+
+	for id := range m.index {
+		if _, ok := seen[id]; !ok {
+			delete(m.index, id)
+		}
+	}
+
+	return nil
+}
+
+// summarize builds a Summary for a conversation.
+func summarize(id string, conversation *messages.Conversation) *Summary {
+	summary := &Summary{
+		Id:           id,
+		Title:        conversation.Title,
+		Created:      conversation.Created,
+		Updated:      conversation.Updated,
+		MessageCount: len(conversation.Messages),
+		Cost:         conversation.Spend.Cost,
+	}
+	if conversation.Model != nil {
+		summary.Model = *conversation.Model
+	}
+	for _, message := range conversation.Messages {
+		switch message.Role {
+		case "user":
+			summary.UserMessages++
+		case "assistant":
+			summary.AssistantMessages++
+		}
+	}
+	return summary
+}
+
+// indexConversation extracts the searchable blocks of a conversation.
+func indexConversation(conversation *messages.Conversation) []*indexBlock {
+	blocks := []*indexBlock{}
+	for i, message := range conversation.Messages {
+		for j, content := range message.MessageContent {
+			text := content.Text
+			if text == "" {
+				text = content.Content
+			}
+			if text == "" {
+				continue
+			}
+			terms := make(map[string]struct{})
+			for _, term := range tokenize(text) {
+				terms[term] = struct{}{}
+			}
+			blocks = append(blocks, &indexBlock{
+				messageIndex: i,
+				contentIndex: j,
+				role:         message.Role,
+				text:         text,
+				terms:        terms,
+			})
+		}
+	}
+	return blocks
+}
+
+// matches reports whether every term is present in the block.
+func (b *indexBlock) matches(terms []string) bool {
+	for _, term := range terms {
+		if _, ok := b.terms[term]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// tokenize splits text into lower case words.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// snippet returns an excerpt of text around the first occurrence of term.
+func snippet(text string, term string) string {
+	runes := []rune(text)
+	if len(runes) <= SNIPPET_LENGTH {
+		return text
+	}
+
+	pos := strings.Index(strings.ToLower(text), term)
+	if pos < 0 {
+		return string(runes[:SNIPPET_LENGTH]) + "..."
+	}
+	pos = len([]rune(text[:pos]))
+
+	start := max(pos-SNIPPET_LENGTH/2, 0)
+	end := min(start+SNIPPET_LENGTH, len(runes))
+	start = max(end-SNIPPET_LENGTH, 0)
+
+	excerpt := string(runes[start:end])
+	if start > 0 {
+		excerpt = "..." + excerpt
+	}
+	if end < len(runes) {
+		excerpt += "..."
+	}
+	return excerpt
+}
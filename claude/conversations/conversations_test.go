@@ -0,0 +1,231 @@
+package conversations_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rmrfslashbin/ami/claude/conversations"
+	"github.com/rmrfslashbin/ami/claude/messages"
+)
+
+func newManager(t *testing.T) *conversations.Manager {
+	t.Helper()
+	m, err := conversations.New(conversations.WithDirectory(t.TempDir()))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return m
+}
+
+// save writes a conversation with the given messages, updated at the given time.
+func save(t *testing.T, m *conversations.Manager, title string, updated time.Time, texts ...string) string {
+	t.Helper()
+	summary, err := m.Create(title, "haiku")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	conversation, err := m.Open(summary.Id)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	roles := []string{"user", "assistant"}
+	for i, text := range texts {
+		conversation.Messages = append(conversation.Messages, &messages.Message{
+			Role:           roles[i%2],
+			MessageContent: []*messages.Content{{Type: "text", Text: text}},
+		})
+	}
+	conversation.Updated = updated
+	fqpn, err := m.Path(summary.Id)
+	if err != nil {
+		t.Fatalf("Path: %v", err)
+	}
+	if err := messages.SaveConversation(fqpn, conversation); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+	return summary.Id
+}
+
+func ids(summaries []*conversations.Summary) []string {
+	found := []string{}
+	for _, summary := range summaries {
+		found = append(found, summary.Id)
+	}
+	return found
+}
+
+func TestNewMissingDirectory(t *testing.T) {
+	var missing *conversations.ErrMissingDirectory
+	if _, err := conversations.New(); !errors.As(err, &missing) {
+		t.Errorf("got %v, want ErrMissingDirectory", err)
+	}
+}
+
+func TestCreateOpen(t *testing.T) {
+	m := newManager(t)
+
+	summary, err := m.Create("first", "haiku")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if summary.Title != "first" || summary.Model != "haiku" || summary.MessageCount != 0 {
+		t.Errorf("Create summary = %+v", summary)
+	}
+
+	conversation, err := m.Open(summary.Id)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if conversation.Id != summary.Id || conversation.Title != "first" || conversation.Model == nil || *conversation.Model != "haiku" {
+		t.Errorf("Open = %+v", conversation)
+	}
+
+	var notFound *conversations.ErrConversationNotFound
+	if _, err := m.Open("0123456789abcdef"); !errors.As(err, &notFound) {
+		t.Errorf("Open of a missing id = %v, want ErrConversationNotFound", err)
+	}
+}
+
+func TestPath(t *testing.T) {
+	m := newManager(t)
+
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{id: "abc123", valid: true},
+		{id: ""},
+		{id: "../abc"},
+		{id: "a/b"},
+		{id: ".hidden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			fqpn, err := m.Path(tt.id)
+			var invalid *conversations.ErrInvalidId
+			if tt.valid {
+				if err != nil || filepath.Base(fqpn) != tt.id+conversations.FILE_EXTENSION {
+					t.Errorf("Path = %q, %v", fqpn, err)
+				}
+				return
+			}
+			if !errors.As(err, &invalid) {
+				t.Errorf("Path = %q, %v, want ErrInvalidId", fqpn, err)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	m := newManager(t)
+	now := time.Now()
+
+	older := save(t, m, "older", now.Add(-time.Hour), "hello", "hi there")
+	newer := save(t, m, "newer", now, "question")
+
+	// unreadable files and other extensions are skipped
+	directory := filepath.Dir(mustPath(t, m, older))
+	if err := os.WriteFile(filepath.Join(directory, "broken"+conversations.FILE_EXTENSION), []byte("not gob"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(directory, "notes.txt"), []byte("ignored"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	summaries, err := m.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got := ids(summaries); !slices.Equal(got, []string{newer, older}) {
+		t.Fatalf("List = %v, want [%s %s]", got, newer, older)
+	}
+	if s := summaries[1]; s.MessageCount != 2 || s.UserMessages != 1 || s.AssistantMessages != 1 {
+		t.Errorf("older summary = %+v", s)
+	}
+}
+
+func TestRenameDelete(t *testing.T) {
+	m := newManager(t)
+	updated := time.Now().Add(-time.Hour)
+	id := save(t, m, "before", updated, "hello")
+
+	if _, err := m.List(); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	if err := m.Rename(id, "after"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	summaries, err := m.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].Title != "after" || !summaries[0].Updated.After(updated) {
+		t.Errorf("List after Rename = %+v, want the new title and a later update time", summaries)
+	}
+
+	if err := m.Delete(id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if summaries, err := m.List(); err != nil || len(summaries) != 0 {
+		t.Errorf("List after Delete = %v, %v", ids(summaries), err)
+	}
+
+	var notFound *conversations.ErrConversationNotFound
+	if err := m.Delete(id); !errors.As(err, &notFound) {
+		t.Errorf("second Delete = %v, want ErrConversationNotFound", err)
+	}
+	if err := m.Rename(id, "gone"); !errors.As(err, &notFound) {
+		t.Errorf("Rename after Delete = %v, want ErrConversationNotFound", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	m := newManager(t)
+	now := time.Now()
+
+	lighthouse := save(t, m, "lighthouse", now, "Tell me about the old Lighthouse.", "The lighthouse was built in 1871.")
+	boats := save(t, m, "boats", now.Add(-time.Hour), "Which boats pass the lighthouse?")
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "LIGHTHOUSE", want: []string{lighthouse + " 0", lighthouse + " 1", boats + " 0"}},
+		{query: "old lighthouse", want: []string{lighthouse + " 0"}},
+		{query: "1871", want: []string{lighthouse + " 1"}},
+		{query: "submarine"},
+		{query: "  "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := m.Search(tt.query)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			got := []string{}
+			for _, result := range results {
+				got = append(got, result.ConversationId+" "+strconv.Itoa(result.MessageIndex))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Search = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func mustPath(t *testing.T, m *conversations.Manager, id string) string {
+	t.Helper()
+	fqpn, err := m.Path(id)
+	if err != nil {
+		t.Fatalf("Path: %v", err)
+	}
+	return fqpn
+}
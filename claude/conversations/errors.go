@@ -0,0 +1,77 @@
+package conversations
+
+type ErrMissingDirectory struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingDirectory) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing directory- use WithDirectory to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidId struct {
+	Err error
+	Msg string
+	Id  string
+}
+
+func (e *ErrInvalidId) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid conversation id"
+	}
+	if e.Id != "" {
+		msg += " " + e.Id
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrConversationNotFound struct {
+	Err error
+	Msg string
+	Id  string
+}
+
+func (e *ErrConversationNotFound) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "conversation not found"
+	}
+	if e.Id != "" {
+		msg += " " + e.Id
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrConversationExists struct {
+	Err error
+	Msg string
+	Id  string
+}
+
+func (e *ErrConversationExists) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "conversation already exists"
+	}
+	if e.Id != "" {
+		msg += " " + e.Id
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
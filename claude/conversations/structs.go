@@ -0,0 +1,70 @@
+package conversations
+
+import "time"
+
+// Summary describes a stored conversation without its messages.
+type Summary struct {
+	// Id is the conversation identifier; it is also the file name without extension.
+	Id string `json:"id"`
+
+	// Title is the human readable title of the conversation.
+	Title string `json:"title"`
+
+	// Model is the model used in the conversation.
+	Model string `json:"model"`
+
+	// Created is the time the conversation was created.
+	Created time.Time `json:"created"`
+
+	// Updated is the time the conversation was updated.
+	Updated time.Time `json:"updated"`
+
+	// MessageCount is the total number of messages in the conversation.
+	MessageCount int `json:"message_count"`
+
+	// UserMessages is the number of "user" messages in the conversation.
+	UserMessages int `json:"user_messages"`
+
+	// AssistantMessages is the number of "assistant" messages in the conversation.
+	AssistantMessages int `json:"assistant_messages"`
+
+	// Cost is the total cost of the conversation in US dollars.
+	Cost float64 `json:"cost"`
+}
+
+// SearchResult is a single full-text search hit.
+type SearchResult struct {
+	// ConversationId is the id of the conversation containing the hit.
+	ConversationId string `json:"conversation_id"`
+
+	// Title is the title of the conversation containing the hit.
+	Title string `json:"title"`
+
+	// MessageIndex is the index of the message in Conversation.Messages.
+	MessageIndex int `json:"message_index"`
+
+	// ContentIndex is the index of the content block in Message.MessageContent.
+	ContentIndex int `json:"content_index"`
+
+	// Role is the role of the matching message.
+	Role string `json:"role"`
+
+	// Snippet is a short excerpt of the matching text.
+	Snippet string `json:"snippet"`
+}
+
+// indexEntry is the cached index data for a single conversation file.
+type indexEntry struct {
+	modTime time.Time
+	summary *Summary
+	blocks  []*indexBlock
+}
+
+// indexBlock is a single searchable content block.
+type indexBlock struct {
+	messageIndex int
+	contentIndex int
+	role         string
+	text         string
+	terms        map[string]struct{}
+}
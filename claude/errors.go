@@ -1,6 +1,9 @@
 package claude
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type ErrMissingAPIKey struct {
 	Err error
@@ -45,23 +48,78 @@ type ErrHTTP struct {
 	Data       *[]byte
 	Body       *[]byte
 	StatusCode int
+
+	// RetryAfter is the wait the API asked for in its retry-after header, if any.
+	RetryAfter time.Duration
+
+	// RequestId is the request-id header of the response; quote it to Anthropic support.
+	RequestId string
 }
 
+// Error formats the error without changing it, so it reads the same every time it is
+// called, e.g. once in the request log and again by the caller.
 func (e *ErrHTTP) Error() string {
-	if e.Msg != "" {
-		e.Msg = "HTTP error"
+	msg := e.Msg
+	if msg == "" {
+		msg = "HTTP error"
 	}
 	if e.StatusCode != 0 {
-		e.Msg += fmt.Sprintf(": %d", e.StatusCode)
+		msg += fmt.Sprintf(": %d", e.StatusCode)
 	}
 	if errorAPIText, ok := apiErrors[e.StatusCode]; ok {
-		e.Msg += fmt.Sprintf(": %s", errorAPIText)
+		msg += fmt.Sprintf(": %s", errorAPIText)
 	}
 	if e.URL != "" {
-		e.Msg += fmt.Sprintf(" for %s", e.URL)
+		msg += fmt.Sprintf(" for %s", e.URL)
+	}
+	if e.RequestId != "" {
+		msg += fmt.Sprintf(" (request id %s)", e.RequestId)
 	}
 	if e.Err != nil {
-		return e.Msg + ": " + e.Err.Error()
+		return msg + ": " + e.Err.Error()
 	}
-	return e.Msg
+	return msg
+}
+
+type ErrBudgetExceeded struct {
+	Err     error
+	Msg     string
+	Limit   string
+	UserId  string
+	Max     float64
+	Current float64
+}
+
+func (e *ErrBudgetExceeded) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "budget exceeded"
+	}
+	if e.Limit != "" {
+		msg += ": " + e.Limit
+	}
+	if e.UserId != "" {
+		msg += " for user " + e.UserId
+	}
+	msg += fmt.Sprintf(" (%g of %g)", e.Current, e.Max)
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrBudgetState struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrBudgetState) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "error reading or writing budget state"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
 }
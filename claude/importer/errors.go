@@ -0,0 +1,49 @@
+package importer
+
+type ErrParsingInput struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrParsingInput) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "error parsing input"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrUnknownFormat struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrUnknownFormat) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "unknown conversation format"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrNoMessages struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrNoMessages) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "no messages could be imported"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
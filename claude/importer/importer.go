@@ -0,0 +1,461 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rmrfslashbin/ami/claude/messages"
+)
+
+// roles maps the role names used by other tools onto the Messages API roles.
+var roles = map[string]string{
+	"user":      "user",
+	"human":     "user",
+	"assistant": "assistant",
+	"ai":        "assistant",
+	"bot":       "assistant",
+	"model":     "assistant",
+	"system":    "system",
+	"developer": "system",
+}
+
+// legacyTurn matches the turn markers of the legacy text completions prompt format.
+var legacyTurn = regexp.MustCompile(`\n\n(Human|Assistant):`)
+
+// Import detects the format of data and imports it.
+func Import(data []byte) (*Result, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, &ErrUnknownFormat{}
+	}
+
+	switch data[0] {
+	case '{':
+		return FromConsole(data)
+	case '[':
+		var probe []map[string]json.RawMessage
+		if err := json.Unmarshal(data, &probe); err != nil {
+			return nil, &ErrParsingInput{Err: err}
+		}
+		for _, message := range probe {
+			if _, ok := message["tool_calls"]; ok {
+				return FromOpenAI(data)
+			}
+			if _, ok := message["tool_call_id"]; ok {
+				return FromOpenAI(data)
+			}
+			if bytes.Contains(message["content"], []byte(`"image_url"`)) {
+				return FromOpenAI(data)
+			}
+		}
+		return FromJSON(data)
+	}
+
+	return nil, &ErrUnknownFormat{}
+}
+
+// FromJSON imports a plain JSON array of {role, content} messages. Content is either a
+// string or an array of Anthropic content blocks.
+func FromJSON(data []byte) (*Result, error) {
+	var source []*plainMessage
+	if err := json.Unmarshal(data, &source); err != nil {
+		return nil, &ErrParsingInput{Err: err}
+	}
+
+	b := newBuilder()
+	b.addPlain(source)
+	return b.finish()
+}
+
+// FromConsole imports an Anthropic console export. Both the Messages API request shape
+// and the legacy Human:/Assistant: prompt are supported.
+func FromConsole(data []byte) (*Result, error) {
+	var source consoleExport
+	if err := json.Unmarshal(data, &source); err != nil {
+		return nil, &ErrParsingInput{Err: err}
+	}
+
+	b := newBuilder()
+	if source.Model != nil && *source.Model != "" {
+		b.result.Conversation.Model = source.Model
+	}
+
+	if len(source.System) > 0 {
+		blocks, issues := parseAnthropicContent(source.System)
+		for _, content := range blocks {
+			b.addSystem(content.Text)
+		}
+		for _, issue := range issues {
+			b.issue(-1, "system: %s", issue)
+		}
+	}
+
+	switch {
+	case len(source.Messages) > 0:
+		b.addPlain(source.Messages)
+	case source.Prompt != nil:
+		b.addLegacyPrompt(*source.Prompt)
+	}
+
+	return b.finish()
+}
+
+// FromOpenAI imports an OpenAI style array of chat messages. Tool calls become tool_use
+// blocks and tool messages become tool_result blocks.
+func FromOpenAI(data []byte) (*Result, error) {
+	var source []*openAIMessage
+	if err := json.Unmarshal(data, &source); err != nil {
+		return nil, &ErrParsingInput{Err: err}
+	}
+
+	b := newBuilder()
+	for i, message := range source {
+		if message == nil {
+			b.issue(i, "message is nil")
+			continue
+		}
+		switch message.Role {
+		case "tool":
+			text, issues := parseOpenAIText(message.Content)
+			for _, issue := range issues {
+				b.issue(i, "%s", issue)
+			}
+			b.add(i, "user", []*messages.Content{
+				{Type: "tool_result", ToolUseId: message.ToolCallId, Content: text},
+			})
+
+		case "function":
+			b.issue(i, "legacy function message not supported")
+
+		default:
+			role, ok := roles[strings.ToLower(message.Role)]
+			if !ok {
+				b.issue(i, "unknown role %q", message.Role)
+				continue
+			}
+
+			content, issues := parseOpenAIContent(message.Content)
+			for _, issue := range issues {
+				b.issue(i, "%s", issue)
+			}
+
+			if role == "system" {
+				for _, c := range content {
+					if c.Type == "text" {
+						b.addSystem(c.Text)
+					}
+				}
+				continue
+			}
+
+			for j, call := range message.ToolCalls {
+				if call == nil {
+					b.issue(i, "tool call %d is nil", j)
+					continue
+				}
+				var input interface{}
+				if err := json.Unmarshal([]byte(call.Function.Arguments), &input); err != nil {
+					b.issue(i, "tool call %s: invalid arguments: %s", call.Id, err)
+					input = map[string]interface{}{}
+				}
+				content = append(content, &messages.Content{
+					Type:  "tool_use",
+					Id:    call.Id,
+					Name:  call.Function.Name,
+					Input: input,
+				})
+			}
+
+			b.add(i, role, content)
+		}
+	}
+
+	return b.finish()
+}
+
+// builder accumulates imported messages, merging adjacent turns of the same role.
+type builder struct {
+	result *Result
+}
+
+func newBuilder() *builder {
+	now := time.Now()
+	return &builder{
+		result: &Result{
+			Conversation: &messages.Conversation{
+				Created: now,
+				Updated: now,
+			},
+		},
+	}
+}
+
+// issue records something that could not be converted.
+func (b *builder) issue(index int, format string, args ...interface{}) {
+	b.result.Issues = append(b.result.Issues, &Issue{Index: index, Reason: fmt.Sprintf(format, args...)})
+}
+
+// addSystem appends text to the system prompt.
+func (b *builder) addSystem(text string) {
+	if text == "" {
+		return
+	}
+	if b.result.System != "" {
+		b.result.System += "\n\n"
+	}
+	b.result.System += text
+}
+
+// add appends a turn, merging it into the previous turn if the roles match.
+func (b *builder) add(index int, role string, content []*messages.Content) {
+	if len(content) == 0 {
+		b.issue(index, "empty %s message dropped", role)
+		return
+	}
+
+	conversation := b.result.Conversation
+	if n := len(conversation.Messages); n > 0 && conversation.Messages[n-1].Role == role {
+		conversation.Messages[n-1].MessageContent = append(conversation.Messages[n-1].MessageContent, content...)
+		return
+	}
+
+	conversation.Messages = append(conversation.Messages, &messages.Message{
+		Role:           role,
+		MessageContent: content,
+	})
+}
+
+// addPlain appends messages in the plain {role, content} format.
+func (b *builder) addPlain(source []*plainMessage) {
+	for i, message := range source {
+		if message == nil {
+			b.issue(i, "message is nil")
+			continue
+		}
+		role, ok := roles[strings.ToLower(message.Role)]
+		if !ok {
+			b.issue(i, "unknown role %q", message.Role)
+			continue
+		}
+
+		content, issues := parseAnthropicContent(message.Content)
+		for _, issue := range issues {
+			b.issue(i, "%s", issue)
+		}
+
+		if role == "system" {
+			for _, c := range content {
+				b.addSystem(c.Text)
+			}
+			continue
+		}
+
+		b.add(i, role, content)
+	}
+}
+
+// addLegacyPrompt appends the turns of a legacy Human:/Assistant: prompt.
+func (b *builder) addLegacyPrompt(prompt string) {
+	prompt = "\n\n" + strings.TrimLeft(prompt, "\n")
+	markers := legacyTurn.FindAllStringSubmatchIndex(prompt, -1)
+	if len(markers) == 0 {
+		b.addSystem(strings.TrimSpace(prompt))
+		return
+	}
+
+	b.addSystem(strings.TrimSpace(prompt[:markers[0][0]]))
+	for i, marker := range markers {
+		end := len(prompt)
+		if i+1 < len(markers) {
+			end = markers[i+1][0]
+		}
+		text := strings.TrimSpace(prompt[marker[1]:end])
+		if text == "" {
+			// a trailing "Assistant:" marker is the completion slot
+			continue
+		}
+		role := roles[strings.ToLower(prompt[marker[2]:marker[3]])]
+		b.add(i, role, []*messages.Content{{Type: "text", Text: text}})
+	}
+}
+
+// finish returns the result, failing if nothing could be imported.
+func (b *builder) finish() (*Result, error) {
+	if len(b.result.Conversation.Messages) == 0 {
+		return b.result, &ErrNoMessages{}
+	}
+	if b.result.Conversation.Messages[0].Role != "user" {
+		b.issue(0, "conversation starts with %s; the Messages API requires a user turn first", b.result.Conversation.Messages[0].Role)
+	}
+	return b.result, nil
+}
+
+// parseAnthropicContent parses content that is either a string or an array of
+// Anthropic content blocks.
+func parseAnthropicContent(raw json.RawMessage) ([]*messages.Content, []string) {
+	if text, ok := asString(raw); ok {
+		if text == "" {
+			return nil, nil
+		}
+		return []*messages.Content{{Type: "text", Text: text}}, nil
+	}
+
+	var blocks []*anthropicBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return nil, []string{"unreadable content: " + err.Error()}
+	}
+
+	content := []*messages.Content{}
+	issues := []string{}
+	for j, block := range blocks {
+		if block == nil {
+			issues = append(issues, fmt.Sprintf("block %d is nil", j))
+			continue
+		}
+		switch block.Type {
+		case "text":
+			if block.Text != "" {
+				content = append(content, &messages.Content{Type: "text", Text: block.Text})
+			}
+		case "image":
+			if block.Source == nil || (block.Source.Type != "base64" && block.Source.Type != "url") {
+				issues = append(issues, fmt.Sprintf("block %d: only base64 and url image sources are supported", j))
+				continue
+			}
+			content = append(content, &messages.Content{Type: "image", Source: block.Source})
+		case "tool_use":
+			content = append(content, &messages.Content{Type: "tool_use", Id: block.Id, Name: block.Name, Input: block.Input})
+		case "tool_result":
+			text, blockIssues := parseAnthropicText(block.Content)
+			for _, issue := range blockIssues {
+				issues = append(issues, fmt.Sprintf("block %d: %s", j, issue))
+			}
+			content = append(content, &messages.Content{Type: "tool_result", ToolUseId: block.ToolUseId, Content: text})
+		default:
+			issues = append(issues, fmt.Sprintf("block %d: unsupported content type %q", j, block.Type))
+		}
+	}
+
+	return content, issues
+}
+
+// parseAnthropicText flattens tool_result content, which is a string or an array of blocks,
+// into text.
+func parseAnthropicText(raw json.RawMessage) (string, []string) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+	content, issues := parseAnthropicContent(raw)
+	texts := []string{}
+	for _, c := range content {
+		if c.Type != "text" {
+			issues = append(issues, fmt.Sprintf("%s block in tool result dropped", c.Type))
+			continue
+		}
+		texts = append(texts, c.Text)
+	}
+	return strings.Join(texts, "\n"), issues
+}
+
+// parseOpenAIContent parses content that is either a string or an array of OpenAI parts.
+func parseOpenAIContent(raw json.RawMessage) ([]*messages.Content, []string) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if text, ok := asString(raw); ok {
+		if text == "" {
+			return nil, nil
+		}
+		return []*messages.Content{{Type: "text", Text: text}}, nil
+	}
+
+	var parts []*openAIPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return nil, []string{"unreadable content: " + err.Error()}
+	}
+
+	content := []*messages.Content{}
+	issues := []string{}
+	for j, part := range parts {
+		if part == nil {
+			issues = append(issues, fmt.Sprintf("part %d is nil", j))
+			continue
+		}
+		switch part.Type {
+		case "text":
+			if part.Text != "" {
+				content = append(content, &messages.Content{Type: "text", Text: part.Text})
+			}
+		case "image_url":
+			if part.ImageUrl == nil {
+				issues = append(issues, fmt.Sprintf("part %d: missing image_url", j))
+				continue
+			}
+			source, err := parseDataURI(part.ImageUrl.Url)
+			if err != nil {
+				issues = append(issues, fmt.Sprintf("part %d: %s", j, err))
+				continue
+			}
+			content = append(content, &messages.Content{Type: "image", Source: source})
+		default:
+			issues = append(issues, fmt.Sprintf("part %d: unsupported content type %q", j, part.Type))
+		}
+	}
+
+	return content, issues
+}
+
+// parseOpenAIText flattens OpenAI content into text.
+func parseOpenAIText(raw json.RawMessage) (string, []string) {
+	content, issues := parseOpenAIContent(raw)
+	texts := []string{}
+	for _, c := range content {
+		if c.Type != "text" {
+			issues = append(issues, fmt.Sprintf("%s part in tool message dropped", c.Type))
+			continue
+		}
+		texts = append(texts, c.Text)
+	}
+	return strings.Join(texts, "\n"), issues
+}
+
+// parseDataURI converts a base64 data URI or an http(s) URL into a media source.
+func parseDataURI(uri string) (*messages.MediaSource, error) {
+	if strings.HasPrefix(uri, "https://") || strings.HasPrefix(uri, "http://") {
+		return &messages.MediaSource{Type: "url", URL: uri}, nil
+	}
+	if !strings.HasPrefix(uri, "data:") {
+		return nil, fmt.Errorf("only data URI and http(s) images are supported")
+	}
+
+	header, data, ok := strings.Cut(strings.TrimPrefix(uri, "data:"), ",")
+	if !ok || !strings.HasSuffix(header, ";base64") {
+		return nil, fmt.Errorf("only base64 data URI images are supported")
+	}
+
+	mediaType := strings.TrimSuffix(header, ";base64")
+	if !slices.Contains(messages.SUPPORTED_MIME_TYPES, mediaType) {
+		return nil, fmt.Errorf("unsupported mime type %s", mediaType)
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+		return nil, fmt.Errorf("invalid base64 image data: %s", err)
+	}
+
+	return &messages.MediaSource{Type: "base64", MediaType: mediaType, Data: data}, nil
+}
+
+// asString reports whether raw is a JSON string and returns it.
+func asString(raw json.RawMessage) (string, bool) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return "", false
+	}
+	return text, true
+}
@@ -0,0 +1,233 @@
+package importer_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/ami/claude/importer"
+	"github.com/rmrfslashbin/ami/claude/messages"
+)
+
+// PIXEL is a base64 encoded 1x1 PNG.
+const PIXEL = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mNkYAAAAAYAAjCB0C8AAAAASUVORK5CYII="
+
+// summary renders the messages of a conversation as "role: type text" lines.
+func summary(conversation *messages.Conversation) []string {
+	lines := []string{}
+	for _, message := range conversation.Messages {
+		for _, content := range message.MessageContent {
+			detail := content.Text
+			switch content.Type {
+			case "image":
+				detail = content.Source.MediaType + content.Source.URL
+			case "tool_use":
+				detail = fmt.Sprintf("%s %s %v", content.Id, content.Name, content.Input)
+			case "tool_result":
+				detail = content.ToolUseId + " " + content.Content
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s %s", message.Role, content.Type, detail))
+		}
+	}
+	return lines
+}
+
+// reasons returns the "index reason" pairs of the issues.
+func reasons(issues []*importer.Issue) []string {
+	found := []string{}
+	for _, issue := range issues {
+		found = append(found, fmt.Sprintf("%d %s", issue.Index, issue.Reason))
+	}
+	return found
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		want   []string
+		system string
+		model  string
+	}{
+		{
+			name:   "plain strings",
+			data:   `[{"role": "system", "content": "Be brief."}, {"role": "human", "content": "hi"}, {"role": "AI", "content": "hello"}]`,
+			want:   []string{"user: text hi", "assistant: text hello"},
+			system: "Be brief.",
+		},
+		{
+			name: "plain blocks merge adjacent roles",
+			data: `[{"role": "user", "content": [{"type": "text", "text": "look"}, {"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "` + PIXEL + `"}}]},
+				{"role": "user", "content": "again"}]`,
+			want: []string{"user: text look", "user: image image/png", "user: text again"},
+		},
+		{
+			name: "plain tool round trip",
+			data: `[{"role": "user", "content": "weather?"},
+				{"role": "assistant", "content": [{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": {"city": "Paris"}}]},
+				{"role": "user", "content": [{"type": "tool_result", "tool_use_id": "toolu_1", "content": [{"type": "text", "text": "sunny"}]}]}]`,
+			want: []string{"user: text weather?", "assistant: tool_use toolu_1 get_weather map[city:Paris]", "user: tool_result toolu_1 sunny"},
+		},
+		{
+			name:   "console messages",
+			data:   `{"model": "claude-3-haiku-20240307", "system": [{"type": "text", "text": "Be kind."}], "messages": [{"role": "user", "content": "hi"}]}`,
+			want:   []string{"user: text hi"},
+			system: "Be kind.",
+			model:  "claude-3-haiku-20240307",
+		},
+		{
+			name:   "console legacy prompt",
+			data:   `{"prompt": "You are helpful.\n\nHuman: hi\n\nAssistant: hello\n\nHuman: more\n\nAssistant:"}`,
+			want:   []string{"user: text hi", "assistant: text hello", "user: text more"},
+			system: "You are helpful.",
+		},
+		{
+			name: "openai tool calls",
+			data: `[{"role": "developer", "content": "Be brief."}, {"role": "user", "content": "weather?"},
+				{"role": "assistant", "content": null, "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\": \"Paris\"}"}}]},
+				{"role": "tool", "tool_call_id": "call_1", "content": "sunny"}]`,
+			want:   []string{"user: text weather?", "assistant: tool_use call_1 get_weather map[city:Paris]", "user: tool_result call_1 sunny"},
+			system: "Be brief.",
+		},
+		{
+			name: "openai image parts",
+			data: `[{"role": "user", "content": [{"type": "text", "text": "look"}, {"type": "image_url", "image_url": {"url": "data:image/png;base64,` + PIXEL + `"}},
+				{"type": "image_url", "image_url": {"url": "https://example.com/a.png"}}]}]`,
+			want: []string{"user: text look", "user: image image/png", "user: image https://example.com/a.png"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := importer.Import([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if got := summary(result.Conversation); !slices.Equal(got, tt.want) {
+				t.Errorf("messages =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if result.System != tt.system {
+				t.Errorf("System = %q, want %q", result.System, tt.system)
+			}
+			model := ""
+			if result.Conversation.Model != nil {
+				model = *result.Conversation.Model
+			}
+			if model != tt.model {
+				t.Errorf("Model = %q, want %q", model, tt.model)
+			}
+			if got := reasons(result.Issues); len(got) != 0 {
+				t.Errorf("Issues = %v, want none", got)
+			}
+		})
+	}
+}
+
+func TestImportIssues(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		want   []string
+		issues []string
+	}{
+		{
+			name:   "unknown role",
+			data:   `[{"role": "user", "content": "hi"}, {"role": "narrator", "content": "meanwhile"}]`,
+			want:   []string{"user: text hi"},
+			issues: []string{`1 unknown role "narrator"`},
+		},
+		{
+			name:   "unsupported block and image source",
+			data:   `[{"role": "user", "content": [{"type": "text", "text": "hi"}, {"type": "document"}, {"type": "image", "source": {"type": "file"}}]}]`,
+			want:   []string{"user: text hi"},
+			issues: []string{`0 block 1: unsupported content type "document"`, "0 block 2: only base64 and url image sources are supported"},
+		},
+		{
+			name:   "unreadable content",
+			data:   `[{"role": "user", "content": "hi"}, {"role": "assistant", "content": 42}]`,
+			want:   []string{"user: text hi"},
+			issues: []string{"1 unreadable content: json: cannot unmarshal number into Go value of type []*importer.anthropicBlock", "1 empty assistant message dropped"},
+		},
+		{
+			name:   "assistant first",
+			data:   `[{"role": "assistant", "content": "hello"}]`,
+			want:   []string{"assistant: text hello"},
+			issues: []string{"0 conversation starts with assistant; the Messages API requires a user turn first"},
+		},
+		{
+			name: "openai ftp image and bad arguments",
+			data: `[{"role": "user", "content": [{"type": "text", "text": "look"}, {"type": "image_url", "image_url": {"url": "ftp://example.com/a.png"}}]},
+				{"role": "assistant", "tool_calls": [{"id": "call_1", "function": {"name": "f", "arguments": "{"}}]},
+				{"role": "function", "content": "legacy"}]`,
+			want: []string{"user: text look", "assistant: tool_use call_1 f map[]"},
+			issues: []string{"0 part 1: only data URI and http(s) images are supported",
+				"1 tool call call_1: invalid arguments: unexpected end of JSON input",
+				"2 legacy function message not supported"},
+		},
+		{
+			name:   "nil message and block",
+			data:   `[null, {"role": "user", "content": [null, {"type": "text", "text": "hi"}]}]`,
+			want:   []string{"user: text hi"},
+			issues: []string{"0 message is nil", "1 block 0 is nil"},
+		},
+		{
+			name: "openai nil message, part and tool call",
+			data: `[{"role": "user", "content": [null, {"type": "image_url", "image_url": {"url": "https://example.com/a.png"}}]}, null,
+				{"role": "assistant", "content": "ok", "tool_calls": [null]}]`,
+			want:   []string{"user: image https://example.com/a.png", "assistant: text ok"},
+			issues: []string{"0 part 0 is nil", "1 message is nil", "2 tool call 0 is nil"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := importer.Import([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if got := summary(result.Conversation); !slices.Equal(got, tt.want) {
+				t.Errorf("messages =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if got := reasons(result.Issues); !slices.Equal(got, tt.issues) {
+				t.Errorf("Issues =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.issues, "\n"))
+			}
+		})
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		check func(error) bool
+	}{
+		{name: "empty", data: "  ", check: func(err error) bool { var e *importer.ErrUnknownFormat; return errors.As(err, &e) }},
+		{name: "not json", data: "hello", check: func(err error) bool { var e *importer.ErrUnknownFormat; return errors.As(err, &e) }},
+		{name: "broken array", data: `[{"role": `, check: func(err error) bool { var e *importer.ErrParsingInput; return errors.As(err, &e) }},
+		{name: "broken object", data: `{"messages": 1}`, check: func(err error) bool { var e *importer.ErrParsingInput; return errors.As(err, &e) }},
+		{name: "no messages", data: `[]`, check: func(err error) bool { var e *importer.ErrNoMessages; return errors.As(err, &e) }},
+		{name: "only null", data: `[null]`, check: func(err error) bool { var e *importer.ErrNoMessages; return errors.As(err, &e) }},
+		{name: "only system", data: `[{"role": "system", "content": "Be brief."}]`, check: func(err error) bool { var e *importer.ErrNoMessages; return errors.As(err, &e) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := importer.Import([]byte(tt.data)); !tt.check(err) {
+				t.Errorf("Import = %v", err)
+			}
+		})
+	}
+}
+
+func TestFromOpenAINull(t *testing.T) {
+	result, err := importer.FromOpenAI([]byte("[null]"))
+	var noMessages *importer.ErrNoMessages
+	if !errors.As(err, &noMessages) {
+		t.Fatalf("FromOpenAI = %v, want ErrNoMessages", err)
+	}
+	if got := reasons(result.Issues); !slices.Equal(got, []string{"0 message is nil"}) {
+		t.Errorf("Issues = %v", got)
+	}
+}
@@ -0,0 +1,83 @@
+package importer
+
+import (
+	"encoding/json"
+
+	"github.com/rmrfslashbin/ami/claude/messages"
+)
+
+// Result is the outcome of an import.
+type Result struct {
+	// Conversation is the imported conversation.
+	Conversation *messages.Conversation `json:"conversation"`
+
+	// System is the system prompt found in the source, if any. Conversations do not
+	// carry a system prompt; pass it to Messages.SetSystemPrompt.
+	System string `json:"system,omitempty"`
+
+	// Issues lists everything that could not be converted.
+	Issues []*Issue `json:"issues,omitempty"`
+}
+
+// Issue describes a part of the source that could not be converted.
+type Issue struct {
+	// Index is the index of the message in the source.
+	Index int `json:"index"`
+
+	// Reason describes what was dropped and why.
+	Reason string `json:"reason"`
+}
+
+// plainMessage is a message in the plain and console formats.
+type plainMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// consoleExport is the Anthropic console export; it mirrors a Messages API request body.
+// Legacy exports carry a Human:/Assistant: prompt instead of messages.
+type consoleExport struct {
+	Model    *string         `json:"model"`
+	System   json.RawMessage `json:"system"`
+	Messages []*plainMessage `json:"messages"`
+	Prompt   *string         `json:"prompt"`
+}
+
+// anthropicBlock is a content block in the Anthropic format.
+type anthropicBlock struct {
+	Type      string                `json:"type"`
+	Text      string                `json:"text"`
+	Source    *messages.MediaSource `json:"source"`
+	Id        string                `json:"id"`
+	Name      string                `json:"name"`
+	Input     interface{}           `json:"input"`
+	ToolUseId string                `json:"tool_use_id"`
+	Content   json.RawMessage       `json:"content"`
+}
+
+// openAIMessage is a message in the OpenAI chat format.
+type openAIMessage struct {
+	Role       string            `json:"role"`
+	Content    json.RawMessage   `json:"content"`
+	ToolCalls  []*openAIToolCall `json:"tool_calls"`
+	ToolCallId string            `json:"tool_call_id"`
+}
+
+// openAIPart is a content part in the OpenAI chat format.
+type openAIPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageUrl *struct {
+		Url string `json:"url"`
+	} `json:"image_url"`
+}
+
+// openAIToolCall is a tool call in the OpenAI chat format.
+type openAIToolCall struct {
+	Id       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
@@ -0,0 +1,122 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/rmrfslashbin/ami/instrument"
+)
+
+// GEN_AI_SYSTEM is the gen_ai.system attribute of every span.
+const GEN_AI_SYSTEM = "anthropic"
+
+// WithInstrumentation emits a span and metrics for every API call. The default is
+// instrument.Noop.
+func WithInstrumentation(instrumentation instrument.Instrumentation) Option {
+	return func(config *Claude) {
+		config.instrumentation = instrumentation
+	}
+}
+
+// StartRequest logs the start of an API call and starts its span. Pass the result to
+// EndRequest once the call is complete.
+func (c *Claude) StartRequest(ctx context.Context, url string, model string, stream bool) *RequestLog {
+	entry := &RequestLog{URL: url, Model: model, Stream: stream, start: time.Now()}
+
+	c.log.LogAttrs(ctx, slog.LevelDebug, "api request started",
+		slog.String("url", url),
+		slog.String("model", model),
+		slog.Bool("stream", stream))
+
+	operation := operationName(url)
+	entry.ctx, entry.span = c.instrumentation.Start(ctx, operation+" "+model,
+		instrument.String(instrument.ATTR_GEN_AI_SYSTEM, GEN_AI_SYSTEM),
+		instrument.String(instrument.ATTR_GEN_AI_OPERATION_NAME, operation),
+		instrument.String(instrument.ATTR_GEN_AI_REQUEST_MODEL, model),
+		instrument.String(instrument.ATTR_URL_FULL, url),
+		instrument.Bool("gen_ai.request.stream", stream))
+
+	return entry
+}
+
+// EndRequest logs the outcome of an API call started with StartRequest, ends its span
+// and records its metrics. Token usage is only recorded for chat calls; counting tokens
+// consumes none.
+func (c *Claude) EndRequest(entry *RequestLog) {
+	entry.Latency = time.Since(entry.start)
+	c.logRequest(entry)
+
+	attrs := []instrument.Attribute{
+		instrument.String(instrument.ATTR_GEN_AI_SYSTEM, GEN_AI_SYSTEM),
+		instrument.String(instrument.ATTR_GEN_AI_OPERATION_NAME, operationName(entry.URL)),
+		instrument.String(instrument.ATTR_GEN_AI_RESPONSE_MODEL, entry.Model),
+	}
+	if entry.StatusCode != 0 {
+		attrs = append(attrs, instrument.Int(instrument.ATTR_HTTP_STATUS_CODE, entry.StatusCode))
+	}
+	if entry.Err != nil {
+		attrs = append(attrs, instrument.String(instrument.ATTR_ERROR_TYPE, errorType(entry.Err)))
+	}
+
+	spanAttrs := append([]instrument.Attribute{}, attrs...)
+	spanAttrs = append(spanAttrs,
+		instrument.Int(instrument.ATTR_GEN_AI_USAGE_INPUT_TOKENS, entry.InputTokens),
+		instrument.Int(instrument.ATTR_GEN_AI_USAGE_OUTPUT_TOKENS, entry.OutputTokens),
+		instrument.Int("gen_ai.request.retries", entry.Retries))
+	if entry.StopReason != "" {
+		spanAttrs = append(spanAttrs, instrument.Strings(instrument.ATTR_GEN_AI_FINISH_REASONS, entry.StopReason))
+	}
+	if entry.RequestId != "" {
+		spanAttrs = append(spanAttrs, instrument.String("anthropic.request_id", entry.RequestId))
+	}
+	if entry.CacheStatus != "" {
+		spanAttrs = append(spanAttrs, instrument.String("ami.cache", entry.CacheStatus))
+	}
+
+	entry.span.SetAttributes(spanAttrs...)
+	if entry.Err != nil {
+		entry.span.RecordError(entry.Err)
+	}
+	entry.span.End()
+
+	ctx := entry.ctx
+	c.instrumentation.Add(ctx, instrument.METRIC_REQUESTS, 1, attrs...)
+	c.instrumentation.Record(ctx, instrument.METRIC_OPERATION_DURATION, entry.Latency.Seconds(), attrs...)
+	if operationName(entry.URL) != "chat" {
+		return
+	}
+	if entry.InputTokens > 0 {
+		c.instrumentation.Record(ctx, instrument.METRIC_TOKEN_USAGE, float64(entry.InputTokens),
+			append(attrs, instrument.String(instrument.ATTR_GEN_AI_TOKEN_TYPE, "input"))...)
+	}
+	if entry.OutputTokens > 0 {
+		c.instrumentation.Record(ctx, instrument.METRIC_TOKEN_USAGE, float64(entry.OutputTokens),
+			append(attrs, instrument.String(instrument.ATTR_GEN_AI_TOKEN_TYPE, "output"))...)
+	}
+}
+
+// operationName returns the gen_ai.operation.name of an endpoint.
+func operationName(url string) string {
+	if strings.HasSuffix(url, "/count_tokens") {
+		return "count_tokens"
+	}
+	return "chat"
+}
+
+// errorType returns the error.type attribute of an error: the API error type for HTTP
+// errors, otherwise the Go type.
+func errorType(err error) string {
+	var httpErr *ErrHTTP
+	if errors.As(err, &httpErr) {
+		if errorAPIText, ok := apiErrors[httpErr.StatusCode]; ok {
+			errorType, _, _ := strings.Cut(errorAPIText, ":")
+			return errorType
+		}
+		return "http_error"
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", err), "*")
+}
@@ -0,0 +1,221 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/rmrfslashbin/ami/instrument"
+)
+
+// REDACTED replaces secrets and media payloads in logs.
+const REDACTED = "[redacted]"
+
+// REDACT_HEADERS are the headers whose values are never logged.
+var REDACT_HEADERS = []string{"x-api-key", "authorization"}
+
+// RequestLog is the outcome of a single API call, logged as one structured event.
+type RequestLog struct {
+	// URL is the endpoint called.
+	URL string
+
+	// Model is the model requested or, once known, the model that answered.
+	Model string
+
+	// Stream reports a streaming request.
+	Stream bool
+
+	// StatusCode is the HTTP status of the last attempt.
+	StatusCode int
+
+	// RequestId is the request-id header of the response.
+	RequestId string
+
+	// Latency is the time from the first attempt to the end of the response.
+	Latency time.Duration
+
+	// Retries is the number of attempts beyond the first.
+	Retries int
+
+	// CacheStatus is the response cache status, if a cache is configured.
+	CacheStatus string
+
+	// InputTokens is the number of input tokens used, if known.
+	InputTokens int
+
+	// OutputTokens is the number of output tokens generated, if known.
+	OutputTokens int
+
+	// StopReason is the reason the model stopped, if known.
+	StopReason string
+
+	// Err is the error the call failed with, if any.
+	Err error
+
+	start time.Time
+	ctx   context.Context
+	span  instrument.Span
+}
+
+// WithDebugBodies logs request and response bodies at debug level. API keys and media
+// payloads are redacted. It is off by default.
+func WithDebugBodies(enabled bool) Option {
+	return func(config *Claude) {
+		config.debugBodies = enabled
+	}
+}
+
+// discardLogger returns a logger that drops every record; the default when no logger is set.
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// GetLogger returns the client logger. It is never nil.
+func (c *Claude) GetLogger() *slog.Logger {
+	return c.log
+}
+
+// logRequest logs the end of an API call: at info level on success, warn for client and
+// rate limit errors, and error otherwise.
+func (c *Claude) logRequest(entry *RequestLog) {
+	attrs := []slog.Attr{
+		slog.String("url", entry.URL),
+		slog.String("model", entry.Model),
+		slog.Bool("stream", entry.Stream),
+		slog.Int("status", entry.StatusCode),
+		slog.Duration("latency", entry.Latency),
+		slog.Int("retries", entry.Retries),
+	}
+	if entry.RequestId != "" {
+		attrs = append(attrs, slog.String("request_id", entry.RequestId))
+	}
+	if entry.CacheStatus != "" {
+		attrs = append(attrs, slog.String("cache", entry.CacheStatus))
+	}
+	if entry.StopReason != "" {
+		attrs = append(attrs, slog.String("stop_reason", entry.StopReason))
+	}
+	if entry.InputTokens > 0 || entry.OutputTokens > 0 {
+		attrs = append(attrs, slog.Group("usage",
+			slog.Int("input_tokens", entry.InputTokens),
+			slog.Int("output_tokens", entry.OutputTokens)))
+	}
+
+	level := slog.LevelInfo
+	message := "api request completed"
+	if entry.Err != nil {
+		attrs = append(attrs, slog.String("error", entry.Err.Error()))
+		message = "api request failed"
+		level = slog.LevelError
+		if entry.StatusCode >= 400 && entry.StatusCode < 500 {
+			level = slog.LevelWarn
+		}
+	}
+
+	c.log.LogAttrs(context.Background(), level, message, attrs...)
+}
+
+// LogBody logs a request or response body at debug level if WithDebugBodies is set.
+func (c *Claude) LogBody(message string, body []byte) {
+	if !c.debugBodies {
+		return
+	}
+	c.log.LogAttrs(context.Background(), slog.LevelDebug, message,
+		slog.String("body", string(RedactBody(body))))
+}
+
+// LogRequestBody logs a request body and the client headers, with secrets redacted, at
+// debug level if WithDebugBodies is set.
+func (c *Claude) LogRequestBody(body []byte) {
+	if !c.debugBodies {
+		return
+	}
+	c.log.LogAttrs(context.Background(), slog.LevelDebug, "api request body",
+		slog.Any("headers", RedactHeaders(c.headers)),
+		slog.String("body", string(RedactBody(body))))
+}
+
+// RedactBody replaces base64 media payloads in a JSON body with their size. Bodies that
+// are not JSON are replaced entirely.
+func RedactBody(body []byte) []byte {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return []byte(fmt.Sprintf("%s %d bytes", REDACTED, len(body)))
+	}
+	redacted, err := json.Marshal(redact(v))
+	if err != nil {
+		return []byte(fmt.Sprintf("%s %d bytes", REDACTED, len(body)))
+	}
+	return redacted
+}
+
+// redact walks a decoded JSON value, replacing the data of base64 sources.
+func redact(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if data, ok := child.(string); ok && key == "data" && value["type"] == "base64" {
+				value[key] = fmt.Sprintf("%s %d bytes", REDACTED, len(data))
+				continue
+			}
+			value[key] = redact(child)
+		}
+		return value
+	case []interface{}:
+		for i, child := range value {
+			value[i] = redact(child)
+		}
+		return value
+	default:
+		return v
+	}
+}
+
+// RedactHeaders copies headers, replacing the values of REDACT_HEADERS.
+func RedactHeaders(headers map[string]string) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for key, value := range headers {
+		redacted[key] = value
+		for _, secret := range REDACT_HEADERS {
+			if strings.EqualFold(key, secret) {
+				redacted[key] = REDACTED
+			}
+		}
+	}
+	return redacted
+}
+
+// requestSummary extracts the model and stream flag of a request body for logging.
+func requestSummary(jsonData []byte) (string, bool) {
+	var request struct {
+		Model  string `json:"model"`
+		Stream bool   `json:"stream"`
+	}
+	json.Unmarshal(jsonData, &request)
+	return request.Model, request.Stream
+}
+
+// responseSummary fills in the model, stop reason and token usage of a response body.
+func responseSummary(entry *RequestLog, body []byte) {
+	var response struct {
+		Model      string `json:"model"`
+		StopReason string `json:"stop_reason"`
+		Usage      struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+		InputTokens int `json:"input_tokens"`
+	}
+	json.Unmarshal(body, &response)
+
+	if response.Model != "" {
+		entry.Model = response.Model
+	}
+	entry.StopReason = response.StopReason
+	entry.InputTokens = response.Usage.InputTokens + response.InputTokens
+	entry.OutputTokens = response.Usage.OutputTokens
+}
@@ -0,0 +1,33 @@
+package claude
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestErrHTTPError(t *testing.T) {
+	err := &ErrHTTP{StatusCode: 429, URL: TEST_URL, RequestId: "req_1"}
+	first := err.Error()
+	if second := err.Error(); second != first || err.Msg != "" {
+		t.Errorf("Error changed from %q to %q, Msg = %q", first, second, err.Msg)
+	}
+	if !strings.HasPrefix(first, "HTTP error: 429") || !strings.Contains(first, "(request id req_1)") {
+		t.Errorf("Error = %q", first)
+	}
+}
+
+func TestLogRequestBody(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c, err := New(WithAPIKey("sk-ant-secret"), WithLogger(log), WithDebugBodies(true))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	c.LogRequestBody([]byte(`{"model":"m"}`))
+	if out := buf.String(); !strings.Contains(out, "api request body") || !strings.Contains(out, "headers=") || strings.Contains(out, "sk-ant-secret") {
+		t.Errorf("log = %s, want redacted headers", out)
+	}
+}
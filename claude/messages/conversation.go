@@ -0,0 +1,171 @@
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Validate checks the structural invariants the Messages API enforces on a conversation:
+// it must start with a user turn, roles must alternate, every tool_use must be answered by
+// a tool_result in the next turn, and text blocks must not be empty. All violations are
+// returned, joined, as *ErrInvalidConversation values naming the offending message.
+func (c *Conversation) Validate() error {
+	if len(c.Messages) == 0 {
+		return &ErrInvalidConversation{Index: -1, Err: errors.New("no messages")}
+	}
+
+	violations := []error{}
+	violation := func(index int, format string, args ...interface{}) {
+		violations = append(violations, &ErrInvalidConversation{Index: index, Err: fmt.Errorf(format, args...)})
+	}
+
+	for i, message := range c.Messages {
+		if message == nil {
+			violation(i, "message is nil")
+			continue
+		}
+
+		if i == 0 && message.Role != "user" {
+			violation(0, "first message must use the user role, not %q", message.Role)
+		}
+
+		if message.Role != "user" && message.Role != "assistant" {
+			violation(i, "invalid role %q", message.Role)
+		}
+
+		if i > 0 && c.Messages[i-1] != nil && c.Messages[i-1].Role == message.Role {
+			violation(i, "consecutive %s messages; roles must alternate", message.Role)
+		}
+
+		if len(message.MessageContent) == 0 {
+			violation(i, "message has no content")
+		}
+
+		for j, content := range message.MessageContent {
+			if content == nil {
+				violation(i, "content block %d is nil", j)
+				continue
+			}
+			if content.Type == "text" && strings.TrimSpace(content.Text) == "" {
+				violation(i, "content block %d is an empty text block", j)
+			}
+		}
+
+		if message.Role != "assistant" {
+			continue
+		}
+
+		// every tool_use must be answered in the following user turn
+		var results map[string]struct{}
+		if i+1 < len(c.Messages) && c.Messages[i+1] != nil {
+			results = toolResultIds(c.Messages[i+1])
+		}
+		for _, id := range toolUseIds(message) {
+			if _, ok := results[id]; !ok {
+				violation(i, "tool_use %s has no matching tool_result in the next message", id)
+			}
+		}
+	}
+
+	// every tool_result must answer a tool_use in the previous assistant turn
+	for i := 1; i < len(c.Messages); i++ {
+		if c.Messages[i] == nil || c.Messages[i-1] == nil {
+			continue
+		}
+		uses := map[string]struct{}{}
+		for _, id := range toolUseIds(c.Messages[i-1]) {
+			uses[id] = struct{}{}
+		}
+		for id := range toolResultIds(c.Messages[i]) {
+			if _, ok := uses[id]; !ok {
+				violation(i, "tool_result %s does not match a tool_use in the previous message", id)
+			}
+		}
+	}
+	if results := toolResultIds(c.Messages[0]); c.Messages[0] != nil && len(results) > 0 {
+		violation(0, "tool_result in the first message has no matching tool_use")
+	}
+
+	return errors.Join(violations...)
+}
+
+// Repair merges consecutive turns of the same role and drops empty text blocks and the
+// messages left without content. It does not invent missing tool results.
+func (c *Conversation) Repair() {
+	repaired := make([]*Message, 0, len(c.Messages))
+
+	for _, message := range c.Messages {
+		if message == nil {
+			continue
+		}
+
+		content := make([]*Content, 0, len(message.MessageContent))
+		for _, block := range message.MessageContent {
+			if block == nil || (block.Type == "text" && strings.TrimSpace(block.Text) == "") {
+				continue
+			}
+			content = append(content, block)
+		}
+		if len(content) == 0 {
+			continue
+		}
+		message.MessageContent = content
+
+		n := len(repaired)
+		if n == 0 || repaired[n-1].Role != message.Role {
+			repaired = append(repaired, message)
+			continue
+		}
+
+		previous := repaired[n-1]
+		previous.MessageContent = append(previous.MessageContent, message.MessageContent...)
+		if previous.Usage == nil {
+			previous.Usage = message.Usage
+		} else if message.Usage != nil {
+			previous.Usage.InputTokens += message.Usage.InputTokens
+			previous.Usage.OutputTokens += message.Usage.OutputTokens
+		}
+
+		// the API requires tool_result blocks to come first in a user turn
+		if previous.Role == "user" {
+			results := []*Content{}
+			others := []*Content{}
+			for _, block := range previous.MessageContent {
+				if block.Type == "tool_result" {
+					results = append(results, block)
+				} else {
+					others = append(others, block)
+				}
+			}
+			previous.MessageContent = append(results, others...)
+		}
+	}
+
+	c.Messages = repaired
+}
+
+// toolUseIds returns the ids of the tool_use blocks in a message.
+func toolUseIds(message *Message) []string {
+	ids := []string{}
+	for _, content := range message.MessageContent {
+		if content != nil && content.Type == "tool_use" {
+			ids = append(ids, content.Id)
+		}
+	}
+	return ids
+}
+
+// toolResultIds returns the set of tool_use ids answered by a message.
+func toolResultIds(message *Message) map[string]struct{} {
+	ids := map[string]struct{}{}
+	if message == nil {
+		return ids
+	}
+	for _, content := range message.MessageContent {
+		if content != nil && content.Type == "tool_result" {
+			ids[content.ToolUseId] = struct{}{}
+		}
+	}
+	return ids
+}
@@ -0,0 +1,135 @@
+package messages_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/ami/claude/messages"
+)
+
+func text(role string, texts ...string) *messages.Message {
+	message := &messages.Message{Role: role}
+	for _, t := range texts {
+		message.MessageContent = append(message.MessageContent, &messages.Content{Type: "text", Text: t})
+	}
+	return message
+}
+
+func toolUse(ids ...string) *messages.Message {
+	message := &messages.Message{Role: "assistant"}
+	for _, id := range ids {
+		message.MessageContent = append(message.MessageContent, &messages.Content{Type: "tool_use", Id: id, Name: "f"})
+	}
+	return message
+}
+
+func toolResult(ids ...string) *messages.Message {
+	message := &messages.Message{Role: "user"}
+	for _, id := range ids {
+		message.MessageContent = append(message.MessageContent, &messages.Content{Type: "tool_result", ToolUseId: id, Content: "ok"})
+	}
+	return message
+}
+
+// violations returns the "index: reason" strings of the joined ErrInvalidConversation errors.
+func violations(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	found := []string{}
+	for _, e := range errs {
+		var invalid *messages.ErrInvalidConversation
+		if !errors.As(e, &invalid) {
+			t.Fatalf("got %T %v, want ErrInvalidConversation", e, e)
+		}
+		found = append(found, fmt.Sprintf("%d: %s", invalid.Index, invalid.Err))
+	}
+	return found
+}
+
+func TestConversationValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []*messages.Message
+		want     []string
+	}{
+		{name: "valid", messages: []*messages.Message{text("user", "hi"), text("assistant", "hello")}},
+		{name: "valid tool round trip", messages: []*messages.Message{text("user", "hi"), toolUse("a", "b"), toolResult("b", "a"), text("assistant", "done")}},
+		{name: "no messages", want: []string{"-1: no messages"}},
+		{name: "assistant first", messages: []*messages.Message{text("assistant", "hello")},
+			want: []string{`0: first message must use the user role, not "assistant"`}},
+		{name: "invalid role", messages: []*messages.Message{text("user", "hi"), text("system", "x")},
+			want: []string{`1: invalid role "system"`}},
+		{name: "consecutive roles", messages: []*messages.Message{text("user", "hi"), text("user", "again")},
+			want: []string{"1: consecutive user messages; roles must alternate"}},
+		{name: "no content", messages: []*messages.Message{text("user")},
+			want: []string{"0: message has no content"}},
+		{name: "empty text and nil block", messages: []*messages.Message{{Role: "user", MessageContent: []*messages.Content{{Type: "text", Text: " "}, nil}}},
+			want: []string{"0: content block 0 is an empty text block", "0: content block 1 is nil"}},
+		{name: "nil message", messages: []*messages.Message{text("user", "hi"), nil},
+			want: []string{"1: message is nil"}},
+		{name: "nil first message", messages: []*messages.Message{nil, text("assistant", "hello")},
+			want: []string{"0: message is nil"}},
+		{name: "unanswered tool_use", messages: []*messages.Message{text("user", "hi"), toolUse("a"), text("user", "no result")},
+			want: []string{"1: tool_use a has no matching tool_result in the next message"}},
+		{name: "trailing tool_use", messages: []*messages.Message{text("user", "hi"), toolUse("a")},
+			want: []string{"1: tool_use a has no matching tool_result in the next message"}},
+		{name: "unmatched tool_result", messages: []*messages.Message{text("user", "hi"), toolUse("a"), toolResult("a", "b")},
+			want: []string{"2: tool_result b does not match a tool_use in the previous message"}},
+		{name: "tool_result first", messages: []*messages.Message{toolResult("a")},
+			want: []string{"0: tool_result in the first message has no matching tool_use"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conversation := &messages.Conversation{Messages: tt.messages}
+			if got := violations(t, conversation.Validate()); !slices.Equal(got, tt.want) {
+				t.Errorf("violations =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestConversationRepair(t *testing.T) {
+	conversation := &messages.Conversation{Messages: []*messages.Message{
+		nil,
+		text("user", "hi", " "),
+		text("assistant"),
+		text("user", "again"),
+		toolUse("a"),
+		{Role: "user", MessageContent: []*messages.Content{{Type: "text", Text: "note"}, nil}},
+		toolResult("a"),
+	}}
+	conversation.Messages[4].Usage = &messages.Usage{InputTokens: 1, OutputTokens: 2}
+
+	conversation.Repair()
+
+	got := []string{}
+	for _, message := range conversation.Messages {
+		blocks := []string{}
+		for _, content := range message.MessageContent {
+			blocks = append(blocks, content.Type+":"+content.Text+content.Id+content.ToolUseId)
+		}
+		got = append(got, message.Role+" "+strings.Join(blocks, ","))
+	}
+	want := []string{
+		"user text:hi,text:again",
+		"assistant tool_use:a",
+		// tool results are moved before the other blocks of the merged turn
+		"user tool_result:a,text:note",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Repair =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if err := conversation.Validate(); err != nil {
+		t.Errorf("Validate after Repair: %v", err)
+	}
+}
@@ -1,6 +1,10 @@
 package messages
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/rmrfslashbin/ami/validate"
+)
 
 type ErrMissingClaude struct {
 	Err error
@@ -254,12 +258,117 @@ func (e *ErrToolUseNotSupported) Error() string {
 	return e.Msg
 }
 
-type ValidationError struct {
-	Field   string
-	Message string
-	Err     error
+// ValidationError is a single validation violation.
+type ValidationError = validate.ValidationError
+
+type ErrInvalidConversation struct {
+	Err   error
+	Msg   string
+	Index int
+}
+
+func (e *ErrInvalidConversation) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid conversation"
+	}
+	if e.Index >= 0 {
+		msg += fmt.Sprintf(" at message %d", e.Index)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidParameter struct {
+	Err       error
+	Msg       string
+	Parameter string
+	Value     interface{}
+}
+
+func (e *ErrInvalidParameter) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid parameter"
+	}
+	if e.Parameter != "" {
+		msg += " " + e.Parameter
+	}
+	if e.Value != nil {
+		msg += fmt.Sprintf(" (%v)", e.Value)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrUnknownPreset struct {
+	Err  error
+	Msg  string
+	Name string
+}
+
+func (e *ErrUnknownPreset) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "unknown preset"
+	}
+	if e.Name != "" {
+		msg += " " + e.Name
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrDecodingImage struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrDecodingImage) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "error decoding image"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrEncodingImage struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrEncodingImage) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "error encoding image"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
 }
 
-func (e *ValidationError) Error() string {
-	return e.Field + " " + e.Message
+type ErrImageTooLarge struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrImageTooLarge) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("image too large- must be at most %d bytes base64 encoded and %dx%d pixels", MAX_IMAGE_BYTES, MAX_IMAGE_DIMENSION, MAX_IMAGE_DIMENSION)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
 }
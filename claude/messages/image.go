@@ -0,0 +1,221 @@
+package messages
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// MAX_IMAGE_BYTES is the largest image the API accepts, measured base64 encoded.
+const MAX_IMAGE_BYTES = 5 * 1024 * 1024
+
+// MAX_IMAGE_DIMENSION is the largest width or height, in pixels, the API accepts.
+const MAX_IMAGE_DIMENSION = 8000
+
+// MAX_IMAGE_PIXELS is the largest image, in pixels, that is decoded to be downscaled.
+const MAX_IMAGE_PIXELS = 50_000_000
+
+// JPEG_QUALITY is the quality used when an image is re-encoded as JPEG.
+const JPEG_QUALITY = 85
+
+// Text returns a text content block.
+func Text(text string) *Content {
+	return &Content{Type: "text", Text: text}
+}
+
+// ImageFromFile returns an image content block for the file at fqpn.
+func ImageFromFile(fqpn string) (*Content, error) {
+	data, err := os.ReadFile(fqpn)
+	if err != nil {
+		return nil, &ErrReadingFile{Err: err}
+	}
+	return ImageFromBytes(data)
+}
+
+// ImageFromReader returns an image content block for the data read from r.
+func ImageFromReader(r io.Reader) (*Content, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, &ErrReadingFile{Err: err}
+	}
+	return ImageFromBytes(data)
+}
+
+// ImageFromBytes returns a base64 image content block for data. Images larger than
+// MAX_IMAGE_BYTES once encoded, or than MAX_IMAGE_DIMENSION, are downscaled and re-encoded.
+func ImageFromBytes(data []byte) (*Content, error) {
+	mtype := mimetype.Detect(data)
+	if !slices.Contains(SUPPORTED_MIME_TYPES, mtype.String()) {
+		return nil, &ErrUnsupportedMimeType{MimeType: mtype.String()}
+	}
+	return imageFromBytes(data, mtype.String())
+}
+
+// imageFromBytes is ImageFromBytes for data of a supported, already detected media type.
+func imageFromBytes(data []byte, mediaType string) (*Content, error) {
+	data, mediaType, err := fitImage(data, mediaType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Content{
+		Type: "image",
+		Source: &MediaSource{
+			Type:      "base64",
+			MediaType: mediaType,
+			Data:      base64.StdEncoding.EncodeToString(data),
+		},
+	}, nil
+}
+
+// ImageFromURL returns an image content block that the API fetches from url.
+func ImageFromURL(url string) *Content {
+	return &Content{
+		Type: "image",
+		Source: &MediaSource{
+			Type: "url",
+			URL:  url,
+		},
+	}
+}
+
+// fitImage downscales and re-encodes an image that exceeds the API limits. Images within
+// the limits are returned unchanged.
+func fitImage(data []byte, mediaType string) ([]byte, string, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		// webp has no decoder in the standard library, so it cannot be downscaled; accept
+		// it if it is small enough
+		if mediaType == "image/webp" {
+			if fits(len(data)) {
+				return data, mediaType, nil
+			}
+			return nil, "", &ErrImageTooLarge{Err: errors.New("webp images cannot be downscaled")}
+		}
+		return nil, "", &ErrDecodingImage{Err: err}
+	}
+
+	if fits(len(data)) && config.Width <= MAX_IMAGE_DIMENSION && config.Height <= MAX_IMAGE_DIMENSION {
+		return data, mediaType, nil
+	}
+
+	// refuse to decode images that would take too much memory
+	if pixels := int64(config.Width) * int64(config.Height); pixels > MAX_IMAGE_PIXELS {
+		return nil, "", &ErrImageTooLarge{Err: fmt.Errorf("%dx%d is more than %d pixels", config.Width, config.Height, MAX_IMAGE_PIXELS)}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", &ErrDecodingImage{Err: err}
+	}
+
+	// scale so the longest edge is within limits
+	width, height := config.Width, config.Height
+	if longest := max(width, height); longest > MAX_IMAGE_DIMENSION {
+		width = max(width*MAX_IMAGE_DIMENSION/longest, 1)
+		height = max(height*MAX_IMAGE_DIMENSION/longest, 1)
+	}
+
+	// keep png (and gif, as png) when it fits, otherwise fall back to jpeg and shrink
+	// until the encoded image is small enough
+	for {
+		resized := img
+		if width != config.Width || height != config.Height {
+			resized = downscale(img, width, height)
+		}
+
+		if mediaType != "image/jpeg" {
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, resized); err != nil {
+				return nil, "", &ErrEncodingImage{Err: err}
+			}
+			if fits(buf.Len()) {
+				return buf.Bytes(), "image/png", nil
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, flatten(resized), &jpeg.Options{Quality: JPEG_QUALITY}); err != nil {
+			return nil, "", &ErrEncodingImage{Err: err}
+		}
+		if fits(buf.Len()) {
+			return buf.Bytes(), "image/jpeg", nil
+		}
+
+		if width <= 1 || height <= 1 {
+			return nil, "", &ErrImageTooLarge{}
+		}
+		width = max(width*3/4, 1)
+		height = max(height*3/4, 1)
+	}
+}
+
+// downscale resizes img to width x height by averaging the source pixels covered by each
+// destination pixel.
+func downscale(img image.Image, width int, height int) *image.RGBA {
+	bounds := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	srcWidth, srcHeight := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := y * srcHeight / height
+		y1 := max((y+1)*srcHeight/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := x * srcWidth / width
+			x1 := max((x+1)*srcWidth/width, x0+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					b += uint64(p[2])
+					a += uint64(p[3])
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+// flatten composites img onto a white background; jpeg has no alpha channel.
+func flatten(img image.Image) image.Image {
+	if _, ok := img.(*image.YCbCr); ok {
+		return img
+	}
+	bounds := img.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Over)
+	return dst
+}
+
+// fits reports whether an image of n bytes is within MAX_IMAGE_BYTES once base64 encoded.
+func fits(n int) bool {
+	return base64.StdEncoding.EncodedLen(n) <= MAX_IMAGE_BYTES
+}
@@ -0,0 +1,119 @@
+package messages_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rmrfslashbin/ami/claude/messages"
+)
+
+// WEBP is a 1x1 lossless WebP.
+const WEBP = "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA=="
+
+// noise returns a PNG of random pixels, which barely compresses.
+func noise(t *testing.T, width int, height int) []byte {
+	t.Helper()
+	random := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(random.Intn(256)), G: uint8(random.Intn(256)), B: uint8(random.Intn(256)), A: uint8(random.Intn(256))})
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatalf("encoding PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestImageFromBytes(t *testing.T) {
+	data := noise(t, 8, 8)
+	content, err := messages.ImageFromBytes(data)
+	if err != nil {
+		t.Fatalf("ImageFromBytes: %v", err)
+	}
+	if content.Source.MediaType != "image/png" || content.Source.Data != base64.StdEncoding.EncodeToString(data) {
+		t.Errorf("ImageFromBytes changed an image within the limits: %s", content.Source.MediaType)
+	}
+}
+
+func TestImageFromBytesBase64Limit(t *testing.T) {
+	// under the limit raw, over it base64 encoded
+	data := noise(t, 1050, 1050)
+	if len(data) > messages.MAX_IMAGE_BYTES || base64.StdEncoding.EncodedLen(len(data)) <= messages.MAX_IMAGE_BYTES {
+		t.Fatalf("fixture is %d bytes, want it to exceed the limit only once encoded", len(data))
+	}
+
+	content, err := messages.ImageFromBytes(data)
+	if err != nil {
+		t.Fatalf("ImageFromBytes: %v", err)
+	}
+	if n := len(content.Source.Data); n > messages.MAX_IMAGE_BYTES {
+		t.Errorf("encoded image is %d bytes, want at most %d", n, messages.MAX_IMAGE_BYTES)
+	}
+}
+
+func TestImageFromBytesWebp(t *testing.T) {
+	small, _ := base64.StdEncoding.DecodeString(WEBP)
+	if content, err := messages.ImageFromBytes(small); err != nil || content.Source.MediaType != "image/webp" {
+		t.Errorf("ImageFromBytes(small webp) = %v, %v", content, err)
+	}
+
+	// webp cannot be decoded, so a large one cannot be downscaled
+	large := append(bytes.Clone(small), make([]byte, messages.MAX_IMAGE_BYTES)...)
+	var tooLarge *messages.ErrImageTooLarge
+	if _, err := messages.ImageFromBytes(large); !errors.As(err, &tooLarge) {
+		t.Errorf("ImageFromBytes(large webp) = %v, want ErrImageTooLarge", err)
+	}
+}
+
+func TestImageFromBytesPixelLimit(t *testing.T) {
+	// a GIF header claiming 10000x10000 pixels, without the pixels
+	data := []byte("GIF89a\x10\x27\x10\x27\x00\x00\x00\x3b")
+
+	var tooLarge *messages.ErrImageTooLarge
+	if _, err := messages.ImageFromBytes(data); !errors.As(err, &tooLarge) {
+		t.Errorf("ImageFromBytes = %v, want ErrImageTooLarge", err)
+	}
+}
+
+func TestAddRoleUserMedia(t *testing.T) {
+	m, err := newMessages(t)
+	if err != nil {
+		t.Fatalf("messages.New: %v", err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "noise.png")
+	if err := os.WriteFile(path, noise(t, 4, 4), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := m.AddRoleUserMedia(path, "What is this?"); err != nil {
+		t.Fatalf("AddRoleUserMedia: %v", err)
+	}
+	content := m.GetConversation().Messages[0].MessageContent
+	if len(content) != 2 || content[0].Source.MediaType != "image/png" || content[1].Text != "What is this?" {
+		t.Errorf("content = %+v", content)
+	}
+
+	text := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(text, []byte("not an image"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	var unsupported *messages.ErrUnsupportedMimeType
+	if err := m.AddRoleUserMedia(text, "What is this?"); !errors.As(err, &unsupported) {
+		t.Errorf("AddRoleUserMedia with text = %v, want ErrUnsupportedMimeType", err)
+	}
+	var fetching *messages.ErrFetchingMimeType
+	if err := m.AddRoleUserMedia(filepath.Join(dir, "missing.png"), "What is this?"); !errors.As(err, &fetching) {
+		t.Errorf("AddRoleUserMedia with a missing file = %v, want ErrFetchingMimeType", err)
+	}
+}
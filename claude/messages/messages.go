@@ -3,10 +3,11 @@ package messages
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/gob"
 	"encoding/json"
 	"errors"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -15,11 +16,21 @@ import (
 
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/rmrfslashbin/ami/claude"
+	"github.com/rmrfslashbin/ami/validate"
 	"github.com/tmaxmax/go-sse"
 )
 
+// MODULE_NAME is the module name
+const MODULE_NAME = "messages"
+
+// PATH is the path of the Messages API.
+const PATH = "/v1/messages"
+
+// COUNT_TOKENS_PATH is the path of the token counting API.
+const COUNT_TOKENS_PATH = PATH + "/count_tokens"
+
 // URL is the URL for the Messages API.
-const URL = claude.URL + "/v1/messages"
+const URL = claude.URL + PATH
 
 // Slice of supported mime types.
 var SUPPORTED_MIME_TYPES = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
@@ -32,15 +43,31 @@ var StopReasons = map[string]string{
 	"tool_use":      "the model requests use of a tool",
 }
 
+func init() {
+	validate.RegisterEnum("claude.media_types", SUPPORTED_MIME_TYPES)
+
+	// tool_use inputs are decoded from JSON into these types; gob needs them registered
+	// to encode Content.Input.
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
 // Option is a configuration option.
 type Option func(config *Messages)
 
 // Messages is the messages configuration.
 type Messages struct {
+	log              *slog.Logger
 	claud            *claude.Claude
 	conversation     *Conversation
 	conversationFqpn *string
 	url              string
+	autoRepair       bool
+	optionErrs       []error
+	maxTokens        *int
+	budgetConfig     *claude.Budget
+	budget           *claude.BudgetTracker
+	budgetCount      bool
 
 	request Request
 }
@@ -54,7 +81,6 @@ func New(opts ...func(*Messages)) (*Messages, error) {
 	now := time.Now()
 	config.conversation.Created = now
 	config.conversation.Updated = now
-	config.url = URL
 
 	// apply the list of options to Config
 	for _, opt := range opts {
@@ -65,10 +91,35 @@ func New(opts ...func(*Messages)) (*Messages, error) {
 		return nil, &ErrMissingClaude{}
 	}
 
+	if config.log == nil {
+		config.log = config.claud.GetLogger()
+	}
+
+	if len(config.optionErrs) > 0 {
+		return nil, errors.Join(config.optionErrs...)
+	}
+
 	if config.request.Model == "" {
 		return nil, &ErrMissingModel{}
 	}
 
+	// max tokens is checked once the model, and so its limit, is known
+	if config.maxTokens != nil {
+		if err := config.SetMaxTokens(*config.maxTokens); err != nil {
+			return nil, err
+		}
+	}
+
+	config.url = config.claud.GetBaseURL() + PATH
+
+	if config.budgetConfig != nil {
+		budget, err := claude.NewBudgetTracker(config.budgetConfig)
+		if err != nil {
+			return nil, err
+		}
+		config.budget = budget
+	}
+
 	if config.conversationFqpn != nil {
 		err := config.Load()
 		if err != nil {
@@ -90,6 +141,18 @@ func WithClaude(claud *claude.Claude) Option {
 	}
 }
 
+// WithLogger sets the logger. The default is the logger of the Claude client.
+func WithLogger(log *slog.Logger) Option {
+	return func(config *Messages) {
+		moduleLogger := log.With(
+			slog.Group("module_info",
+				slog.String("module", MODULE_NAME),
+			),
+		)
+		config.log = moduleLogger
+	}
+}
+
 func WithConversationFile(fpqn *string) Option {
 	if fpqn != nil {
 		return func(config *Messages) {
@@ -101,6 +164,30 @@ func WithConversationFile(fpqn *string) Option {
 	}
 }
 
+// WithAutoRepair merges consecutive same-role turns and drops empty text blocks before
+// the conversation is validated and sent.
+func WithAutoRepair() Option {
+	return func(config *Messages) {
+		config.autoRepair = true
+	}
+}
+
+// WithBudget enforces budget on this conversation, in addition to any budget set on the
+// Claude client.
+func WithBudget(budget *claude.Budget) Option {
+	return func(config *Messages) {
+		config.budgetConfig = budget
+	}
+}
+
+// WithBudgetCountTokens counts the input tokens of each request with CountTokens for the
+// budget check, instead of estimating them from the usage of the last reply.
+func WithBudgetCountTokens() Option {
+	return func(config *Messages) {
+		config.budgetCount = true
+	}
+}
+
 func WithOpus() Option {
 	return withModel("opus")
 }
@@ -126,9 +213,11 @@ func withModel(model string) Option {
 	}
 }
 
+// WithMaxTokens sets the maximum number of tokens to generate, at most the model's
+// MaxOutputTokens. It is checked by New, after the model is set.
 func WithMaxTokens(n int) Option {
 	return func(config *Messages) {
-		config.request.MaxTokens = n
+		config.maxTokens = &n
 	}
 }
 
@@ -138,17 +227,36 @@ func (messages *Messages) SetStreaming(stream bool) {
 
 // UserId sets the user id.
 func (messages *Messages) SetUserId(id string) {
+	if messages.request.Metadata == nil {
+		messages.request.Metadata = &Metadata{}
+	}
 	messages.request.Metadata.UserId = id
 }
 
+// SetMaxTokens sets the maximum number of tokens to generate, 1 to the model's
+// MaxOutputTokens.
 func (messages *Messages) SetMaxTokens(n int) error {
-	if n > messages.request.MaxTokens {
-		return &ErrMaxTokensExceeded{Model: messages.request.Model, MaxTokens: messages.request.MaxTokens}
+	if n < 1 {
+		return &ErrInvalidParameter{Parameter: "max_tokens", Value: n, Err: errors.New("must be at least 1")}
+	}
+	if n > messages.request.modelMaxTokens {
+		return &ErrMaxTokensExceeded{Model: messages.request.Model, MaxTokens: messages.request.modelMaxTokens}
 	}
 	messages.request.MaxTokens = n
 	return nil
 }
 
+// SetBudgetCountTokens enables or disables counting input tokens with CountTokens for the
+// budget check.
+func (messages *Messages) SetBudgetCountTokens(count bool) {
+	messages.budgetCount = count
+}
+
+// SetAutoRepair enables or disables conversation auto-repair before sending.
+func (messages *Messages) SetAutoRepair(repair bool) {
+	messages.autoRepair = repair
+}
+
 func (messages *Messages) SetSystemPrompt(p string) {
 	messages.request.System = p
 }
@@ -157,7 +265,8 @@ func (messages *Messages) AddRoleAssistant(content string) {
 	messages.conversation.Messages = append(
 		messages.conversation.Messages,
 		&Message{
-			Role: "assistant",
+			Role:    "assistant",
+			Created: time.Now(),
 			MessageContent: []*Content{
 				{
 					Type: "text",
@@ -177,7 +286,8 @@ func (messages *Messages) AddRoleUser(content string) {
 	messages.conversation.Messages = append(
 		messages.conversation.Messages,
 		&Message{
-			Role: "user",
+			Role:    "user",
+			Created: time.Now(),
 			MessageContent: []*Content{
 				{
 					Type: "text",
@@ -188,49 +298,74 @@ func (messages *Messages) AddRoleUser(content string) {
 	)
 }
 
+// AddRoleUserMedia adds a user turn with the image at fqpn followed by prompt.
 func (messages *Messages) AddRoleUserMedia(fqpn string, prompt string) error {
-	mtype, err := mimetype.DetectFile(fqpn)
+	data, err := os.ReadFile(fqpn)
 	if err != nil {
 		return &ErrFetchingMimeType{Err: err}
 	}
+	mtype := mimetype.Detect(data)
 	if !slices.Contains(SUPPORTED_MIME_TYPES, mtype.String()) {
 		return &ErrUnsupportedMimeType{MimeType: mtype.String()}
 	}
 
-	// Read the file content
-	content, err := os.ReadFile(fqpn)
+	image, err := imageFromBytes(data, mtype.String())
 	if err != nil {
-		return &ErrReadingFile{Err: err}
+		return err
 	}
 
-	// Convert the file content to a base64-encoded string
-	base64Content := base64.StdEncoding.EncodeToString(content)
+	messages.AddRoleUserContent(image, Text(prompt))
+	return nil
+}
 
+// AddRoleUserMediaBytes adds a user turn with an in-memory image followed by prompt.
+func (messages *Messages) AddRoleUserMediaBytes(data []byte, prompt string) error {
+	image, err := ImageFromBytes(data)
+	if err != nil {
+		return err
+	}
+
+	messages.AddRoleUserContent(image, Text(prompt))
+	return nil
+}
+
+// AddRoleUserMediaReader adds a user turn with the image read from r followed by prompt.
+func (messages *Messages) AddRoleUserMediaReader(r io.Reader, prompt string) error {
+	image, err := ImageFromReader(r)
+	if err != nil {
+		return err
+	}
+
+	messages.AddRoleUserContent(image, Text(prompt))
+	return nil
+}
+
+// AddRoleUserMediaURL adds a user turn with an image the API fetches from url, followed by prompt.
+func (messages *Messages) AddRoleUserMediaURL(url string, prompt string) {
+	messages.AddRoleUserContent(ImageFromURL(url), Text(prompt))
+}
+
+// AddRoleUserContent adds a user turn built from content blocks, e.g. several images
+// with text between them:
+//
+//	messages.AddRoleUserContent(Text("Before:"), before, Text("After:"), after, Text("What changed?"))
+func (messages *Messages) AddRoleUserContent(content ...*Content) {
 	messages.conversation.Messages = append(
 		messages.conversation.Messages,
 		&Message{
-			Role: "user",
-			MessageContent: []*Content{
-				{
-					Type: "image",
-					Source: &MediaSource{
-						Type:      "base64",
-						MediaType: mtype.String(),
-						Data:      base64Content,
-					},
-				},
-				{Type: "text", Text: prompt},
-			},
+			Role:           "user",
+			Created:        time.Now(),
+			MessageContent: content,
 		},
 	)
-	return nil
 }
 
 func (messages *Messages) AddRoleUserToolResult(toolUseId string, content string) error {
 	messages.conversation.Messages = append(
 		messages.conversation.Messages,
 		&Message{
-			Role: "user",
+			Role:    "user",
+			Created: time.Now(),
 			MessageContent: []*Content{
 				{
 					Type:      "tool_result",
@@ -278,7 +413,7 @@ type StreamResults struct {
 
 func (messages *Messages) Stream(ctx context.Context) StreamResults {
 	responseCh := make(chan StreamingMessageResponse)
-	errCh := make(chan error)
+	errCh := make(chan error, 1)
 
 	if len(messages.request.Tools) > 0 {
 		errCh <- &ErrToolUseNotSupported{}
@@ -298,9 +433,21 @@ func (messages *Messages) Stream(ctx context.Context) StreamResults {
 		return StreamResults{Response: responseCh, Error: errCh}
 	}
 
+	if err := messages.checkConversation(); err != nil {
+		errCh <- err
+		close(responseCh)
+		return StreamResults{Response: responseCh, Error: errCh}
+	}
+
 	// Load the conversation
 	messages.request.Messages = messages.conversation.Messages
 
+	if err := Validate(&messages.request); err != nil {
+		errCh <- err
+		close(responseCh)
+		return StreamResults{Response: responseCh, Error: errCh}
+	}
+
 	jsonData, err := json.Marshal(messages.request)
 	if err != nil {
 		errCh <- &ErrMarshalingInput{Err: err}
@@ -308,101 +455,250 @@ func (messages *Messages) Stream(ctx context.Context) StreamResults {
 		return StreamResults{Response: responseCh, Error: errCh}
 	}
 
-	go func() {
-		defer close(responseCh)
-
-		//ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-		//defer cancel()
-
-		req, err := http.NewRequestWithContext(ctx, "POST", messages.url, bytes.NewBuffer(jsonData))
-		if err != nil {
+	if body, meta := messages.claud.CachedResponse(messages.url, jsonData); body != nil {
+		var reply Response
+		if err := json.Unmarshal(*body, &reply); err != nil {
+			errCh <- &ErrMarshalingReply{Err: err}
+			close(responseCh)
+			return StreamResults{Response: responseCh, Error: errCh}
+		}
+		reply.Meta = meta
+		entry := messages.claud.StartRequest(ctx, messages.url, messages.request.Model, true)
+		entry.Model = reply.Model
+		entry.StatusCode = http.StatusOK
+		entry.CacheStatus = meta.CacheStatus
+		entry.InputTokens = reply.Usage.InputTokens
+		entry.OutputTokens = reply.Usage.OutputTokens
+		entry.StopReason = reply.StopReason
+		messages.claud.EndRequest(entry)
+		go func() {
+			defer close(responseCh)
+			replay(ctx, &reply, responseCh)
+		}()
+		return StreamResults{Response: responseCh, Error: errCh}
+	} else {
+		// a cached reply is not charged to the budget
+		if err := messages.checkBudget(); err != nil {
 			errCh <- err
-			return
+			close(responseCh)
+			return StreamResults{Response: responseCh, Error: errCh}
 		}
+		go func() {
+			defer close(responseCh)
+			messages.stream(ctx, jsonData, meta, responseCh, errCh)
+		}()
+	}
 
-		// Set headers
-		for key, value := range messages.claud.GetHeaders() {
-			req.Header.Set(key, value)
+	return StreamResults{Response: responseCh, Error: errCh}
+}
+
+// replay sends a cached reply as the events of a streamed response.
+func replay(ctx context.Context, reply *Response, responseCh chan<- StreamingMessageResponse) {
+	send := func(response StreamingMessageResponse) bool {
+		select {
+		case responseCh <- response:
+			return true
+		case <-ctx.Done():
+			return false
 		}
+	}
 
-		conn := sse.DefaultClient.NewConnection(req)
+	start := &StreamingMessageStart{Type: "message_start", Message: *reply}
+	start.Message.Content = []*Content{}
+	start.Message.StopReason = ""
+	start.Message.Usage.OutputTokens = 0
+	if !send(StreamingMessageResponse{MessageStart: start}) {
+		return
+	}
 
-		conn.SubscribeEvent("message_start", func(event sse.Event) {
-			var response StreamingMessageStart
-			err := json.Unmarshal([]byte(event.Data), &response)
-			if err != nil {
-				errCh <- &ErrMarshalingReply{Err: err}
-				return
-			}
-			responseCh <- StreamingMessageResponse{MessageStart: &response}
-		})
-		conn.SubscribeEvent("content_block_delta", func(event sse.Event) {
-			var response StreamingMessageContentBlockDelta
-			err := json.Unmarshal([]byte(event.Data), &response)
-			if err != nil {
-				errCh <- &ErrMarshalingReply{Err: err}
-				return
-			}
-			responseCh <- StreamingMessageResponse{ContentBlock: &response}
-		})
-
-		conn.SubscribeEvent("message_delta", func(event sse.Event) {
-			var response StreamingMessageStop
-			err := json.Unmarshal([]byte(event.Data), &response)
-			if err != nil {
-				errCh <- &ErrMarshalingReply{Err: err}
-				return
-			}
-			responseCh <- StreamingMessageResponse{MessageStop: &response}
-			close(responseCh)
-		})
-
-		conn.SubscribeEvent("error", func(event sse.Event) {
-			var response StreamingMessageError
-			err := json.Unmarshal([]byte(event.Data), &response)
-			if err != nil {
-				errCh <- &ErrMarshalingReply{Err: err}
-				return
-			}
-			responseCh <- StreamingMessageResponse{StreamingError: &response}
-			errCh <- &ErrStreamingMessage{}
-		})
-
-		// noops for now
-		conn.SubscribeEvent("ping", func(event sse.Event) {})
-		conn.SubscribeEvent("content_block_start", func(event sse.Event) {})
-		conn.SubscribeEvent("content_block_stop", func(event sse.Event) {})
-		conn.SubscribeEvent("message_stop", func(event sse.Event) {})
-
-		if err := conn.Connect(); err != nil {
-			/*
-				log.LogAttrs(context.TODO(), slog.LevelError,
-					"error connecting to streaming service",
-					slog.String("error", err.Error()))
-			*/
-			errCh <- err
+	for i, content := range reply.Content {
+		if content.Type != "text" {
+			continue
+		}
+		delta := &StreamingMessageContentBlockDelta{Type: "content_block_delta", Index: i}
+		delta.Delta.Type = "text_delta"
+		delta.Delta.Text = content.Text
+		if !send(StreamingMessageResponse{ContentBlock: delta}) {
 			return
 		}
+	}
+
+	stop := &StreamingMessageStop{Type: "message_delta"}
+	stop.Delta.StopReason = reply.StopReason
+	stop.Delta.StopSequence = reply.StopSequences
+	stop.Usage.OutputTokens = reply.Usage.OutputTokens
+	send(StreamingMessageResponse{MessageStop: stop})
+}
+
+// stream sends a streaming request and delivers its events. A cacheable response is
+// assembled from the events and stored once the message is complete.
+func (messages *Messages) stream(ctx context.Context, jsonData []byte, meta *claude.ResponseMeta, responseCh chan<- StreamingMessageResponse, errCh chan<- error) {
+	// the connection is cancelled once the message is complete; otherwise the SSE
+	// client treats the closed stream as a lost connection and sends the request again
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// only the first error is delivered, and no send blocks once the caller has gone
+	errSent := false
+	sendErr := func(err error) {
+		if errSent {
+			return
+		}
+		errSent = true
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+	}
+	send := func(response StreamingMessageResponse) {
+		select {
+		case responseCh <- response:
+		case <-ctx.Done():
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", messages.url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		sendErr(err)
+		return
+	}
+
+	// Set headers
+	for key, value := range messages.claud.GetHeaders() {
+		req.Header.Set(key, value)
+	}
 
+	entry := messages.claud.StartRequest(ctx, messages.url, messages.request.Model, true)
+	entry.CacheStatus = meta.CacheStatus
+	messages.claud.LogRequestBody(jsonData)
+
+	client := &sse.Client{
+		HTTPClient: messages.claud.GetHTTPClient(),
+		ResponseValidator: func(resp *http.Response) error {
+			entry.StatusCode = resp.StatusCode
+			entry.RequestId = resp.Header.Get("request-id")
+			return claude.ValidateStreamResponse(resp)
+		},
+		Backoff: sse.Backoff{MaxRetries: -1},
+	}
+	conn := client.NewConnection(req)
+
+	// events are delivered sequentially on this goroutine
+	done := false
+	failed := false
+	model := messages.request.Model
+	usage := &Usage{}
+	reply := Response{}
+
+	defer func() {
+		entry.Model = model
+		entry.InputTokens = usage.InputTokens
+		entry.OutputTokens = usage.OutputTokens
+		entry.StopReason = reply.StopReason
+		messages.claud.EndRequest(entry)
 	}()
 
-	return StreamResults{Response: responseCh, Error: errCh}
+	conn.SubscribeEvent("message_start", func(event sse.Event) {
+		var response StreamingMessageStart
+		err := json.Unmarshal([]byte(event.Data), &response)
+		if err != nil {
+			sendErr(&ErrMarshalingReply{Err: err})
+			return
+		}
+		if response.Message.Model != "" {
+			model = response.Message.Model
+		}
+		*usage = response.Message.Usage
+		reply = response.Message
+		reply.Content = nil
+		response.Message.Meta = meta
+		send(StreamingMessageResponse{MessageStart: &response})
+	})
+	conn.SubscribeEvent("content_block_delta", func(event sse.Event) {
+		var response StreamingMessageContentBlockDelta
+		err := json.Unmarshal([]byte(event.Data), &response)
+		if err != nil {
+			sendErr(&ErrMarshalingReply{Err: err})
+			return
+		}
+		for len(reply.Content) <= response.Index {
+			reply.Content = append(reply.Content, &Content{Type: "text"})
+		}
+		reply.Content[response.Index].Text += response.Delta.Text
+		send(StreamingMessageResponse{ContentBlock: &response})
+	})
+
+	conn.SubscribeEvent("message_delta", func(event sse.Event) {
+		var response StreamingMessageStop
+		err := json.Unmarshal([]byte(event.Data), &response)
+		if err != nil {
+			sendErr(&ErrMarshalingReply{Err: err})
+			return
+		}
+		usage.OutputTokens = response.Usage.OutputTokens
+		reply.StopReason = response.Delta.StopReason
+		reply.StopSequences = response.Delta.StopSequence
+		send(StreamingMessageResponse{MessageStop: &response})
+	})
+
+	conn.SubscribeEvent("error", func(event sse.Event) {
+		var response StreamingMessageError
+		err := json.Unmarshal([]byte(event.Data), &response)
+		if err != nil {
+			sendErr(&ErrMarshalingReply{Err: err})
+			return
+		}
+		failed = true
+		send(StreamingMessageResponse{StreamingError: &response})
+		sendErr(&ErrStreamingMessage{})
+	})
+
+	conn.SubscribeEvent("message_stop", func(event sse.Event) {
+		done = true
+		messages.recordUsage(model, usage)
+		if meta.CacheStatus == claude.CACHE_MISS && !failed {
+			reply.Usage = *usage
+			if body, err := json.Marshal(reply); err == nil {
+				messages.claud.CacheResponse(meta, body)
+			}
+		}
+		cancel()
+	})
+
+	// noops for now
+	conn.SubscribeEvent("ping", func(event sse.Event) {})
+	conn.SubscribeEvent("content_block_start", func(event sse.Event) {})
+	conn.SubscribeEvent("content_block_stop", func(event sse.Event) {})
+
+	if err := conn.Connect(); err != nil && !done {
+		entry.Err = err
+		sendErr(err)
+		return
+	}
+	if failed {
+		entry.Err = &ErrStreamingMessage{}
+	}
 }
 
 func (messages *Messages) Send() (*Response, error) {
-	if err := messages.request.Validate(); err != nil {
+	if err := messages.checkConversation(); err != nil {
 		return nil, err
 	}
 
 	// Load the conversation
 	messages.request.Messages = messages.conversation.Messages
 
+	if err := Validate(&messages.request); err != nil {
+		return nil, err
+	}
+
 	jsonData, err := json.Marshal(messages.request)
 	if err != nil {
 		return nil, &ErrMarshalingInput{Err: err}
 	}
 
-	resp, err := messages.claud.Do(messages.url, jsonData)
+	// the budget is checked only if the reply is not cached
+	resp, meta, err := messages.claud.DoChecked(context.Background(), messages.url, jsonData, messages.checkBudget)
 	if err != nil {
 		return nil, err
 	}
@@ -412,10 +708,21 @@ func (messages *Messages) Send() (*Response, error) {
 	if err != nil {
 		return nil, &ErrMarshalingReply{Err: err}
 	}
+	reply.Meta = meta
+
+	// a cached reply costs nothing
+	if meta.CacheStatus != claude.CACHE_HIT {
+		messages.recordUsage(reply.Model, &reply.Usage)
+	}
 
 	messages.conversation.Messages = append(
 		messages.conversation.Messages,
-		&Message{Role: reply.Role, MessageContent: reply.Content},
+		&Message{
+			Role:           reply.Role,
+			MessageContent: reply.Content,
+			Created:        time.Now(),
+			Usage:          &reply.Usage,
+		},
 	)
 
 	// Reset the messages
@@ -424,6 +731,133 @@ func (messages *Messages) Send() (*Response, error) {
 	return &reply, nil
 }
 
+// CountTokens returns the number of input tokens the conversation would use if sent now,
+// without creating a message. It is not billed and does not count towards the budget.
+func (messages *Messages) CountTokens() (int, error) {
+	if err := messages.checkConversation(); err != nil {
+		return 0, err
+	}
+
+	// Load the conversation
+	messages.request.Messages = messages.conversation.Messages
+	defer func() { messages.request.Messages = nil }()
+
+	if err := Validate(&messages.request); err != nil {
+		return 0, err
+	}
+
+	jsonData, err := json.Marshal(&CountTokensRequest{
+		Model:      messages.request.Model,
+		Messages:   messages.request.Messages,
+		System:     messages.request.System,
+		ToolChoice: messages.request.ToolChoice,
+		Tools:      messages.request.Tools,
+	})
+	if err != nil {
+		return 0, &ErrMarshalingInput{Err: err}
+	}
+
+	resp, err := messages.claud.Do(messages.claud.GetBaseURL()+COUNT_TOKENS_PATH, jsonData)
+	if err != nil {
+		return 0, err
+	}
+
+	var reply CountTokensResponse
+	if err := json.Unmarshal(*resp, &reply); err != nil {
+		return 0, &ErrMarshalingReply{Err: err}
+	}
+
+	return reply.InputTokens, nil
+}
+
+// recordUsage adds the usage of a reply to the conversation totals and to the client.
+func (messages *Messages) recordUsage(model string, usage *Usage) {
+	record := &claude.UsageRecord{
+		Model:                    model,
+		InputTokens:              usage.InputTokens,
+		OutputTokens:             usage.OutputTokens,
+		CacheCreationInputTokens: usage.CacheCreationInputTokens,
+		CacheReadInputTokens:     usage.CacheReadInputTokens,
+	}
+	if messages.request.Metadata != nil {
+		record.UserId = messages.request.Metadata.UserId
+	}
+
+	messages.claud.RecordUsage(record)
+	messages.conversation.Spend.Add(record)
+
+	if messages.budget != nil {
+		// the record's cost was filled in by RecordUsage
+		if err := messages.budget.Record(record); err != nil {
+			messages.log.Error("error recording budget spend", slog.String("error", err.Error()))
+		}
+	}
+}
+
+// checkBudget refuses the request if it would exceed the client or conversation budget.
+// The request is counted against neither unless both allow it.
+func (messages *Messages) checkBudget() error {
+	if messages.claud.GetBudget() == nil && messages.budget == nil {
+		return nil
+	}
+
+	request := &claude.BudgetRequest{
+		Model:       messages.request.Model,
+		SpentTokens: messages.conversation.Spend.Tokens(),
+		MaxTokens:   messages.request.MaxTokens,
+		InputTokens: messages.lastInputTokens(),
+	}
+	if messages.request.Metadata != nil {
+		request.UserId = messages.request.Metadata.UserId
+	}
+
+	if messages.budgetCount {
+		// CountTokens unloads the conversation from the request
+		loaded := messages.request.Messages
+		tokens, err := messages.CountTokens()
+		messages.request.Messages = loaded
+		if err != nil {
+			return err
+		}
+		request.InputTokens = tokens
+	}
+
+	return claude.CheckBudgets(request, messages.claud.GetBudget(), messages.budget)
+}
+
+// lastInputTokens estimates the input of the next request from the last reply: its input
+// and output are both sent again. New turns since then are not counted.
+func (messages *Messages) lastInputTokens() int {
+	for i := len(messages.conversation.Messages) - 1; i >= 0; i-- {
+		message := messages.conversation.Messages[i]
+		if message == nil || message.Usage == nil {
+			continue
+		}
+		usage := message.Usage
+		return usage.InputTokens + usage.CacheCreationInputTokens + usage.CacheReadInputTokens + usage.OutputTokens
+	}
+	return 0
+}
+
+// GetSpend returns the running token and dollar totals of the conversation.
+func (messages *Messages) GetSpend() claude.Spend {
+	return messages.conversation.Spend
+}
+
+// checkConversation repairs the conversation if auto-repair is enabled and validates it.
+func (messages *Messages) checkConversation() error {
+	if messages.autoRepair {
+		before := len(messages.conversation.Messages)
+		messages.conversation.Repair()
+		if after := len(messages.conversation.Messages); after != before {
+			messages.log.Debug("conversation repaired",
+				slog.Int("messages_before", before),
+				slog.Int("messages_after", after))
+		}
+	}
+	return messages.conversation.Validate()
+}
+
 func (messages *Messages) Load() error {
 	var err error
 	var fqpn string
@@ -440,19 +874,11 @@ func (messages *Messages) Load() error {
 			return err
 		}
 	} else {
-		// Load the file
-		file, err := os.Open(fqpn)
+		conversation, err := LoadConversation(fqpn)
 		if err != nil {
-			return &ErrOpeningFile{Err: err}
-		}
-		defer file.Close()
-
-		// Decode the GOB data
-		decoder := gob.NewDecoder(file)
-		err = decoder.Decode(messages.conversation)
-		if err != nil {
-			return &ErrLoadingGOB{Err: err}
+			return err
 		}
+		messages.conversation = conversation
 	}
 
 	return nil
@@ -465,19 +891,43 @@ func (messages *Messages) Save() error {
 		return nil
 	}
 
+	now := time.Now()
+	messages.conversation.Updated = now
+
+	return SaveConversation(*messages.conversationFqpn, messages.conversation)
+}
+
+// LoadConversation reads a GOB encoded conversation from fqpn.
+func LoadConversation(fqpn string) (*Conversation, error) {
+	file, err := os.Open(fqpn)
+	if err != nil {
+		return nil, &ErrOpeningFile{Err: err}
+	}
+	defer file.Close()
+
+	// Decode the GOB data
+	conversation := &Conversation{}
+	decoder := gob.NewDecoder(file)
+	err = decoder.Decode(conversation)
+	if err != nil {
+		return nil, &ErrLoadingGOB{Err: err}
+	}
+
+	return conversation, nil
+}
+
+// SaveConversation writes a GOB encoded conversation to fqpn.
+func SaveConversation(fqpn string, conversation *Conversation) error {
 	// Create the file
-	file, err := os.Create(*messages.conversationFqpn)
+	file, err := os.Create(fqpn)
 	if err != nil {
 		return &ErrOpeningFile{Err: err}
 	}
 	defer file.Close()
 
-	now := time.Now()
-	messages.conversation.Updated = now
-
 	// Encode the GOB data
 	encoder := gob.NewEncoder(file)
-	err = encoder.Encode(messages.conversation)
+	err = encoder.Encode(conversation)
 	if err != nil {
 		return &ErrSavingGOB{Err: err}
 	}
@@ -499,6 +949,11 @@ func (messages *Messages) Reset(modelName *string) {
 	messages.conversation.Messages = nil
 }
 
+// SetTitle sets the title of the conversation.
+func (messages *Messages) SetTitle(title string) {
+	messages.conversation.Title = title
+}
+
 // GetModel returns the model name.
 func (messages *Messages) GetModel() string {
 	return messages.request.Model
@@ -0,0 +1,133 @@
+package messages_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rmrfslashbin/ami/claude"
+	"github.com/rmrfslashbin/ami/claude/claudetest"
+	"github.com/rmrfslashbin/ami/claude/messages"
+)
+
+// newMessages returns a haiku Messages client; nothing is sent.
+func newMessages(t *testing.T, opts ...func(*messages.Messages)) (*messages.Messages, error) {
+	t.Helper()
+	c, err := claude.New(claude.WithAPIKey("test"))
+	if err != nil {
+		t.Fatalf("claude.New: %v", err)
+	}
+	return messages.New(append([]func(*messages.Messages){messages.WithClaude(c), messages.WithHaiku()}, opts...)...)
+}
+
+func TestMaxTokens(t *testing.T) {
+	m, err := newMessages(t, messages.WithMaxTokens(100))
+	if err != nil {
+		t.Fatalf("messages.New: %v", err)
+	}
+	if got := m.GetMessageRequest().MaxTokens; got != 100 {
+		t.Errorf("MaxTokens = %d, want 100", got)
+	}
+
+	var invalid *messages.ErrInvalidParameter
+	if err := m.SetMaxTokens(0); !errors.As(err, &invalid) || invalid.Parameter != "max_tokens" {
+		t.Errorf("SetMaxTokens(0) = %v, want ErrInvalidParameter", err)
+	}
+	var exceeded *messages.ErrMaxTokensExceeded
+	if err := m.SetMaxTokens(1 << 30); !errors.As(err, &exceeded) {
+		t.Errorf("SetMaxTokens over the model limit = %v, want ErrMaxTokensExceeded", err)
+	}
+	if got := m.GetMessageRequest().MaxTokens; got != 100 {
+		t.Errorf("MaxTokens after rejected sets = %d, want 100", got)
+	}
+
+	if _, err := newMessages(t, messages.WithMaxTokens(1<<30)); !errors.As(err, &exceeded) {
+		t.Errorf("New with max tokens over the model limit = %v, want ErrMaxTokensExceeded", err)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	server, err := claudetest.New()
+	if err != nil {
+		t.Fatalf("claudetest.New: %v", err)
+	}
+	t.Cleanup(server.Close)
+	c, err := server.Client(claude.WithBudget(&claude.Budget{MaxRequestsPerMinute: 1}))
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	m, err := messages.New(messages.WithClaude(c), messages.WithHaiku(), messages.WithBudget(&claude.Budget{MaxTokensPerConversation: 100}))
+	if err != nil {
+		t.Fatalf("messages.New: %v", err)
+	}
+	server.Enqueue(claudetest.Text("Hello."))
+	m.AddRoleUser("Say hello.")
+
+	// refused by the conversation budget, so not charged to the client request rate
+	var exceeded *claude.ErrBudgetExceeded
+	if _, err := m.Send(); !errors.As(err, &exceeded) || exceeded.Limit != claude.BUDGET_LIMIT_TOKENS_PER_CONVERSATION {
+		t.Fatalf("Send = %v, want the conversation token limit", err)
+	}
+	if err := m.SetMaxTokens(10); err != nil {
+		t.Fatalf("SetMaxTokens: %v", err)
+	}
+	if _, err := m.Send(); err != nil {
+		t.Fatalf("Send within the budget: %v", err)
+	}
+
+	// the client request rate is used up
+	m.AddRoleUser("Again.")
+	if _, err := m.Send(); !errors.As(err, &exceeded) || exceeded.Limit != claude.BUDGET_LIMIT_REQUESTS_PER_MINUTE {
+		t.Errorf("Send = %v, want the client request rate limit", err)
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("server received %d requests, want 1", n)
+	}
+}
+
+func TestBudgetCache(t *testing.T) {
+	server, err := claudetest.New()
+	if err != nil {
+		t.Fatalf("claudetest.New: %v", err)
+	}
+	t.Cleanup(server.Close)
+	c, err := server.Client(claude.WithBudget(&claude.Budget{MaxRequestsPerMinute: 1}), claude.WithCache(claude.NewMemoryCache(10, 0)))
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	server.Enqueue(claudetest.Text("Hello."))
+
+	newAsk := func(prompt string, opts ...func(*messages.Messages)) *messages.Messages {
+		m, err := messages.New(append([]func(*messages.Messages){messages.WithClaude(c), messages.WithHaiku(), messages.WithTemperature(0)}, opts...)...)
+		if err != nil {
+			t.Fatalf("messages.New: %v", err)
+		}
+		m.AddRoleUser(prompt)
+		return m
+	}
+
+	if _, err := newAsk("Say hello.").Send(); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	// cached replies are not charged to the request rate
+	if _, err := newAsk("Say hello.").Send(); err != nil {
+		t.Errorf("Send of a cached request: %v", err)
+	}
+	stream := newAsk("Say hello.", messages.WithStreaming(true)).Stream(context.Background())
+	for range stream.Response {
+	}
+	select {
+	case err := <-stream.Error:
+		t.Errorf("Stream of a cached request: %v", err)
+	default:
+	}
+
+	var exceeded *claude.ErrBudgetExceeded
+	if _, err := newAsk("Again.").Send(); !errors.As(err, &exceeded) || exceeded.Limit != claude.BUDGET_LIMIT_REQUESTS_PER_MINUTE {
+		t.Errorf("Send = %v, want the client request rate limit", err)
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("server received %d requests, want 1", n)
+	}
+}
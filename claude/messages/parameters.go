@@ -0,0 +1,294 @@
+package messages
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// Preset is a named set of sampling parameters. Nil fields are left untouched when the
+// preset is applied.
+type Preset struct {
+	// Temperature is the sampling temperature, 0 to 1.
+	Temperature *float32
+
+	// TopK samples from the top K options for each subsequent token.
+	TopK *int
+
+	// TopP is the nucleus sampling threshold, 0 to 1.
+	TopP *float32
+
+	// StopSequences are custom sequences that stop generation.
+	StopSequences []string
+}
+
+var (
+	presetsMu sync.RWMutex
+
+	// presets are the registered presets. Use RegisterPreset to add team specific ones
+	// and GetPreset to read them.
+	presets = map[string]*Preset{
+		// deterministic always picks the most likely token
+		"deterministic": {Temperature: float32Ptr(0), TopK: intPtr(1)},
+		// precise favours focused, factual answers
+		"precise": {Temperature: float32Ptr(0.2)},
+		// balanced is a middle ground for general use
+		"balanced": {Temperature: float32Ptr(0.5)},
+		// creative favours varied, open ended answers
+		"creative": {Temperature: float32Ptr(1.0)},
+	}
+)
+
+// RegisterPreset adds or replaces a named preset.
+func RegisterPreset(name string, preset *Preset) error {
+	if err := preset.check(); err != nil {
+		return err
+	}
+	presetsMu.Lock()
+	defer presetsMu.Unlock()
+	presets[name] = copyPreset(preset)
+	return nil
+}
+
+// GetPreset returns a copy of a registered preset.
+func GetPreset(name string) (*Preset, error) {
+	presetsMu.RLock()
+	defer presetsMu.RUnlock()
+	preset, ok := presets[name]
+	if !ok {
+		return nil, &ErrUnknownPreset{Name: name}
+	}
+	return copyPreset(preset), nil
+}
+
+// copyPreset copies a preset, so registered presets cannot be changed without the lock.
+func copyPreset(preset *Preset) *Preset {
+	copied := &Preset{StopSequences: append([]string(nil), preset.StopSequences...)}
+	if preset.Temperature != nil {
+		copied.Temperature = float32Ptr(*preset.Temperature)
+	}
+	if preset.TopK != nil {
+		copied.TopK = intPtr(*preset.TopK)
+	}
+	if preset.TopP != nil {
+		copied.TopP = float32Ptr(*preset.TopP)
+	}
+	return copied
+}
+
+// check validates the ranges of the preset parameters.
+func (p *Preset) check() error {
+	errs := []error{}
+	if p.Temperature != nil {
+		errs = append(errs, checkTemperature(*p.Temperature))
+	}
+	if p.TopK != nil {
+		errs = append(errs, checkTopK(*p.TopK))
+	}
+	if p.TopP != nil {
+		errs = append(errs, checkTopP(*p.TopP))
+	}
+	if p.Temperature != nil && p.TopP != nil {
+		errs = append(errs, &ErrConflictingOptions{Err: errors.New("top_p and temperature")})
+	}
+	errs = append(errs, checkStopSequences(p.StopSequences))
+	return errors.Join(errs...)
+}
+
+// WithPreset applies a registered preset. Options given after it override its values.
+func WithPreset(name string) Option {
+	return func(config *Messages) {
+		config.optionErr(config.ApplyPreset(name))
+	}
+}
+
+// WithTemperature sets the sampling temperature, 0 to 1.
+func WithTemperature(t float32) Option {
+	return func(config *Messages) {
+		config.optionErr(config.SetTemperature(t))
+	}
+}
+
+// WithTopK sets top_k sampling.
+func WithTopK(k int) Option {
+	return func(config *Messages) {
+		config.optionErr(config.SetTopK(k))
+	}
+}
+
+// WithTopP sets nucleus sampling, 0 to 1.
+func WithTopP(p float32) Option {
+	return func(config *Messages) {
+		config.optionErr(config.SetTopP(p))
+	}
+}
+
+// WithStopSequences sets custom stop sequences.
+func WithStopSequences(sequences ...string) Option {
+	return func(config *Messages) {
+		config.optionErr(config.SetStopSequences(sequences...))
+	}
+}
+
+// WithUserId sets metadata.user_id.
+func WithUserId(id string) Option {
+	return func(config *Messages) {
+		config.SetUserId(id)
+	}
+}
+
+// WithSystemPrompt sets the system prompt.
+func WithSystemPrompt(p string) Option {
+	return func(config *Messages) {
+		config.SetSystemPrompt(p)
+	}
+}
+
+// WithStreaming sets the stream flag of the request.
+func WithStreaming(stream bool) Option {
+	return func(config *Messages) {
+		config.SetStreaming(stream)
+	}
+}
+
+// WithTools adds tools the model may use.
+func WithTools(tools ...*Tool) Option {
+	return func(config *Messages) {
+		for _, tool := range tools {
+			config.AddTool(tool)
+		}
+	}
+}
+
+// WithToolChoice sets the tool choice: auto, any, or the name of a tool.
+func WithToolChoice(choice string) Option {
+	return func(config *Messages) {
+		switch choice {
+		case "auto":
+			config.SetToolChoiceAuto()
+		case "any":
+			config.SetToolChoiceAny()
+		default:
+			config.SetToolChoiceTool(choice)
+		}
+	}
+}
+
+// ApplyPreset applies a registered preset to the request. Setters called afterwards
+// override its values.
+func (messages *Messages) ApplyPreset(name string) error {
+	preset, err := GetPreset(name)
+	if err != nil {
+		return err
+	}
+
+	// a preset replaces the whole sampling configuration
+	messages.request.Temperature = nil
+	messages.request.TopK = nil
+	messages.request.TopP = nil
+	if preset.Temperature != nil {
+		messages.request.Temperature = float32Ptr(*preset.Temperature)
+	}
+	if preset.TopK != nil {
+		messages.request.TopK = intPtr(*preset.TopK)
+	}
+	if preset.TopP != nil {
+		messages.request.TopP = float32Ptr(*preset.TopP)
+	}
+	if preset.StopSequences != nil {
+		messages.request.StopSequences = append([]string{}, preset.StopSequences...)
+	}
+	return nil
+}
+
+// SetTemperature sets the sampling temperature, 0 to 1.
+func (messages *Messages) SetTemperature(t float32) error {
+	if err := checkTemperature(t); err != nil {
+		return err
+	}
+	messages.request.Temperature = &t
+	return nil
+}
+
+// SetTopK sets top_k sampling.
+func (messages *Messages) SetTopK(k int) error {
+	if err := checkTopK(k); err != nil {
+		return err
+	}
+	messages.request.TopK = &k
+	return nil
+}
+
+// SetTopP sets nucleus sampling, 0 to 1.
+func (messages *Messages) SetTopP(p float32) error {
+	if err := checkTopP(p); err != nil {
+		return err
+	}
+	messages.request.TopP = &p
+	return nil
+}
+
+// SetStopSequences sets custom stop sequences. Call with no arguments to clear them.
+func (messages *Messages) SetStopSequences(sequences ...string) error {
+	if err := checkStopSequences(sequences); err != nil {
+		return err
+	}
+	if len(sequences) == 0 {
+		messages.request.StopSequences = nil
+		return nil
+	}
+	messages.request.StopSequences = append([]string{}, sequences...)
+	return nil
+}
+
+// ClearSampling removes temperature, top_k and top_p so the API defaults apply.
+func (messages *Messages) ClearSampling() {
+	messages.request.Temperature = nil
+	messages.request.TopK = nil
+	messages.request.TopP = nil
+}
+
+// optionErr records an error raised while applying an option; New returns them.
+func (messages *Messages) optionErr(err error) {
+	if err != nil {
+		messages.optionErrs = append(messages.optionErrs, err)
+	}
+}
+
+func checkTemperature(t float32) error {
+	if t < 0 || t > 1 {
+		return &ErrInvalidParameter{Parameter: "temperature", Value: t, Err: errors.New("must be between 0 and 1")}
+	}
+	return nil
+}
+
+func checkTopK(k int) error {
+	if k < 0 {
+		return &ErrInvalidParameter{Parameter: "top_k", Value: k, Err: errors.New("must not be negative")}
+	}
+	return nil
+}
+
+func checkTopP(p float32) error {
+	if p < 0 || p > 1 {
+		return &ErrInvalidParameter{Parameter: "top_p", Value: p, Err: errors.New("must be between 0 and 1")}
+	}
+	return nil
+}
+
+func checkStopSequences(sequences []string) error {
+	for _, sequence := range sequences {
+		if strings.TrimSpace(sequence) == "" {
+			return &ErrInvalidParameter{Parameter: "stop_sequences", Value: sequences, Err: errors.New("must not contain empty sequences")}
+		}
+	}
+	return nil
+}
+
+func float32Ptr(f float32) *float32 {
+	return &f
+}
+
+func intPtr(i int) *int {
+	return &i
+}
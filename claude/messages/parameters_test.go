@@ -0,0 +1,35 @@
+package messages_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rmrfslashbin/ami/claude/messages"
+)
+
+func TestPresets(t *testing.T) {
+	preset, err := messages.GetPreset("deterministic")
+	if err != nil {
+		t.Fatalf("GetPreset: %v", err)
+	}
+	// changing the returned preset does not change the registered one
+	*preset.Temperature = 0.9
+	preset.StopSequences = append(preset.StopSequences, "END")
+	if again, _ := messages.GetPreset("deterministic"); *again.Temperature != 0 || len(again.StopSequences) != 0 {
+		t.Errorf("registered preset changed to %+v", again)
+	}
+
+	stop := []string{"STOP"}
+	if err := messages.RegisterPreset("team", &messages.Preset{StopSequences: stop}); err != nil {
+		t.Fatalf("RegisterPreset: %v", err)
+	}
+	stop[0] = "CHANGED"
+	if team, err := messages.GetPreset("team"); err != nil || team.StopSequences[0] != "STOP" {
+		t.Errorf("GetPreset(team) = %+v, %v, want the registered copy", team, err)
+	}
+
+	var unknown *messages.ErrUnknownPreset
+	if _, err := messages.GetPreset("missing"); !errors.As(err, &unknown) {
+		t.Errorf("GetPreset(missing) = %v, want ErrUnknownPreset", err)
+	}
+}
@@ -1,12 +1,11 @@
 package messages
 
 import (
-	"reflect"
-	"slices"
-	"strings"
 	"time"
 
 	"github.com/invopop/jsonschema"
+	"github.com/rmrfslashbin/ami/claude"
+	"github.com/rmrfslashbin/ami/validate"
 )
 
 // Conversation represents a conversation. This is not part of the API.
@@ -14,6 +13,9 @@ type Conversation struct {
 	// Id is the unique object identifier.
 	Id string `json:"id"`
 
+	// Title is a human readable title for the conversation.
+	Title string `json:"title"`
+
 	// Model is the model used in the conversation.
 	Model *string `json:"model"`
 
@@ -25,6 +27,9 @@ type Conversation struct {
 
 	// Messages is a list of messages in the conversation.
 	Messages []*Message `json:"messages"`
+
+	// Spend is the running token and dollar total of the conversation.
+	Spend claude.Spend `json:"spend"`
 }
 
 // https://docs.anthropic.com/claude/reference/messages_post
@@ -33,15 +38,15 @@ type Request struct {
 	// Model is the model that will complete your prompt.
 	// Required.
 	// See models (https://docs.anthropic.com/claude/docs/models-overview) for additional details and options.
-	Model string `json:"model" required:"true" enum:"haiku,sonnet,opus"`
+	Model string `json:"model" required:"true" regex:"^claude-[a-z0-9.-]+$"`
 
 	// Messages is the messages to send to the API.
 	// Required.
-	Messages []*Message `json:"messages" required:"true"`
+	Messages []*Message `json:"messages" required:"true" min:"1"`
 
 	// MaxToken is the maximum number of tokens to generate before stopping.
 	// Required.
-	MaxTokens int `json:"max_tokens" required:"true"`
+	MaxTokens int `json:"max_tokens" required:"true" min:"1"`
 
 	// Metadata is an object describing metadata about the request.
 	Metadata *Metadata `json:"metadata,omitempty"`
@@ -57,22 +62,22 @@ type Request struct {
 	System string `json:"system,omitempty"`
 
 	// Temperature is a float that controls the randomness of the model's output. The higher the temperature, the more random the output.
-	Temperature *float32 `json:"temperature,omitempty"`
+	Temperature *float32 `json:"temperature,omitempty" min:"0" max:"1"`
 
 	// ToolChoice is a tool choice.
-	ToolChoice *ToolChoice `json:"tool_choice,omitempty" enum:"auto,any,tool"`
+	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
 
 	// Tools are definitions of tools that the model may use
 	Tools []*Tool `json:"tools,omitempty"`
 
 	// TopK is an integer that specifies sampling from the top K options for each subsequent token.
 	// Recommended for advanced use cases only. You usually only need to use temperature.
-	TopK *int `json:"top_k,omitempty"`
+	TopK *int `json:"top_k,omitempty" min:"0"`
 
-	// TopP is an integer that controls nucleus sampling. The higher the top_p, the more diverse the output.
+	// TopP is a float between 0 and 1 that controls nucleus sampling. The higher the top_p, the more diverse the output.
 	// Recommended for advanced use cases only. You usually only need to use temperature.
 	// You should either alter temperature or top_p, but not both.
-	TopP *int `json:"top_p,omitempty"`
+	TopP *float32 `json:"top_p,omitempty" min:"0" max:"1"`
 
 	// modelMaxTokens is the maximum number of tokens for the model.
 	modelMaxTokens int
@@ -92,51 +97,59 @@ type Message struct {
 	// Role is the conversational role of the message.
 	// Specify a single user-role message, or you can include multiple "user" and "assistant" messages.
 	// The first message must always use the "user" role.
-	Role string `json:"role"`
+	Role string `json:"role" required:"true" enum:"user,assistant"`
 
 	// MessageContent is the content of the message.
-	MessageContent []*Content `json:"content"`
+	MessageContent []*Content `json:"content" required:"true" min:"1"`
+
+	// Created is the time the message was added to the conversation. This is not part of the API.
+	Created time.Time `json:"-"`
+
+	// Usage is the usage reported for an assistant reply. This is not part of the API.
+	Usage *Usage `json:"-"`
 }
 
 // Content is the content of the message.
 type Content struct {
 	// Type is the type of content.
-	Type string `json:"type"`
+	Type string `json:"type" required:"true" enum:"text,image,tool_use,tool_result"`
 
 	// Text is the text of the content.
-	Text string `json:"text,omitempty"`
+	Text string `json:"text,omitempty" require_if:"Type=text"`
 
 	// Content is the content of the content.
 	Content string `json:"content,omitempty"`
 
 	// Id is the unique object identifier for a tool_use block.
-	Id string `json:"id,omitempty"`
+	Id string `json:"id,omitempty" require_if:"Type=tool_use"`
 
 	// ToolUseId is the unique object identifier for a tool_use block.
-	ToolUseId string `json:"tool_use_id,omitempty"`
+	ToolUseId string `json:"tool_use_id,omitempty" require_if:"Type=tool_result"`
 
 	// Input is the input request for a tool_use block.
 	Input interface{} `json:"input,omitempty"`
 
 	// Name is the name of the tool used in a tool_use block.
-	Name string `json:"name,omitempty"`
+	Name string `json:"name,omitempty" require_if:"Type=tool_use"`
 
 	// Source is the source of the media.
-	Source *MediaSource `json:"source,omitempty"`
+	Source *MediaSource `json:"source,omitempty" require_if:"Type=image"`
 }
 
 // MediaSource is the source of the media.
 type MediaSource struct {
-	// Type is the type of media source.
-	// The only type is "base64".
-	Type string `json:"type"`
+	// Type is the type of media source: "base64" or "url".
+	Type string `json:"type" required:"true" enum:"base64,url"`
 
 	// MediaType is the media type of the data.
 	// Valid image types: image/jpeg, image/png, image/gif, and image/webp.
-	MediaType string `json:"media_type"`
+	MediaType string `json:"media_type,omitempty" require_if:"Type=base64" enum:"@claude.media_types"`
 
 	// Data is the base64 encoded data.
-	Data string `json:"data"`
+	Data string `json:"data,omitempty" require_if:"Type=base64"`
+
+	// URL is the location of the image for "url" sources.
+	URL string `json:"url,omitempty" require_if:"Type=url" regex:"^https?://"`
 }
 
 // ToolChoice is a tool choice. The model can use a specific tool, any available tool, or decide by itself.
@@ -186,6 +199,26 @@ type Response struct {
 	// Usage is the usage of the API billing and rate-limit data.
 	// Required.
 	Usage Usage `json:"usage"`
+
+	// Meta describes how the response was obtained, e.g. whether it came from the cache.
+	// It is not part of the API.
+	Meta *claude.ResponseMeta `json:"-"`
+}
+
+// https://docs.anthropic.com/en/api/messages-count-tokens
+// CountTokensRequest is the request to send to the token counting API.
+type CountTokensRequest struct {
+	Model      string      `json:"model"`
+	Messages   []*Message  `json:"messages"`
+	System     string      `json:"system,omitempty"`
+	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+	Tools      []*Tool     `json:"tools,omitempty"`
+}
+
+// CountTokensResponse is the response from the token counting API.
+type CountTokensResponse struct {
+	// InputTokens is the number of input tokens the request would use.
+	InputTokens int `json:"input_tokens"`
 }
 
 type Usage struct {
@@ -196,18 +229,24 @@ type Usage struct {
 	// OutputTokens is the number of tokens generated by the model.
 	// Required.
 	OutputTokens int `json:"output_tokens"`
+
+	// CacheCreationInputTokens is the number of input tokens written to the prompt cache.
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+
+	// CacheReadInputTokens is the number of input tokens read from the prompt cache.
+	CacheReadInputTokens int `json:"cache_read_input_tokens,omitempty"`
 }
 
 // Tool defines a tool that the model may use.
 type Tool struct {
 	// Name is the name of the tool.
-	Name string `json:"name"`
+	Name string `json:"name" required:"true" regex:"^[a-zA-Z0-9_-]{1,64}$"`
 
 	// Description is the optional description of the tool.
 	Description string `json:"description"`
 
 	// Input_schema specified the JSON schema for the tool input shape that the model will produce in tool_use output content blocks.
-	InputSchema *jsonschema.Schema `json:"input_schema"`
+	InputSchema *jsonschema.Schema `json:"input_schema" required:"true"`
 }
 
 // ToolReply is the reply from the tool.
@@ -290,64 +329,28 @@ type Error struct {
 }
 
 // Validater is an interface for validating structs.
-type Validater interface {
-	Validate() error
-}
+type Validater = validate.Validater
 
-// Validate validates the struct.
+// Validate validates the struct tags, recursing into nested structs and slices, and then
+// calls the struct's Validate method. Every violation is returned as validate.ValidationErrors.
 func Validate(v Validater) error {
-	// reflect the struct and parse the tags
-	val := reflect.ValueOf(v)
-	for i := 0; i < val.NumField(); i++ {
-		requiredTag := val.Type().Field(i).Tag.Get("required")
-		enumTag := val.Type().Field(i).Tag.Get("enum")
-		requireIfTag := val.Type().Field(i).Tag.Get("require_if")
-
-		// check if the field is required
-		if requiredTag == "true" {
-			if val.Field(i).IsZero() {
-				return &ValidationError{Field: val.Type().Field(i).Name, Message: "is required"}
-			}
-		}
-
-		// check if the field is in the enum; the format of the enum field is "enum1,enum2,enum3"
-		if enumTag != "" {
-			enum := strings.Split(enumTag, ",")
-			if !slices.Contains(enum, val.Field(i).String()) {
-				return &ValidationError{Field: val.Type().Field(i).Name, Message: "is not in the enum"}
-			}
-		}
-
-		// check if the field is required if another field is set
-		if requireIfTag != "" {
-			// split the require_if tag
-
-			requireIfSplit := strings.Split(requireIfTag, "=")
-			requireIfField := requireIfSplit[0]
-			requireIfValue := requireIfSplit[1]
-
-			// if a value is set, the field is required
-			if val.FieldByName(requireIfField).String() == requireIfValue {
-				if val.Field(i).IsZero() {
-					return &ValidationError{Field: val.Type().Field(i).Name, Message: "is required"}
-				}
-			}
-		}
-	}
-
-	// call the struct's validate method
-	return v.Validate()
+	return validate.Validate(v)
 }
 
-// Validate validates the Request struct.
+// Validate validates the Request struct fields that depend on each other.
 func (r *Request) Validate() error {
+	errs := validate.ValidationErrors{}
+
 	if r.MaxTokens > r.modelMaxTokens {
-		return &ValidationError{Field: "max_tokens", Message: "cannot be greater than the model's max tokens"}
+		errs = append(errs, &ValidationError{Field: "max_tokens", Rule: "max", Message: "cannot be greater than the model's max tokens"})
 	}
 
 	if r.TopP != nil && r.Temperature != nil {
-		return &ValidationError{Field: "top_p and temperature", Message: "cannot both be set"}
+		errs = append(errs, &ValidationError{Field: "top_p and temperature", Rule: "conflict", Message: "cannot both be set"})
 	}
 
-	return nil
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
 }
@@ -0,0 +1,33 @@
+package transcript
+
+type ErrMissingConversation struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingConversation) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing conversation"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrRenderingTemplate struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrRenderingTemplate) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "error rendering template"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
@@ -0,0 +1,36 @@
+package transcript
+
+import (
+	"html/template"
+
+	"github.com/rmrfslashbin/ami/claude/messages"
+)
+
+// document is the renderer-neutral form of a conversation.
+type document struct {
+	Title    string
+	Model    string
+	Created  string
+	Updated  string
+	Messages []*entry
+	Usage    messages.Usage
+	Cost     string
+}
+
+// entry is a single message in the transcript.
+type entry struct {
+	Role    string
+	Created string
+	Usage   *messages.Usage
+	Blocks  []*block
+}
+
+// block is a single content block in the transcript.
+type block struct {
+	// Kind is one of text, image, tool_use or tool_result.
+	Kind     string
+	Title    string
+	Text     string
+	Language string
+	Image    template.URL
+}
@@ -0,0 +1,223 @@
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/rmrfslashbin/ami/claude/messages"
+)
+
+// TIME_FORMAT is the format used for timestamps in transcripts.
+const TIME_FORMAT = time.RFC1123
+
+// Markdown writes the conversation as a Markdown transcript. Tool inputs and results are
+// rendered as collapsible <details> sections, which GitHub flavoured Markdown supports.
+func Markdown(w io.Writer, conversation *messages.Conversation) error {
+	if conversation == nil {
+		return &ErrMissingConversation{}
+	}
+
+	if err := markdownTemplate.Execute(w, build(conversation)); err != nil {
+		return &ErrRenderingTemplate{Err: err}
+	}
+	return nil
+}
+
+// HTML writes the conversation as a self-contained HTML page. Images are embedded as
+// data URIs and tool inputs and results are rendered as collapsible sections.
+func HTML(w io.Writer, conversation *messages.Conversation) error {
+	if conversation == nil {
+		return &ErrMissingConversation{}
+	}
+
+	if err := htmlTemplate.Execute(w, build(conversation)); err != nil {
+		return &ErrRenderingTemplate{Err: err}
+	}
+	return nil
+}
+
+// build converts a conversation into the renderer-neutral document.
+func build(conversation *messages.Conversation) *document {
+	doc := &document{
+		Title:   conversation.Title,
+		Created: formatTime(conversation.Created),
+		Updated: formatTime(conversation.Updated),
+	}
+	if doc.Title == "" {
+		doc.Title = "Conversation"
+		if conversation.Id != "" {
+			doc.Title += " " + conversation.Id
+		}
+	}
+	if conversation.Model != nil {
+		doc.Model = *conversation.Model
+	}
+	if conversation.Spend.Cost > 0 {
+		doc.Cost = fmt.Sprintf("$%.4f", conversation.Spend.Cost)
+	}
+
+	for _, message := range conversation.Messages {
+		if message == nil {
+			continue
+		}
+		entry := &entry{
+			Role:    message.Role,
+			Created: formatTime(message.Created),
+			Usage:   message.Usage,
+		}
+		if message.Usage != nil {
+			doc.Usage.InputTokens += message.Usage.InputTokens
+			doc.Usage.OutputTokens += message.Usage.OutputTokens
+		}
+
+		for _, content := range message.MessageContent {
+			if content != nil {
+				entry.Blocks = append(entry.Blocks, convert(content))
+			}
+		}
+		doc.Messages = append(doc.Messages, entry)
+	}
+
+	return doc
+}
+
+// convert converts a single content block.
+func convert(content *messages.Content) *block {
+	switch content.Type {
+	case "image":
+		b := &block{Kind: "image", Title: "image"}
+		switch {
+		case content.Source == nil:
+		case content.Source.Type == "url":
+			b.Title = content.Source.URL
+			// only trust http(s) links; template.URL bypasses html/template's URL filtering
+			if strings.HasPrefix(content.Source.URL, "https://") || strings.HasPrefix(content.Source.URL, "http://") {
+				b.Image = template.URL(content.Source.URL)
+			}
+		default:
+			b.Title = content.Source.MediaType
+			b.Image = template.URL("data:" + content.Source.MediaType + ";base64," + content.Source.Data)
+		}
+		return b
+
+	case "tool_use":
+		input, err := json.MarshalIndent(content.Input, "", "  ")
+		if err != nil {
+			input = []byte(fmt.Sprint(content.Input))
+		}
+		return &block{
+			Kind:     "tool_use",
+			Title:    fmt.Sprintf("Tool use: %s (%s)", content.Name, content.Id),
+			Text:     string(input),
+			Language: "json",
+		}
+
+	case "tool_result":
+		return &block{
+			Kind:  "tool_result",
+			Title: fmt.Sprintf("Tool result (%s)", content.ToolUseId),
+			Text:  content.Content,
+		}
+
+	default:
+		return &block{Kind: "text", Text: content.Text}
+	}
+}
+
+// fence returns a code fence longer than any run of backticks in text, so the text cannot
+// close it early.
+func fence(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r != '`' {
+			run = 0
+			continue
+		}
+		run++
+		longest = max(longest, run)
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// formatTime formats t, returning an empty string for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(TIME_FORMAT)
+}
+
+// markdownTemplate renders a document as Markdown. Images without an http(s) or data URI
+// are rendered as a placeholder.
+var markdownTemplate = texttemplate.Must(texttemplate.New("transcript").Funcs(texttemplate.FuncMap{"fence": fence}).Parse(`# {{.Title}}
+
+{{if .Model}}- **Model:** {{.Model}}
+{{end}}- **Created:** {{.Created}}
+- **Updated:** {{.Updated}}
+
+{{range .Messages}}## {{.Role}}{{if .Created}} <sub>{{.Created}}</sub>{{end}}
+
+{{range .Blocks}}{{if eq .Kind "text"}}{{.Text}}
+
+{{else if eq .Kind "image"}}{{if .Image}}![{{.Title}}]({{.Image}}){{else}}*[image: {{.Title}}]*{{end}}
+
+{{else}}{{$fence := fence .Text}}<details>
+<summary>{{html .Title}}</summary>
+
+{{$fence}}{{.Language}}
+{{.Text}}
+{{$fence}}
+
+</details>
+
+{{end}}{{end}}{{if .Usage}}*Tokens: {{.Usage.InputTokens}} in / {{.Usage.OutputTokens}} out*
+
+{{end}}{{end}}---
+
+**Total tokens:** {{.Usage.InputTokens}} input, {{.Usage.OutputTokens}} output
+{{if .Cost}}
+**Total cost:** {{.Cost}}
+{{end}}`))
+
+var htmlTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 860px; margin: 2em auto; padding: 0 1em; color: #1f2328; }
+header { border-bottom: 1px solid #d0d7de; margin-bottom: 1.5em; }
+.meta { color: #57606a; font-size: 0.9em; }
+.message { border: 1px solid #d0d7de; border-radius: 6px; padding: 0.75em 1em; margin: 1em 0; }
+.message.user { background: #f6f8fa; }
+.message.assistant { background: #ffffff; }
+.role { font-weight: 600; text-transform: capitalize; }
+.text { white-space: pre-wrap; }
+img { max-width: 100%; border-radius: 4px; }
+details { margin: 0.5em 0; }
+summary { cursor: pointer; color: #0969da; }
+pre { background: #f6f8fa; padding: 0.75em; overflow-x: auto; border-radius: 4px; }
+footer { border-top: 1px solid #d0d7de; margin-top: 1.5em; padding-top: 0.5em; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<p class="meta">{{if .Model}}Model: {{.Model}} &middot; {{end}}Created: {{.Created}} &middot; Updated: {{.Updated}}</p>
+</header>
+{{range .Messages}}<section class="message {{.Role}}">
+<div><span class="role">{{.Role}}</span>{{if .Created}} <span class="meta">{{.Created}}</span>{{end}}</div>
+{{range .Blocks}}{{if eq .Kind "text"}}<div class="text">{{.Text}}</div>
+{{else if eq .Kind "image"}}{{if .Image}}<img src="{{.Image}}" alt="{{.Title}}">{{else}}<p class="meta">[image: {{.Title}}]</p>{{end}}
+{{else}}<details><summary>{{.Title}}</summary><pre>{{.Text}}</pre></details>
+{{end}}{{end}}{{if .Usage}}<p class="meta">Tokens: {{.Usage.InputTokens}} in / {{.Usage.OutputTokens}} out</p>
+{{end}}</section>
+{{end}}<footer class="meta">Total tokens: {{.Usage.InputTokens}} input, {{.Usage.OutputTokens}} output{{if .Cost}} &middot; Total cost: {{.Cost}}{{end}}</footer>
+</body>
+</html>
+`))
@@ -0,0 +1,162 @@
+package transcript_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmrfslashbin/ami/claude/messages"
+	"github.com/rmrfslashbin/ami/claude/transcript"
+)
+
+// conversation returns a conversation with text, an image, a tool round trip and usage.
+func conversation() *messages.Conversation {
+	model := "claude-3-haiku-20240307"
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return &messages.Conversation{
+		Id:      "abc",
+		Title:   "Weather <today>",
+		Model:   &model,
+		Created: created,
+		Updated: created.Add(time.Minute),
+		Messages: []*messages.Message{
+			{Role: "user", Created: created, MessageContent: []*messages.Content{
+				{Type: "text", Text: "What is the weather in Paris?"},
+				{Type: "image", Source: &messages.MediaSource{Type: "base64", MediaType: "image/png", Data: "iVBORw0KGgo="}},
+			}},
+			{Role: "assistant", Usage: &messages.Usage{InputTokens: 10, OutputTokens: 5}, MessageContent: []*messages.Content{
+				{Type: "tool_use", Id: "toolu_1", Name: "get_weather", Input: map[string]interface{}{"city": "Paris"}},
+			}},
+			{Role: "user", MessageContent: []*messages.Content{
+				{Type: "tool_result", ToolUseId: "toolu_1", Content: "18C and sunny"},
+			}},
+			{Role: "assistant", Usage: &messages.Usage{InputTokens: 20, OutputTokens: 7}, MessageContent: []*messages.Content{
+				{Type: "text", Text: "It is 18C and sunny. <script>alert(1)</script>"},
+			}},
+		},
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := transcript.Markdown(buf, conversation()); err != nil {
+		t.Fatalf("Markdown: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"# Weather <today>\n",
+		"- **Model:** claude-3-haiku-20240307\n",
+		"## user <sub>Wed, 01 May 2024 12:00:00 UTC</sub>\n",
+		"What is the weather in Paris?\n",
+		"![image/png](data:image/png;base64,iVBORw0KGgo=)\n",
+		"<summary>Tool use: get_weather (toolu_1)</summary>\n\n```json\n{\n  \"city\": \"Paris\"\n}\n```\n",
+		"<summary>Tool result (toolu_1)</summary>\n\n```\n18C and sunny\n```\n",
+		"*Tokens: 10 in / 5 out*\n",
+		"**Total tokens:** 30 input, 12 output\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Markdown is missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestHTML(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := transcript.HTML(buf, conversation()); err != nil {
+		t.Fatalf("HTML: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"<title>Weather &lt;today&gt;</title>",
+		"Model: claude-3-haiku-20240307",
+		`<img src="data:image/png;base64,iVBORw0KGgo=" alt="image/png">`,
+		"<summary>Tool use: get_weather (toolu_1)</summary>",
+		"It is 18C and sunny. &lt;script&gt;alert(1)&lt;/script&gt;",
+		"Total tokens: 30 input, 12 output",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("HTML is missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "<script>") {
+		t.Errorf("HTML contains unescaped message text")
+	}
+}
+
+func TestMarkdownFence(t *testing.T) {
+	result := "Run:\n````\n```go\nfmt.Println()\n```\n````"
+	c := &messages.Conversation{Messages: []*messages.Message{
+		{Role: "user", MessageContent: []*messages.Content{{Type: "tool_result", ToolUseId: "toolu_1", Content: result}}},
+	}}
+
+	buf := &bytes.Buffer{}
+	if err := transcript.Markdown(buf, c); err != nil {
+		t.Fatalf("Markdown: %v", err)
+	}
+	// the fence is longer than the longest backtick run in the result
+	if want := "\n`````\n" + result + "\n`````\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("Markdown is missing %q in:\n%s", want, buf.String())
+	}
+}
+
+func TestUntrustedImage(t *testing.T) {
+	c := &messages.Conversation{Messages: []*messages.Message{
+		{Role: "user", MessageContent: []*messages.Content{
+			{Type: "image", Source: &messages.MediaSource{Type: "url", URL: "javascript:alert(1)"}},
+			{Type: "image", Source: &messages.MediaSource{Type: "url", URL: "https://example.com/cat.png"}},
+		}},
+		nil,
+	}}
+
+	markdown := &bytes.Buffer{}
+	if err := transcript.Markdown(markdown, c); err != nil {
+		t.Fatalf("Markdown: %v", err)
+	}
+	html := &bytes.Buffer{}
+	if err := transcript.HTML(html, c); err != nil {
+		t.Fatalf("HTML: %v", err)
+	}
+
+	for _, want := range []string{"*[image: javascript:alert(1)]*\n", "![https://example.com/cat.png](https://example.com/cat.png)\n"} {
+		if !strings.Contains(markdown.String(), want) {
+			t.Errorf("Markdown is missing %q in:\n%s", want, markdown.String())
+		}
+	}
+	if strings.Contains(markdown.String(), "]()") {
+		t.Errorf("Markdown has an image without a link:\n%s", markdown.String())
+	}
+	for _, want := range []string{`<p class="meta">[image: javascript:alert(1)]</p>`, `<img src="https://example.com/cat.png"`} {
+		if !strings.Contains(html.String(), want) {
+			t.Errorf("HTML is missing %q in:\n%s", want, html.String())
+		}
+	}
+}
+
+func TestUntitled(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := transcript.Markdown(buf, &messages.Conversation{Id: "abc"}); err != nil {
+		t.Fatalf("Markdown: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "# Conversation abc\n") {
+		t.Errorf("Markdown title = %q", strings.SplitN(buf.String(), "\n", 2)[0])
+	}
+}
+
+func TestMissingConversation(t *testing.T) {
+	for name, render := range map[string]func(io.Writer, *messages.Conversation) error{
+		"markdown": transcript.Markdown,
+		"html":     transcript.HTML,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var missing *transcript.ErrMissingConversation
+			if err := render(io.Discard, nil); !errors.As(err, &missing) {
+				t.Errorf("got %v, want ErrMissingConversation", err)
+			}
+		})
+	}
+}
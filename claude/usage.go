@@ -0,0 +1,186 @@
+package claude
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Pricing is the price of a model in US dollars per million tokens.
+type Pricing struct {
+	// Input is the price of input tokens.
+	Input float64 `json:"input"`
+
+	// Output is the price of output tokens.
+	Output float64 `json:"output"`
+
+	// CacheWrite is the price of input tokens written to the prompt cache.
+	CacheWrite float64 `json:"cache_write"`
+
+	// CacheRead is the price of input tokens read from the prompt cache.
+	CacheRead float64 `json:"cache_read"`
+}
+
+// UsageRecord is the usage and cost of a single API call.
+type UsageRecord struct {
+	// Time is the time the call completed.
+	Time time.Time `json:"time"`
+
+	// Model is the model that handled the call.
+	Model string `json:"model"`
+
+	// UserId is the metadata.user_id of the request; use it to attribute cost.
+	UserId string `json:"user_id,omitempty"`
+
+	// InputTokens is the number of uncached input tokens.
+	InputTokens int `json:"input_tokens"`
+
+	// OutputTokens is the number of generated tokens.
+	OutputTokens int `json:"output_tokens"`
+
+	// CacheCreationInputTokens is the number of input tokens written to the prompt cache.
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+
+	// CacheReadInputTokens is the number of input tokens read from the prompt cache.
+	CacheReadInputTokens int `json:"cache_read_input_tokens"`
+
+	// Cost is the price of the call in US dollars. It is zero for models without pricing.
+	Cost float64 `json:"cost"`
+}
+
+// Spend is a running total of usage and cost.
+type Spend struct {
+	// Requests is the number of API calls.
+	Requests int `json:"requests"`
+
+	// InputTokens is the number of uncached input tokens.
+	InputTokens int `json:"input_tokens"`
+
+	// OutputTokens is the number of generated tokens.
+	OutputTokens int `json:"output_tokens"`
+
+	// CacheCreationInputTokens is the number of input tokens written to the prompt cache.
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+
+	// CacheReadInputTokens is the number of input tokens read from the prompt cache.
+	CacheReadInputTokens int `json:"cache_read_input_tokens"`
+
+	// Cost is the total price in US dollars.
+	Cost float64 `json:"cost"`
+}
+
+// Add adds a usage record to the total.
+func (s *Spend) Add(record *UsageRecord) {
+	s.Requests++
+	s.InputTokens += record.InputTokens
+	s.OutputTokens += record.OutputTokens
+	s.CacheCreationInputTokens += record.CacheCreationInputTokens
+	s.CacheReadInputTokens += record.CacheReadInputTokens
+	s.Cost += record.Cost
+}
+
+// Tokens returns the total number of tokens, input and output.
+func (s *Spend) Tokens() int {
+	return s.InputTokens + s.OutputTokens + s.CacheCreationInputTokens + s.CacheReadInputTokens
+}
+
+// GetPricing returns the pricing for a model, given either its short name (e.g. "opus")
+// or its API name. Dated API names match their undated prefix. It returns nil if the
+// model has no pricing.
+func GetPricing(model string) *Pricing {
+	if m, ok := ModelsList[model]; ok {
+		return m.Pricing
+	}
+	for _, m := range ModelsList {
+		if m.Name == model {
+			return m.Pricing
+		}
+	}
+	for _, m := range ModelsList {
+		if strings.HasPrefix(model, m.Name) {
+			return m.Pricing
+		}
+	}
+	return nil
+}
+
+// Cost returns the price in US dollars of the usage in record.
+func Cost(record *UsageRecord) float64 {
+	pricing := GetPricing(record.Model)
+	if pricing == nil {
+		return 0
+	}
+	return (float64(record.InputTokens)*pricing.Input +
+		float64(record.OutputTokens)*pricing.Output +
+		float64(record.CacheCreationInputTokens)*pricing.CacheWrite +
+		float64(record.CacheReadInputTokens)*pricing.CacheRead) / 1_000_000
+}
+
+// WithUsageCallback registers a function called with every usage record. Use it to send
+// spend to your own metrics. Callbacks run synchronously on the calling goroutine.
+func WithUsageCallback(callback func(*UsageRecord)) Option {
+	return func(config *Claude) {
+		config.usageCallbacks = append(config.usageCallbacks, callback)
+	}
+}
+
+// RecordUsage adds a usage record to the client totals and calls the usage callbacks.
+// The cost is computed if it is not set.
+func (c *Claude) RecordUsage(record *UsageRecord) {
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	if record.Cost == 0 {
+		record.Cost = Cost(record)
+	}
+
+	c.spendMu.Lock()
+	c.total.Add(record)
+	spend, ok := c.spend[record.UserId]
+	if !ok {
+		spend = &Spend{}
+		c.spend[record.UserId] = spend
+	}
+	spend.Add(record)
+	callbacks := c.usageCallbacks
+	c.spendMu.Unlock()
+
+	if c.budget != nil {
+		if err := c.budget.Record(record); err != nil {
+			c.log.Error("error recording budget spend", slog.String("error", err.Error()))
+		}
+	}
+
+	for _, callback := range callbacks {
+		callback(record)
+	}
+}
+
+// GetTotalSpend returns the usage and cost of every call made with this client.
+func (c *Claude) GetTotalSpend() Spend {
+	c.spendMu.Lock()
+	defer c.spendMu.Unlock()
+	return c.total
+}
+
+// GetSpend returns the usage and cost attributed to a user id. Calls without a user id
+// are attributed to "".
+func (c *Claude) GetSpend(userId string) Spend {
+	c.spendMu.Lock()
+	defer c.spendMu.Unlock()
+	if spend, ok := c.spend[userId]; ok {
+		return *spend
+	}
+	return Spend{}
+}
+
+// GetSpendByUser returns the usage and cost of every user id.
+func (c *Claude) GetSpendByUser() map[string]Spend {
+	c.spendMu.Lock()
+	defer c.spendMu.Unlock()
+	spend := make(map[string]Spend, len(c.spend))
+	for userId, s := range c.spend {
+		spend[userId] = *s
+	}
+	return spend
+}
@@ -16,7 +16,6 @@ require (
 )
 
 require (
-	github.com/davecgh/go-spew v1.1.1
 	github.com/tmaxmax/go-sse v0.8.0
 	golang.org/x/net v0.26.0 // indirect
 )
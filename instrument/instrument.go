@@ -0,0 +1,124 @@
+package instrument
+
+// path: instrument/instrument.go
+
+import "context"
+
+// MODULE_NAME is the module name
+const MODULE_NAME = "instrument"
+
+// Span and metric attribute keys. The gen_ai keys follow the OpenTelemetry GenAI
+// semantic conventions; the stability keys are specific to image generation.
+const (
+	ATTR_GEN_AI_SYSTEM              = "gen_ai.system"
+	ATTR_GEN_AI_OPERATION_NAME      = "gen_ai.operation.name"
+	ATTR_GEN_AI_REQUEST_MODEL       = "gen_ai.request.model"
+	ATTR_GEN_AI_RESPONSE_MODEL      = "gen_ai.response.model"
+	ATTR_GEN_AI_RESPONSE_ID         = "gen_ai.response.id"
+	ATTR_GEN_AI_FINISH_REASONS      = "gen_ai.response.finish_reasons"
+	ATTR_GEN_AI_USAGE_INPUT_TOKENS  = "gen_ai.usage.input_tokens"
+	ATTR_GEN_AI_USAGE_OUTPUT_TOKENS = "gen_ai.usage.output_tokens"
+	ATTR_GEN_AI_TOKEN_TYPE          = "gen_ai.token.type"
+	ATTR_HTTP_STATUS_CODE           = "http.response.status_code"
+	ATTR_URL_FULL                   = "url.full"
+	ATTR_ERROR_TYPE                 = "error.type"
+	ATTR_STABILITY_IMAGE_MODEL      = "stability.image.model"
+	ATTR_STABILITY_SEED             = "stability.seed"
+	ATTR_STABILITY_CREDITS          = "stability.credits"
+	ATTR_STABILITY_FINISH_REASON    = "stability.finish_reason"
+)
+
+// Metric names.
+const (
+	// METRIC_OPERATION_DURATION is a histogram of API call latency in seconds.
+	METRIC_OPERATION_DURATION = "gen_ai.client.operation.duration"
+
+	// METRIC_TOKEN_USAGE is a histogram of tokens per call, split by ATTR_GEN_AI_TOKEN_TYPE.
+	METRIC_TOKEN_USAGE = "gen_ai.client.token.usage"
+
+	// METRIC_REQUESTS is a counter of API calls.
+	METRIC_REQUESTS = "gen_ai.client.requests"
+
+	// METRIC_CREDITS is a counter of Stability credits spent.
+	METRIC_CREDITS = "stability.credits"
+)
+
+// Attribute is a key/value pair on a span or measurement. Value is a string, bool, int,
+// int64, float64 or []string.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute.
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Strings returns a string slice attribute.
+func Strings(key string, value ...string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an int attribute.
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Float64 returns a float attribute.
+func Float64(key string, value float64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Bool returns a bool attribute.
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Instrumentation receives spans and metrics from the API clients. Adapt it to
+// OpenTelemetry by starting a trace.Span in Start and recording to metric.Float64Counter
+// and metric.Float64Histogram instruments keyed by name.
+type Instrumentation interface {
+	// Start starts a span. The returned context carries it so nested spans can find
+	// their parent.
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+
+	// Add adds value to a counter.
+	Add(ctx context.Context, name string, value float64, attrs ...Attribute)
+
+	// Record records value in a histogram.
+	Record(ctx context.Context, name string, value float64, attrs ...Attribute)
+}
+
+// Span is a unit of work started by Instrumentation.Start.
+type Span interface {
+	// SetAttributes adds attributes to the span.
+	SetAttributes(attrs ...Attribute)
+
+	// RecordError marks the span as failed.
+	RecordError(err error)
+
+	// End ends the span.
+	End()
+}
+
+// Noop is an Instrumentation that does nothing; the default of every client.
+type Noop struct{}
+
+// Start implements Instrumentation.
+func (Noop) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+// Add implements Instrumentation.
+func (Noop) Add(ctx context.Context, name string, value float64, attrs ...Attribute) {}
+
+// Record implements Instrumentation.
+func (Noop) Record(ctx context.Context, name string, value float64, attrs ...Attribute) {}
+
+// noopSpan is the span of Noop.
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attrs ...Attribute) {}
+func (noopSpan) RecordError(err error)            {}
+func (noopSpan) End()                             {}
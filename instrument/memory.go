@@ -0,0 +1,182 @@
+package instrument
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RecordedSpan is a span kept by Memory.
+type RecordedSpan struct {
+	// Id is the position of the span in Memory.Spans, starting at 1.
+	Id int
+
+	// ParentId is the Id of the span active in the context it was started from, or 0.
+	ParentId int
+
+	Name       string
+	Attributes map[string]interface{}
+	Err        error
+	Start      time.Time
+	End        time.Time
+	Ended      bool
+}
+
+// Measurement is a counter or histogram value kept by Memory.
+type Measurement struct {
+	// Kind is "counter" or "histogram".
+	Kind       string
+	Name       string
+	Value      float64
+	Attributes map[string]interface{}
+}
+
+// Memory is an Instrumentation that keeps everything in memory, for tests and for
+// checking an adapter. It is safe for concurrent use.
+type Memory struct {
+	mu           sync.Mutex
+	spans        []*RecordedSpan
+	measurements []*Measurement
+}
+
+// spanKey is the context key of the active Memory span.
+type spanKey struct{}
+
+// NewMemory creates an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Start implements Instrumentation.
+func (m *Memory) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	span := &RecordedSpan{
+		Id:         len(m.spans) + 1,
+		Name:       name,
+		Attributes: attributes(attrs),
+		Start:      time.Now(),
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*RecordedSpan); ok {
+		span.ParentId = parent.Id
+	}
+	m.spans = append(m.spans, span)
+
+	return context.WithValue(ctx, spanKey{}, span), &memorySpan{memory: m, span: span}
+}
+
+// Add implements Instrumentation.
+func (m *Memory) Add(ctx context.Context, name string, value float64, attrs ...Attribute) {
+	m.measure("counter", name, value, attrs)
+}
+
+// Record implements Instrumentation.
+func (m *Memory) Record(ctx context.Context, name string, value float64, attrs ...Attribute) {
+	m.measure("histogram", name, value, attrs)
+}
+
+// Spans returns copies of the spans started so far.
+func (m *Memory) Spans() []RecordedSpan {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	spans := make([]RecordedSpan, len(m.spans))
+	for i, span := range m.spans {
+		spans[i] = *span
+		spans[i].Attributes = copyAttributes(span.Attributes)
+	}
+	return spans
+}
+
+// Measurements returns the measurements of a metric, in the order they were recorded.
+func (m *Memory) Measurements(name string) []Measurement {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	measurements := []Measurement{}
+	for _, measurement := range m.measurements {
+		if measurement.Name == name {
+			copied := *measurement
+			copied.Attributes = copyAttributes(measurement.Attributes)
+			measurements = append(measurements, copied)
+		}
+	}
+	return measurements
+}
+
+// Sum returns the total of a metric.
+func (m *Memory) Sum(name string) float64 {
+	total := 0.0
+	for _, measurement := range m.Measurements(name) {
+		total += measurement.Value
+	}
+	return total
+}
+
+// Reset drops everything recorded.
+func (m *Memory) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.spans = nil
+	m.measurements = nil
+}
+
+// measure keeps a measurement.
+func (m *Memory) measure(kind string, name string, value float64, attrs []Attribute) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.measurements = append(m.measurements, &Measurement{
+		Kind:       kind,
+		Name:       name,
+		Value:      value,
+		Attributes: attributes(attrs),
+	})
+}
+
+// memorySpan is the Span of Memory.
+type memorySpan struct {
+	memory *Memory
+	span   *RecordedSpan
+}
+
+func (s *memorySpan) SetAttributes(attrs ...Attribute) {
+	s.memory.mu.Lock()
+	defer s.memory.mu.Unlock()
+	for _, attr := range attrs {
+		s.span.Attributes[attr.Key] = attr.Value
+	}
+}
+
+func (s *memorySpan) RecordError(err error) {
+	s.memory.mu.Lock()
+	defer s.memory.mu.Unlock()
+	s.span.Err = err
+}
+
+func (s *memorySpan) End() {
+	s.memory.mu.Lock()
+	defer s.memory.mu.Unlock()
+	if !s.span.Ended {
+		s.span.End = time.Now()
+		s.span.Ended = true
+	}
+}
+
+// attributes converts attributes to a map.
+func attributes(attrs []Attribute) map[string]interface{} {
+	m := make(map[string]interface{}, len(attrs))
+	for _, attr := range attrs {
+		m[attr.Key] = attr.Value
+	}
+	return m
+}
+
+// copyAttributes copies an attribute map.
+func copyAttributes(attrs map[string]interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(attrs))
+	for key, value := range attrs {
+		m[key] = value
+	}
+	return m
+}
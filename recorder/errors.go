@@ -0,0 +1,80 @@
+package recorder
+
+import "fmt"
+
+type ErrMissingFixture struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingFixture) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing fixture file- use WithFixture to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidMode struct {
+	Err  error
+	Msg  string
+	Mode string
+}
+
+func (e *ErrInvalidMode) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid mode"
+	}
+	if e.Mode != "" {
+		msg += fmt.Sprintf(" %q; use MODE_RECORD or MODE_REPLAY", e.Mode)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrFixture struct {
+	Err     error
+	Msg     string
+	Fixture string
+}
+
+func (e *ErrFixture) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "error reading or writing fixture"
+	}
+	if e.Fixture != "" {
+		msg += " " + e.Fixture
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrNoInteraction struct {
+	Err    error
+	Msg    string
+	Method string
+	URL    string
+}
+
+func (e *ErrNoInteraction) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "no recorded interaction left for request"
+	}
+	if e.Method != "" || e.URL != "" {
+		msg += fmt.Sprintf(" %s %s", e.Method, e.URL)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
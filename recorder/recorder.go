@@ -0,0 +1,318 @@
+package recorder
+
+// path: recorder/recorder.go
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// MODULE_NAME is the module name
+const MODULE_NAME = "recorder"
+
+// MODE_RECORD sends requests to the real API and saves every interaction to the fixture.
+const MODE_RECORD = "record"
+
+// MODE_REPLAY serves responses from the fixture and never touches the network.
+const MODE_REPLAY = "replay"
+
+// ENCODING_BASE64 marks a body stored as base64 because it is not valid UTF-8, e.g. an image.
+const ENCODING_BASE64 = "base64"
+
+// SCRUBBED replaces the value of secret headers in fixtures.
+const SCRUBBED = "[scrubbed]"
+
+// SCRUB_HEADERS are the headers scrubbed by default: the Anthropic and Stability API keys.
+var SCRUB_HEADERS = []string{"x-api-key", "authorization"}
+
+// Option is a configuration option.
+type Option func(config *Recorder)
+
+// Recorder is an http.RoundTripper that records interactions to a fixture file or
+// replays them from it. Pass Client() to claude.WithHTTPClient or stability.WithHTTPClient.
+//
+// In replay mode each request is answered by the first unused interaction with the same
+// method and URL, so a test replays the same sequence of calls it recorded.
+type Recorder struct {
+	log       *slog.Logger
+	fixture   *string
+	mode      string
+	transport http.RoundTripper
+	scrub     []string
+
+	mu   sync.Mutex
+	data *Fixture
+	used []bool
+}
+
+// New creates a new Recorder. In replay mode the fixture file must exist.
+func New(opts ...func(*Recorder)) (*Recorder, error) {
+	config := &Recorder{}
+	config.mode = MODE_REPLAY
+	config.transport = http.DefaultTransport
+	config.scrub = SCRUB_HEADERS
+	config.data = &Fixture{}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.fixture == nil {
+		return nil, &ErrMissingFixture{}
+	}
+
+	fqpn, err := filepath.Abs(*config.fixture)
+	if err != nil {
+		return nil, err
+	}
+	config.fixture = &fqpn
+
+	switch config.mode {
+	case MODE_RECORD:
+		// start afresh; the fixture is written after every interaction
+	case MODE_REPLAY:
+		data, err := os.ReadFile(fqpn)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, &ErrMissingFixture{Err: err}
+			}
+			return nil, &ErrFixture{Err: err, Fixture: fqpn}
+		}
+		if err := json.Unmarshal(data, config.data); err != nil {
+			return nil, &ErrFixture{Err: err, Fixture: fqpn}
+		}
+		config.used = make([]bool, len(config.data.Interactions))
+	default:
+		return nil, &ErrInvalidMode{Mode: config.mode}
+	}
+
+	return config, nil
+}
+
+// WithFixture sets the fixture file.
+func WithFixture(fixture string) Option {
+	return func(config *Recorder) {
+		config.fixture = &fixture
+	}
+}
+
+// WithMode sets the mode, MODE_RECORD or MODE_REPLAY. The default is MODE_REPLAY.
+func WithMode(mode string) Option {
+	return func(config *Recorder) {
+		config.mode = mode
+	}
+}
+
+// WithTransport sets the transport used to reach the real API in record mode.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(config *Recorder) {
+		config.transport = transport
+	}
+}
+
+// WithScrubHeaders adds headers whose values are scrubbed from the fixture.
+func WithScrubHeaders(headers ...string) Option {
+	return func(config *Recorder) {
+		config.scrub = append(append([]string{}, config.scrub...), headers...)
+	}
+}
+
+// WithLogger sets the logger.
+func WithLogger(log *slog.Logger) Option {
+	return func(config *Recorder) {
+		moduleLogger := log.With(
+			slog.Group("module_info",
+				slog.String("module", MODULE_NAME),
+			),
+		)
+		config.log = moduleLogger
+	}
+}
+
+// Client returns an HTTP client using the recorder as its transport.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Interactions returns the interactions recorded or loaded so far.
+func (r *Recorder) Interactions() []*Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Interaction{}, r.data.Interactions...)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		requestBody = body
+	}
+
+	if r.mode == MODE_REPLAY {
+		return r.replay(req)
+	}
+	return r.record(req, requestBody)
+}
+
+// record sends the request and saves the interaction.
+func (r *Recorder) record(req *http.Request, requestBody []byte) (*http.Response, error) {
+	outgoing := req.Clone(req.Context())
+	outgoing.Body = io.NopCloser(bytes.NewReader(requestBody))
+	outgoing.ContentLength = int64(len(requestBody))
+
+	resp, err := r.transport.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+
+	// streams are read to the end so they can be stored; the caller still reads them
+	// from the returned body
+	responseBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	interaction := &Interaction{
+		Request: &RecordedRequest{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: r.scrubHeaders(req.Header),
+		},
+		Response: &RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    r.scrubHeaders(resp.Header),
+		},
+	}
+	interaction.Request.Body, interaction.Request.BodyEncoding = encodeBody(requestBody)
+	interaction.Response.Body, interaction.Response.BodyEncoding = encodeBody(responseBody)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.data.Interactions = append(r.data.Interactions, interaction)
+	if err := r.save(); err != nil {
+		return nil, err
+	}
+
+	if r.log != nil {
+		r.log.Debug("recorded interaction",
+			slog.String("method", req.Method),
+			slog.String("url", req.URL.String()),
+			slog.Int("status", resp.StatusCode))
+	}
+
+	return resp, nil
+}
+
+// replay answers the request from the fixture.
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url := req.URL.String()
+	for i, interaction := range r.data.Interactions {
+		if r.used[i] || interaction.Request.Method != req.Method || interaction.Request.URL != url {
+			continue
+		}
+		r.used[i] = true
+
+		body, err := decodeBody(interaction.Response.Body, interaction.Response.BodyEncoding)
+		if err != nil {
+			return nil, &ErrFixture{Err: err, Fixture: *r.fixture}
+		}
+
+		headers := interaction.Response.Headers.Clone()
+		if headers == nil {
+			headers = http.Header{}
+		}
+
+		if r.log != nil {
+			r.log.Debug("replayed interaction",
+				slog.String("method", req.Method),
+				slog.String("url", url),
+				slog.Int("status", interaction.Response.StatusCode))
+		}
+
+		return &http.Response{
+			Status:        http.StatusText(interaction.Response.StatusCode),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        headers,
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, &ErrNoInteraction{Method: req.Method, URL: url}
+}
+
+// save writes the fixture, replacing it atomically. The caller must hold r.mu.
+func (r *Recorder) save() error {
+	data, err := json.MarshalIndent(r.data, "", "  ")
+	if err != nil {
+		return &ErrFixture{Err: err, Fixture: *r.fixture}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(*r.fixture), 0755); err != nil {
+		return &ErrFixture{Err: err, Fixture: *r.fixture}
+	}
+
+	tmp := *r.fixture + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return &ErrFixture{Err: err, Fixture: *r.fixture}
+	}
+	if err := os.Rename(tmp, *r.fixture); err != nil {
+		return &ErrFixture{Err: err, Fixture: *r.fixture}
+	}
+	return nil
+}
+
+// scrubHeaders copies headers, replacing the values of secret headers.
+func (r *Recorder) scrubHeaders(headers http.Header) http.Header {
+	scrubbed := headers.Clone()
+	for key := range scrubbed {
+		for _, secret := range r.scrub {
+			if strings.EqualFold(key, secret) {
+				scrubbed[key] = []string{SCRUBBED}
+			}
+		}
+	}
+	return scrubbed
+}
+
+// encodeBody stores text bodies as they are and binary bodies as base64.
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), ENCODING_BASE64
+}
+
+// decodeBody reverses encodeBody.
+func decodeBody(body string, encoding string) ([]byte, error) {
+	if encoding == ENCODING_BASE64 {
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return []byte(body), nil
+}
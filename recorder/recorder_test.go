@@ -0,0 +1,279 @@
+package recorder_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rmrfslashbin/ami/claude"
+	"github.com/rmrfslashbin/ami/claude/messages"
+	"github.com/rmrfslashbin/ami/recorder"
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/stability/v1/user"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate"
+)
+
+// PNG_MAGIC starts every PNG file.
+var PNG_MAGIC = []byte("\x89PNG\r\n\x1a\n")
+
+func replayer(t *testing.T, fixture string) *recorder.Recorder {
+	t.Helper()
+	rec, err := recorder.New(recorder.WithFixture(fixture))
+	if err != nil {
+		t.Fatalf("recorder.New: %v", err)
+	}
+	return rec
+}
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		run     func(t *testing.T, client *http.Client)
+	}{
+		{name: "claude send and stream", fixture: "testdata/claude.json", run: replayClaude},
+		{name: "stability me and generate", fixture: "testdata/stability.json", run: replayStability},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, replayer(t, tt.fixture).Client())
+		})
+	}
+}
+
+// replayClaude sends a message and then streams one; both interactions are POSTs to the
+// same URL, so they are replayed in the order they were recorded.
+func replayClaude(t *testing.T, client *http.Client) {
+	c, err := claude.New(claude.WithAPIKey("sk-ant-replay"), claude.WithHTTPClient(client))
+	if err != nil {
+		t.Fatalf("claude.New: %v", err)
+	}
+
+	m, err := messages.New(messages.WithClaude(c), messages.WithHaiku())
+	if err != nil {
+		t.Fatalf("messages.New: %v", err)
+	}
+	m.AddRoleUser("Say hello.")
+	res, err := m.Send()
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if len(res.Content) != 1 || res.Content[0].Text != "Hello from the fixture." {
+		t.Errorf("Send content = %+v, want the recorded text", res.Content)
+	}
+
+	m, err = messages.New(messages.WithClaude(c), messages.WithHaiku(), messages.WithStreaming(true))
+	if err != nil {
+		t.Fatalf("messages.New: %v", err)
+	}
+	m.AddRoleUser("Stream something.")
+	stream := m.Stream(context.Background())
+	text := ""
+	for chunk := range stream.Response {
+		if chunk.ContentBlock != nil {
+			text += chunk.ContentBlock.Delta.Text
+		}
+	}
+	select {
+	case err := <-stream.Error:
+		t.Fatalf("Stream: %v", err)
+	default:
+	}
+	if text != "Streamed from the fixture." {
+		t.Errorf("Stream text = %q, want the recorded text", text)
+	}
+}
+
+// replayStability fetches the account, then generates an image as base64 JSON and as raw
+// bytes; the raw response is stored base64 encoded in the fixture.
+func replayStability(t *testing.T, client *http.Client) {
+	s, err := stability.New(stability.WithAPIKey("sk-replay"), stability.WithHTTPClient(client))
+	if err != nil {
+		t.Fatalf("stability.New: %v", err)
+	}
+
+	u, err := user.New(user.WithStability(s), user.WithLogger(s.GetLogger()))
+	if err != nil {
+		t.Fatalf("user.New: %v", err)
+	}
+	me, err := u.Me()
+	if err != nil {
+		t.Fatalf("Me: %v", err)
+	}
+	if me.User.Email != "stabilitytest@example.com" {
+		t.Errorf("Me email = %q, want the recorded email", me.User.Email)
+	}
+
+	g, err := generate.New(generate.WithStability(s), generate.WithLogger(s.GetLogger()),
+		generate.WithPrompt("a lighthouse"), generate.WithSeed(42))
+	if err != nil {
+		t.Fatalf("generate.New: %v", err)
+	}
+	res, err := g.Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if res.Image == nil || *res.Image == "" {
+		t.Errorf("Generate returned no image")
+	}
+	if res.FinishReason == nil || *res.FinishReason != "SUCCESS" {
+		t.Errorf("Generate finish reason = %v, want SUCCESS", res.FinishReason)
+	}
+
+	filename := filepath.Join(t.TempDir(), "raw.png")
+	g.SetOutputFile(filename)
+	res, err = g.Generate()
+	if err != nil {
+		t.Fatalf("Generate raw: %v", err)
+	}
+	if res.Seed == nil || *res.Seed != 42 {
+		t.Errorf("Generate raw seed = %v, want 42", res.Seed)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("reading raw image: %v", err)
+	}
+	if !bytes.HasPrefix(data, PNG_MAGIC) {
+		t.Errorf("raw image is not a PNG: % x", data[:min(len(data), 8)])
+	}
+}
+
+func TestReplayMiss(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		before int
+	}{
+		{name: "unknown url", method: http.MethodGet, url: "https://api.stability.ai/v1/user/balance"},
+		{name: "wrong method", method: http.MethodPost, url: "https://api.stability.ai/v1/user/account"},
+		{name: "wrong host", method: http.MethodGet, url: "https://example.com/v1/user/account"},
+		{name: "interaction used up", method: http.MethodGet, url: "https://api.stability.ai/v1/user/account", before: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := replayer(t, "testdata/stability.json").Client()
+			send := func() (*http.Response, error) {
+				req, err := http.NewRequest(tt.method, tt.url, nil)
+				if err != nil {
+					t.Fatalf("NewRequest: %v", err)
+				}
+				return client.Do(req)
+			}
+
+			for i := 0; i < tt.before; i++ {
+				res, err := send()
+				if err != nil {
+					t.Fatalf("request %d: %v", i, err)
+				}
+				res.Body.Close()
+			}
+
+			res, err := send()
+			if err == nil {
+				res.Body.Close()
+				t.Fatalf("got status %d, want ErrNoInteraction", res.StatusCode)
+			}
+			var noInteraction *recorder.ErrNoInteraction
+			if !errors.As(err, &noInteraction) {
+				t.Fatalf("got %v, want ErrNoInteraction", err)
+			}
+			if noInteraction.Method != tt.method || noInteraction.URL != tt.url {
+				t.Errorf("ErrNoInteraction = %s %s, want %s %s", noInteraction.Method, noInteraction.URL, tt.method, tt.url)
+			}
+		})
+	}
+}
+
+func TestReplayMissingFixture(t *testing.T) {
+	_, err := recorder.New(recorder.WithFixture(filepath.Join(t.TempDir(), "missing.json")))
+	var missing *recorder.ErrMissingFixture
+	if !errors.As(err, &missing) {
+		t.Fatalf("got %v, want ErrMissingFixture", err)
+	}
+}
+
+func TestRecordScrubsHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		opts     []func(*recorder.Recorder)
+		scrubbed bool
+	}{
+		{name: "anthropic api key", header: "x-api-key", value: "sk-ant-secret", scrubbed: true},
+		{name: "stability authorization", header: "Authorization", value: "Bearer sk-secret", scrubbed: true},
+		{name: "extra header", header: "X-Session", value: "secret", opts: []func(*recorder.Recorder){recorder.WithScrubHeaders("x-session")}, scrubbed: true},
+		{name: "plain header", header: "Anthropic-Version", value: "2023-06-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := ""
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r.Header.Get(tt.header)
+				w.Header().Set(tt.header, tt.value)
+				w.Write([]byte(`{"ok":true}`))
+			}))
+			defer server.Close()
+
+			fixture := filepath.Join(t.TempDir(), "fixture.json")
+			opts := append([]func(*recorder.Recorder){recorder.WithFixture(fixture), recorder.WithMode(recorder.MODE_RECORD)}, tt.opts...)
+			rec, err := recorder.New(opts...)
+			if err != nil {
+				t.Fatalf("recorder.New: %v", err)
+			}
+
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatalf("NewRequest: %v", err)
+			}
+			req.Header.Set(tt.header, tt.value)
+			res, err := rec.Client().Do(req)
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			res.Body.Close()
+
+			// the real request is sent unchanged; only the fixture is scrubbed
+			if received != tt.value {
+				t.Errorf("server received %q, want %q", received, tt.value)
+			}
+
+			data, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatalf("reading fixture: %v", err)
+			}
+			saved := &recorder.Fixture{}
+			if err := json.Unmarshal(data, saved); err != nil {
+				t.Fatalf("parsing fixture: %v", err)
+			}
+			if len(saved.Interactions) != 1 {
+				t.Fatalf("fixture has %d interactions, want 1", len(saved.Interactions))
+			}
+			if bytes.Contains(data, []byte(tt.value)) == tt.scrubbed {
+				t.Errorf("fixture contains %q = %v, want %v", tt.value, tt.scrubbed, !tt.scrubbed)
+			}
+
+			want := tt.value
+			if tt.scrubbed {
+				want = recorder.SCRUBBED
+			}
+			interaction := saved.Interactions[0]
+			if got := interaction.Request.Headers.Get(tt.header); got != want {
+				t.Errorf("request header %s = %q, want %q", tt.header, got, want)
+			}
+			if got := interaction.Response.Headers.Get(tt.header); got != want {
+				t.Errorf("response header %s = %q, want %q", tt.header, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package recorder
+
+import "net/http"
+
+// Fixture is the content of a fixture file: the recorded interactions, in the order
+// they happened.
+type Fixture struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a single request and its response.
+type Interaction struct {
+	Request  *RecordedRequest  `json:"request"`
+	Response *RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a recorded HTTP request. Secret headers are scrubbed.
+type RecordedRequest struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Headers      http.Header `json:"headers,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+// RecordedResponse is a recorded HTTP response. Streams (e.g. server-sent events) are
+// stored whole and replayed as one body, which the stream readers parse the same way.
+type RecordedResponse struct {
+	StatusCode   int         `json:"status_code"`
+	Headers      http.Header `json:"headers,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
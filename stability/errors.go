@@ -44,3 +44,23 @@ func (e *ErrHTTP) Error() string {
 	}
 	return e.Msg
 }
+
+type ErrFilePart struct {
+	Err error
+	Msg string
+	Key string
+}
+
+func (e *ErrFilePart) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "unable to read file part"
+	}
+	if e.Key != "" {
+		msg += fmt.Sprintf(" %s", e.Key)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
@@ -0,0 +1,69 @@
+package stability
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmrfslashbin/ami/instrument"
+)
+
+// GEN_AI_SYSTEM is the gen_ai.system attribute of every span.
+const GEN_AI_SYSTEM = "stability.ai"
+
+// WithInstrumentation emits a span and metrics for every API call. The default is
+// instrument.Noop.
+func WithInstrumentation(instrumentation instrument.Instrumentation) Option {
+	return func(config *Stability) {
+		config.instrumentation = instrumentation
+	}
+}
+
+// GetInstrumentation returns the client instrumentation. It is never nil.
+func (stability *Stability) GetInstrumentation() instrument.Instrumentation {
+	return stability.instrumentation
+}
+
+// startSpan starts the span of a single HTTP call.
+func (stability *Stability) startSpan(ctx context.Context, method string, url string) (context.Context, instrument.Span) {
+	return stability.instrumentation.Start(ctx, method+" "+operationName(url),
+		instrument.String(instrument.ATTR_GEN_AI_SYSTEM, GEN_AI_SYSTEM),
+		instrument.String(instrument.ATTR_GEN_AI_OPERATION_NAME, operationName(url)),
+		instrument.String(instrument.ATTR_URL_FULL, url))
+}
+
+// endSpan ends the span of a single HTTP call and records its metrics.
+func (stability *Stability) endSpan(ctx context.Context, span instrument.Span, url string, start time.Time, response *StabilityResponse, err error) {
+	attrs := []instrument.Attribute{
+		instrument.String(instrument.ATTR_GEN_AI_SYSTEM, GEN_AI_SYSTEM),
+		instrument.String(instrument.ATTR_GEN_AI_OPERATION_NAME, operationName(url)),
+	}
+	if response != nil {
+		attrs = append(attrs, instrument.Int(instrument.ATTR_HTTP_STATUS_CODE, response.StatusCode))
+		if response.StatusCode >= 400 {
+			attrs = append(attrs, instrument.String(instrument.ATTR_ERROR_TYPE, strconv.Itoa(response.StatusCode)))
+		}
+	}
+	if err != nil {
+		attrs = append(attrs, instrument.String(instrument.ATTR_ERROR_TYPE, "transport"))
+		span.RecordError(err)
+	}
+
+	span.SetAttributes(attrs...)
+	span.End()
+
+	stability.instrumentation.Add(ctx, instrument.METRIC_REQUESTS, 1, attrs...)
+	stability.instrumentation.Record(ctx, instrument.METRIC_OPERATION_DURATION, time.Since(start).Seconds(), attrs...)
+}
+
+// operationName returns the endpoint of a URL relative to the API version, e.g.
+// "stable-image/generate/sd3".
+func operationName(url string) string {
+	for _, version := range []string{"/v2beta/", "/v1/"} {
+		if _, path, ok := strings.Cut(url, version); ok {
+			return path
+		}
+	}
+	return url
+}
@@ -0,0 +1,89 @@
+package stability
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// REDACTED replaces secrets and binary payloads in logs.
+const REDACTED = "[redacted]"
+
+// WithDebugBodies logs the form fields of every request at debug level. The authorization
+// header is never logged. It is off by default.
+func WithDebugBodies(enabled bool) Option {
+	return func(config *Stability) {
+		config.debugBodies = enabled
+	}
+}
+
+// discardLogger returns a logger that drops every record; the default when no logger is set.
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// GetLogger returns the client logger. It is never nil.
+func (stability *Stability) GetLogger() *slog.Logger {
+	return stability.log
+}
+
+// logRequest logs the end of an API call: at info level for 2xx, warn for 4xx and error
+// otherwise.
+func (stability *Stability) logRequest(method string, url string, start time.Time, response *StabilityResponse, err error) {
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("url", url),
+		slog.Duration("latency", time.Since(start)),
+	}
+
+	level := slog.LevelInfo
+	message := "api request completed"
+	if response != nil {
+		attrs = append(attrs,
+			slog.Int("status", response.StatusCode),
+			slog.Int64("bytes", int64(len(response.Body))+response.Written))
+		if contentType := response.Header("content-type"); contentType != "" {
+			attrs = append(attrs, slog.String("content_type", contentType))
+		}
+		if requestId := response.Header("x-request-id"); requestId != "" {
+			attrs = append(attrs, slog.String("request_id", requestId))
+		}
+		switch {
+		case response.StatusCode >= 500:
+			level = slog.LevelError
+			message = "api request failed"
+		case response.StatusCode >= 400:
+			level = slog.LevelWarn
+			message = "api request failed"
+		}
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+		level = slog.LevelError
+		message = "api request failed"
+	}
+
+	stability.log.LogAttrs(context.Background(), level, message, attrs...)
+}
+
+// logFormParts logs the form fields of a request at debug level if WithDebugBodies is set.
+func (stability *Stability) logFormParts(formParts []FormPart) {
+	if !stability.debugBodies {
+		return
+	}
+	fields := []any{}
+	for _, part := range formParts {
+		switch v := part.Value.(type) {
+		case *FilePart:
+			fields = append(fields, slog.String(part.Key, fmt.Sprintf("%s file %s", REDACTED, v.describe())))
+		case []byte:
+			fields = append(fields, slog.String(part.Key, fmt.Sprintf("%s %d bytes", REDACTED, len(v))))
+		default:
+			fields = append(fields, slog.String(part.Key, fmt.Sprint(v)))
+		}
+	}
+	stability.log.LogAttrs(context.Background(), slog.LevelDebug, "api request form",
+		slog.Group("form", fields...))
+}
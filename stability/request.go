@@ -0,0 +1,324 @@
+package stability
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// SNIFF_LENGTH is the number of leading bytes read to detect a file content type.
+const SNIFF_LENGTH = 3072
+
+// FormPart is a field of a multipart form. Value is a *FilePart for files; anything else
+// is sent as text.
+type FormPart struct {
+	Key   string
+	Value interface{}
+}
+
+// FilePart is a file field of a multipart form. Set one of Path, Data or Reader.
+type FilePart struct {
+	// Path is a file to read. It is opened when the request is sent.
+	Path string
+
+	// Data is the file content.
+	Data []byte
+
+	// Reader supplies the file content. It is read once, while the request is sent.
+	Reader io.Reader
+
+	// Filename is the file name sent. The default is the base of Path, or the form key.
+	Filename string
+
+	// ContentType is the MIME type sent. The default is detected from the content.
+	ContentType string
+}
+
+// Request is a single API call. Build one per call with NewRequest: it owns its headers
+// and form parts, so one Stability client can be reused and shared by goroutines.
+type Request struct {
+	// Method is the HTTP method.
+	Method HttpMethod
+
+	// URL is the full URL called.
+	URL string
+
+	// Headers are the request headers, starting with the client defaults.
+	Headers map[string]string
+
+	// FormParts are the multipart form fields, sent in order. A request without parts has
+	// no body.
+	FormParts []FormPart
+}
+
+// NewRequest returns a request for an API path, e.g. "/v1/user/account", with a copy of
+// the client headers.
+func (stability *Stability) NewRequest(method HttpMethod, path string) *Request {
+	headers := make(map[string]string, len(stability.headers))
+	for key, value := range stability.headers {
+		headers[key] = value
+	}
+	return &Request{
+		Method:  method,
+		URL:     stability.URL(path),
+		Headers: headers,
+	}
+}
+
+// AddFormPart appends a form field.
+func (request *Request) AddFormPart(key string, value interface{}) {
+	request.FormParts = append(request.FormParts, FormPart{Key: key, Value: value})
+}
+
+// AddFile appends a file field read from a path.
+func (request *Request) AddFile(key string, path string) {
+	request.AddFormPart(key, &FilePart{Path: path})
+}
+
+// AddFileBytes appends a file field holding data.
+func (request *Request) AddFileBytes(key string, data []byte, filename string) {
+	request.AddFormPart(key, &FilePart{Data: data, Filename: filename})
+}
+
+// AddFileReader appends a file field streamed from r.
+func (request *Request) AddFileReader(key string, r io.Reader, filename string) {
+	request.AddFormPart(key, &FilePart{Reader: r, Filename: filename})
+}
+
+// AddHeader sets a header, replacing any default.
+func (request *Request) AddHeader(key string, value string) {
+	request.Headers[key] = value
+}
+
+// HasFiles reports whether the form has file parts.
+func (request *Request) HasFiles() bool {
+	for _, part := range request.FormParts {
+		if _, ok := part.Value.(*FilePart); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MakeFormBody encodes the form parts and returns the body, its content type and its
+// length. It returns a nil body and content type if there are none. Forms with files are
+// streamed through a pipe rather than buffered; files given by path are opened first so a
+// missing file fails before anything is sent. The length is -1, for chunked encoding, only
+// if a file comes from a Reader.
+func (request *Request) MakeFormBody() (io.ReadCloser, *string, int64, error) {
+	if len(request.FormParts) == 0 {
+		return nil, nil, 0, nil
+	}
+
+	if !request.HasFiles() {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		for _, part := range request.FormParts {
+			writer.WriteField(part.Key, fmt.Sprint(part.Value))
+		}
+		contentType := writer.FormDataContentType()
+		writer.Close()
+		return io.NopCloser(body), &contentType, int64(body.Len()), nil
+	}
+
+	// open every file up front, sniffing those of known size
+	files := make([]*openFile, len(request.FormParts))
+	closers := []io.Closer{}
+	closeAll := func() {
+		for _, closer := range closers {
+			closer.Close()
+		}
+	}
+	for i, part := range request.FormParts {
+		file, ok := part.Value.(*FilePart)
+		if !ok {
+			continue
+		}
+		opened, closer, err := file.open()
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+		if err == nil && opened.size >= 0 {
+			err = opened.sniff(file.ContentType)
+		}
+		if err != nil {
+			closeAll()
+			return nil, nil, 0, &ErrFilePart{Err: err, Key: part.Key}
+		}
+		files[i] = opened
+	}
+
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	contentType := writer.FormDataContentType()
+	contentLength := request.formLength(files, writer.Boundary())
+
+	go func() {
+		defer closeAll()
+		for i, part := range request.FormParts {
+			file, ok := part.Value.(*FilePart)
+			if !ok {
+				if err := writer.WriteField(part.Key, fmt.Sprint(part.Value)); err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+				continue
+			}
+			if err := file.write(writer, part.Key, files[i]); err != nil {
+				pw.CloseWithError(&ErrFilePart{Err: err, Key: part.Key})
+				return
+			}
+		}
+		pw.CloseWithError(writer.Close())
+	}()
+
+	return pr, &contentType, contentLength, nil
+}
+
+// openFile is a file part opened for sending.
+type openFile struct {
+	// source is the file content after head.
+	source io.Reader
+
+	// size is the content length, or -1 for readers.
+	size int64
+
+	// head and contentType are set once the content is sniffed.
+	head        []byte
+	contentType string
+	sniffed     bool
+}
+
+// open opens the content of a file part and, for paths, returns the file to close.
+func (file *FilePart) open() (*openFile, io.Closer, error) {
+	switch {
+	case file.Path != "":
+		f, err := os.Open(file.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			return nil, f, err
+		}
+		return &openFile{source: f, size: info.Size()}, f, nil
+	case file.Data != nil:
+		return &openFile{source: bytes.NewReader(file.Data), size: int64(len(file.Data))}, nil, nil
+	case file.Reader != nil:
+		return &openFile{source: file.Reader, size: -1}, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("no path, data or reader")
+	}
+}
+
+// sniff reads the first bytes of the content and detects its content type, unless
+// contentType is set.
+func (opened *openFile) sniff(contentType string) error {
+	if opened.sniffed {
+		return nil
+	}
+	head := make([]byte, SNIFF_LENGTH)
+	n, err := io.ReadFull(opened.source, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	opened.head = head[:n]
+	opened.contentType = contentType
+	if opened.contentType == "" {
+		opened.contentType = mimetype.Detect(opened.head).String()
+	}
+	opened.sniffed = true
+	return nil
+}
+
+// header returns the part header of a file part.
+func (file *FilePart) header(key string, contentType string) textproto.MIMEHeader {
+	filename := file.Filename
+	if filename == "" && file.Path != "" {
+		filename = filepath.Base(file.Path)
+	}
+	if filename == "" {
+		filename = key
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		escapeQuotes(key), escapeQuotes(filename)))
+	header.Set("Content-Type", contentType)
+	return header
+}
+
+// write copies a file part into the form, sniffing it first if that was not done yet.
+func (file *FilePart) write(writer *multipart.Writer, key string, opened *openFile) error {
+	if err := opened.sniff(file.ContentType); err != nil {
+		return err
+	}
+	part, err := writer.CreatePart(file.header(key, opened.contentType))
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(opened.head); err != nil {
+		return err
+	}
+	_, err = io.Copy(part, opened.source)
+	return err
+}
+
+// formLength computes the length of a form with files by encoding it without the file
+// content, using the same boundary, and adding the file sizes. It returns -1 if a file
+// size is unknown.
+func (request *Request) formLength(files []*openFile, boundary string) int64 {
+	counter := &countingWriter{}
+	writer := multipart.NewWriter(counter)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return -1
+	}
+
+	var length int64
+	for i, part := range request.FormParts {
+		file, ok := part.Value.(*FilePart)
+		if !ok {
+			writer.WriteField(part.Key, fmt.Sprint(part.Value))
+			continue
+		}
+		if files[i].size < 0 {
+			return -1
+		}
+		writer.CreatePart(file.header(part.Key, files[i].contentType))
+		length += files[i].size
+	}
+	writer.Close()
+	return length + counter.n
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// describe names the source of a file part for logs.
+func (file *FilePart) describe() string {
+	switch {
+	case file.Path != "":
+		return file.Path
+	case file.Data != nil:
+		return fmt.Sprintf("%d bytes", len(file.Data))
+	default:
+		return "from reader"
+	}
+}
+
+// escapeQuotes escapes a Content-Disposition parameter as mime/multipart does.
+var escapeQuotes = strings.NewReplacer("\\", "\\\\", `"`, "\\\"").Replace
@@ -1,12 +1,14 @@
 package stability
 
 import (
-	"bytes"
-	"fmt"
+	"context"
 	"io"
 	"log/slog"
-	"mime/multipart"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rmrfslashbin/ami/instrument"
 )
 
 // Path: stability/stabilityV2.go
@@ -15,10 +17,14 @@ type Option func(config *Stability)
 
 // Configuration structure.
 type Stability struct {
-	apikey    *string
-	log       *slog.Logger
-	headers   map[string]string
-	formParts []map[string]interface{}
+	apikey     *string
+	log        *slog.Logger
+	headers    map[string]string
+	httpClient *http.Client
+	baseURL    string
+
+	debugBodies     bool
+	instrumentation instrument.Instrumentation
 }
 
 func New(opts ...func(*Stability)) (*Stability, error) {
@@ -26,6 +32,7 @@ func New(opts ...func(*Stability)) (*Stability, error) {
 
 	// init headers
 	config.headers = make(map[string]string)
+	config.baseURL = ENDPOINT_ROOT
 
 	// apply the list of options to Config
 	for _, opt := range opts {
@@ -36,6 +43,18 @@ func New(opts ...func(*Stability)) (*Stability, error) {
 		return nil, &ErrMissingAPIKey{}
 	}
 
+	if config.httpClient == nil {
+		config.httpClient = &http.Client{}
+	}
+
+	if config.log == nil {
+		config.log = discardLogger()
+	}
+
+	if config.instrumentation == nil {
+		config.instrumentation = instrument.Noop{}
+	}
+
 	config.headers["authorization"] = "Bearer " + *config.apikey
 	//config.headers["content-type"] = "multipart/form-data"
 	config.headers["accept"] = "image/png" // default to png
@@ -59,65 +78,107 @@ func WithLogger(log *slog.Logger) Option {
 	}
 }
 
-func (stability *Stability) AddFormPart(key string, value interface{}) {
-	stability.formParts = append(stability.formParts, map[string]interface{}{
-		key: value,
-	})
+// WithHTTPClient sets the HTTP client used for every request, e.g. one with a recording
+// transport from the recorder package.
+func WithHTTPClient(client *http.Client) Option {
+	return func(config *Stability) {
+		config.httpClient = client
+	}
 }
 
-func (stability *Stability) AddHeader(key string, value string) {
-	stability.headers[key] = value
+// WithBaseURL points the client at another server, e.g. a stabilitytest fake. The default
+// is ENDPOINT_ROOT.
+func WithBaseURL(baseURL string) Option {
+	return func(config *Stability) {
+		config.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
 }
 
-func (stability *Stability) MakeFormBody() (*bytes.Buffer, *string) {
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
+// URL returns the URL of an API path, e.g. "/v1/user/account", on the configured base URL.
+func (stability *Stability) URL(path string) string {
+	return stability.baseURL + path
+}
 
-	for _, part := range stability.formParts {
-		for key, value := range part {
-			writer.WriteField(key, fmt.Sprint(value))
-		}
-	}
-	contentType := writer.FormDataContentType()
-	writer.Close()
-	return body, &contentType
+// Do sends a request built with NewRequest.
+func (stability *Stability) Do(request *Request) (*StabilityResponse, error) {
+	return stability.DoContext(context.Background(), request)
 }
 
-func (stability *Stability) Do(url *string, httpMethod HttpMethod) (*StabilityResponse, error) {
-	method := string(httpMethod)
-	body, contentType := stability.MakeFormBody()
+// DoContext is Do, with the span of the call started as a child of any span in ctx.
+func (stability *Stability) DoContext(ctx context.Context, request *Request) (*StabilityResponse, error) {
+	return stability.do(ctx, request, nil)
+}
+
+// DoStream is DoContext, but copies a successful response body to w instead of holding it
+// in memory. The response then has a nil Body and the number of bytes copied in Written.
+// Error responses are read into Body as usual.
+func (stability *Stability) DoStream(ctx context.Context, request *Request, w io.Writer) (*StabilityResponse, error) {
+	return stability.do(ctx, request, w)
+}
 
-	req, err := http.NewRequest(method, *url, body)
+// do sends a request, streaming a successful response body to w if it is set.
+func (stability *Stability) do(ctx context.Context, request *Request, w io.Writer) (response *StabilityResponse, err error) {
+	method := string(request.Method)
+	url := request.URL
+	start := time.Now()
+	stability.log.LogAttrs(ctx, slog.LevelDebug, "api request started",
+		slog.String("method", method),
+		slog.String("url", url))
+	stability.logFormParts(request.FormParts)
+
+	ctx, span := stability.startSpan(ctx, method, url)
+	defer func() {
+		stability.endSpan(ctx, span, url, start, response, err)
+	}()
+
+	body, contentType, contentLength, err := request.MakeFormBody()
 	if err != nil {
 		return nil, err
 	}
 
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		if body != nil {
+			body.Close()
+		}
+		return nil, err
+	}
+
 	// Set headers
-	for key, value := range stability.headers {
+	for key, value := range request.Headers {
 		req.Header.Set(key, value)
 	}
 
-	req.Header.Set("Content-Type", *contentType)
+	if contentType != nil {
+		req.Header.Set("Content-Type", *contentType)
+	}
 
-	// Create an HTTP client
-	client := &http.Client{}
+	// a form of known length is sent with a Content-Length; otherwise it is chunked
+	if body != nil && contentLength >= 0 {
+		req.ContentLength = contentLength
+	}
 
 	// Send the request
-	resp, err := client.Do(req)
+	resp, err := stability.httpClient.Do(req)
 	if err != nil {
+		stability.logRequest(method, url, start, nil, err)
 		return nil, err
 	}
 
 	defer resp.Body.Close()
 
-	responseBody, err := io.ReadAll(resp.Body)
+	response = &StabilityResponse{}
+	response.StatusCode = resp.StatusCode
+	if w != nil && resp.StatusCode == http.StatusOK {
+		response.Written, err = io.Copy(w, resp.Body)
+	} else {
+		response.Body, err = io.ReadAll(resp.Body)
+	}
 	if err != nil {
+		stability.logRequest(method, url, start, nil, err)
 		return nil, err
 	}
 
-	response := &StabilityResponse{}
-	response.StatusCode = resp.StatusCode
-	response.Body = responseBody
 	responseHeaders := map[string][]string{}
 
 	for key, value := range resp.Header {
@@ -125,6 +186,7 @@ func (stability *Stability) Do(url *string, httpMethod HttpMethod) (*StabilityRe
 	}
 
 	response.Headers = responseHeaders
+	stability.logRequest(method, url, start, response, nil)
 
 	return response, nil
 }
@@ -0,0 +1,352 @@
+package stability_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/stability/stabilitytest"
+	"github.com/rmrfslashbin/ami/stability/v1/user"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate"
+)
+
+var log = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func newServer(t *testing.T) (*stabilitytest.Server, *stability.Stability) {
+	t.Helper()
+	server, err := stabilitytest.New()
+	if err != nil {
+		t.Fatalf("stabilitytest.New: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	s, err := server.Client(stability.WithLogger(log))
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	return server, s
+}
+
+func TestNewRequest(t *testing.T) {
+	_, s := newServer(t)
+
+	request := s.NewRequest(stability.METHOD_POST, generate.PATH)
+	request.AddHeader("accept", "application/json")
+	request.AddFormPart("prompt", "a")
+
+	// the request owns copies of the client defaults
+	if other := s.NewRequest(stability.METHOD_GET, user.PATH_USER); other.Headers["accept"] != "image/png" || len(other.FormParts) != 0 {
+		t.Errorf("second request = %+v, want the client defaults", other)
+	}
+	if request.URL != s.URL(generate.PATH) || request.Headers["authorization"] != "Bearer "+stabilitytest.API_KEY {
+		t.Errorf("request = %+v", request)
+	}
+}
+
+func TestReuseClient(t *testing.T) {
+	server, s := newServer(t)
+
+	u, err := user.New(user.WithStability(s), user.WithLogger(log))
+	if err != nil {
+		t.Fatalf("user.New: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			g, err := generate.New(generate.WithStability(s), generate.WithLogger(log), generate.WithPrompt(fmt.Sprintf("prompt %d", i)))
+			if err != nil {
+				errs <- err
+				return
+			}
+			if _, err := g.Generate(); err != nil {
+				errs <- err
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := u.Me(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("request: %v", err)
+	}
+
+	// no request saw another's form parts or headers
+	if err := server.Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	for _, received := range server.Requests() {
+		if received.Method == "GET" && (len(received.Form) != 0 || received.Header.Get("content-type") != "") {
+			t.Errorf("GET %s carried a form: %v", received.Path, received.Form)
+		}
+	}
+}
+
+// pngImage returns a small PNG.
+func pngImage(t *testing.T) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("encoding PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// formPart is a decoded multipart part.
+type formPart struct {
+	name        string
+	filename    string
+	contentType string
+	body        string
+}
+
+// readForm decodes the body of a request.
+func readForm(t *testing.T, request *stability.Request) []formPart {
+	t.Helper()
+	body, contentType, _, err := request.MakeFormBody()
+	if err != nil {
+		t.Fatalf("MakeFormBody: %v", err)
+	}
+	defer body.Close()
+
+	_, params, err := mime.ParseMediaType(*contentType)
+	if err != nil {
+		t.Fatalf("content type %q: %v", *contentType, err)
+	}
+	reader := multipart.NewReader(body, params["boundary"])
+	parts := []formPart{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("reading part %s: %v", part.FormName(), err)
+		}
+		parts = append(parts, formPart{
+			name:        part.FormName(),
+			filename:    part.FileName(),
+			contentType: part.Header.Get("Content-Type"),
+			body:        string(data),
+		})
+	}
+}
+
+func TestMakeFormBody(t *testing.T) {
+	_, s := newServer(t)
+	img := pngImage(t)
+	path := filepath.Join(t.TempDir(), "cat.png")
+	if err := os.WriteFile(path, img, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	request := s.NewRequest(stability.METHOD_POST, generate.PATH)
+	request.AddFormPart("prompt", "a cat")
+	request.AddFile("image", path)
+	request.AddFileBytes("mask", img, "")
+	request.AddFileReader("style", strings.NewReader("plain text"), "style.txt")
+	request.AddFormPart("image", &stability.FilePart{Data: img, Filename: "typed.bin", ContentType: "application/octet-stream"})
+	request.AddFormPart("seed", 42)
+
+	want := []formPart{
+		{name: "prompt", body: "a cat"},
+		{name: "image", filename: "cat.png", contentType: "image/png", body: string(img)},
+		{name: "mask", filename: "mask", contentType: "image/png", body: string(img)},
+		{name: "style", filename: "style.txt", contentType: "text/plain; charset=utf-8", body: "plain text"},
+		{name: "image", filename: "typed.bin", contentType: "application/octet-stream", body: string(img)},
+		{name: "seed", body: "42"},
+	}
+	got := readForm(t, request)
+	if len(got) != len(want) {
+		t.Fatalf("form has %d parts, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("part %d = %s %q %q (%d bytes), want %s %q %q (%d bytes)", i,
+				got[i].name, got[i].filename, got[i].contentType, len(got[i].body),
+				want[i].name, want[i].filename, want[i].contentType, len(want[i].body))
+		}
+	}
+}
+
+func TestMakeFormBodyErrors(t *testing.T) {
+	_, s := newServer(t)
+
+	request := s.NewRequest(stability.METHOD_GET, user.PATH_USER)
+	if body, contentType, length, err := request.MakeFormBody(); body != nil || contentType != nil || length != 0 || err != nil {
+		t.Errorf("MakeFormBody without parts = %v, %v, %d, %v, want nothing", body, contentType, length, err)
+	}
+
+	for name, file := range map[string]*stability.FilePart{
+		"missing path": {Path: filepath.Join(t.TempDir(), "missing.png")},
+		"empty":        {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			request := s.NewRequest(stability.METHOD_POST, generate.PATH)
+			request.AddFormPart("prompt", "a cat")
+			request.AddFormPart("image", file)
+
+			var filePart *stability.ErrFilePart
+			if _, _, _, err := request.MakeFormBody(); !errors.As(err, &filePart) || filePart.Key != "image" {
+				t.Errorf("MakeFormBody = %v, want ErrFilePart for image", err)
+			}
+			if _, err := s.Do(request); !errors.As(err, &filePart) {
+				t.Errorf("Do = %v, want ErrFilePart", err)
+			}
+		})
+	}
+}
+
+func TestFormLength(t *testing.T) {
+	server, s := newServer(t)
+	img := pngImage(t)
+	path := filepath.Join(t.TempDir(), "cat.png")
+	if err := os.WriteFile(path, img, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		file   *stability.FilePart
+		length bool
+	}{
+		{name: "path", file: &stability.FilePart{Path: path}, length: true},
+		{name: "data", file: &stability.FilePart{Data: img}, length: true},
+		// the overridden content type replaces the sniffed one in the part header
+		{name: "content type override", file: &stability.FilePart{Data: img, Filename: "cat.bin", ContentType: "application/x-very-long-content-type"}, length: true},
+		{name: "reader", file: &stability.FilePart{Reader: bytes.NewReader(img)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := s.NewRequest(stability.METHOD_POST, generate.PATH)
+			request.AddHeader("accept", "application/json")
+			request.AddFormPart("prompt", "a cat")
+			request.AddFormPart("mode", "image-to-image")
+			request.AddFormPart("strength", 0.5)
+			request.AddFormPart("image", tt.file)
+
+			body, _, length, err := request.MakeFormBody()
+			if err != nil {
+				t.Fatalf("MakeFormBody: %v", err)
+			}
+			written, err := io.Copy(io.Discard, body)
+			body.Close()
+			if err != nil {
+				t.Fatalf("reading body: %v", err)
+			}
+			if tt.length && length != written {
+				t.Errorf("length = %d, body has %d bytes", length, written)
+			}
+			if !tt.length && length != -1 {
+				t.Errorf("length = %d, want -1 for a reader", length)
+			}
+
+			if tt.file.Reader != nil {
+				tt.file.Reader = bytes.NewReader(img)
+			}
+			res, err := s.Do(request)
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			if res.StatusCode != 200 {
+				t.Fatalf("status = %d: %s", res.StatusCode, res.Body)
+			}
+			received := server.LastRequest()
+			if received.ContentLength != length {
+				t.Errorf("Content-Length sent = %d, want %d", received.ContentLength, length)
+			}
+			if files := received.Files["image"]; len(files) != 1 || files[0].Size != int64(len(img)) ||
+				(tt.file.ContentType != "" && files[0].Header.Get("Content-Type") != tt.file.ContentType) {
+				t.Errorf("received files = %+v", files)
+			}
+		})
+	}
+}
+
+func TestDoWithFiles(t *testing.T) {
+	server, s := newServer(t)
+	img := pngImage(t)
+
+	request := s.NewRequest(stability.METHOD_POST, generate.PATH)
+	request.AddHeader("accept", "application/json")
+	request.AddFormPart("prompt", "a cat")
+	request.AddFormPart("mode", "image-to-image")
+	request.AddFormPart("strength", 0.5)
+	request.AddFileReader("image", bytes.NewReader(img), "cat.png")
+
+	res, err := s.Do(request)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("status = %d: %s", res.StatusCode, res.Body)
+	}
+	files := server.LastRequest().Files["image"]
+	if len(files) != 1 || files[0].Filename != "cat.png" || files[0].Size != int64(len(img)) || files[0].Header.Get("Content-Type") != "image/png" {
+		t.Errorf("received files = %+v", files)
+	}
+	if err := server.Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestDoStream(t *testing.T) {
+	server, s := newServer(t)
+
+	request := s.NewRequest(stability.METHOD_POST, generate.PATH)
+	request.AddHeader("accept", generate.ACCEPT_IMAGE)
+	request.AddFormPart("prompt", "a cat")
+	request.AddFormPart("seed", 5)
+
+	buf := &bytes.Buffer{}
+	res, err := s.DoStream(context.Background(), request, buf)
+	if err != nil {
+		t.Fatalf("DoStream: %v", err)
+	}
+	if res.StatusCode != 200 || res.Body != nil || res.Written != int64(buf.Len()) || res.ContentType() != "image/png" {
+		t.Errorf("DoStream = %d, %d body bytes, %d written of %d, %s", res.StatusCode, len(res.Body), res.Written, buf.Len(), res.ContentType())
+	}
+	if seed, ok := res.Seed(); !ok || seed != 5 || res.FinishReason() != "SUCCESS" {
+		t.Errorf("DoStream headers = seed %d, finish reason %q", seed, res.FinishReason())
+	}
+	if _, err := png.DecodeConfig(buf); err != nil {
+		t.Errorf("streamed image: %v", err)
+	}
+
+	// error bodies are read, not streamed
+	server.Enqueue(stabilitytest.Moderated())
+	buf.Reset()
+	res, err = s.DoStream(context.Background(), request, buf)
+	if err != nil {
+		t.Fatalf("DoStream: %v", err)
+	}
+	if res.StatusCode != 403 || len(res.Body) == 0 || res.Written != 0 || buf.Len() != 0 {
+		t.Errorf("DoStream = %d, %d body bytes, %d written, %d streamed", res.StatusCode, len(res.Body), res.Written, buf.Len())
+	}
+}
@@ -0,0 +1,60 @@
+package stabilitytest
+
+import "fmt"
+
+type ErrInvalidRequest struct {
+	Err   error
+	Msg   string
+	Index int
+}
+
+func (e *ErrInvalidRequest) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid request"
+	}
+	msg += fmt.Sprintf(" #%d", e.Index)
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidField struct {
+	Err   error
+	Msg   string
+	Field string
+	Value string
+}
+
+func (e *ErrInvalidField) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid form field"
+	}
+	if e.Field != "" {
+		msg += fmt.Sprintf(" %s=%q", e.Field, e.Value)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrUnusedReplies struct {
+	Err   error
+	Msg   string
+	Count int
+}
+
+func (e *ErrUnusedReplies) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "scripted replies were never requested"
+	}
+	msg += fmt.Sprintf(": %d", e.Count)
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
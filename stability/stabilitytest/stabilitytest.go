@@ -0,0 +1,993 @@
+package stabilitytest
+
+// path: stability/stabilitytest/stabilitytest.go
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/stability/v1/user"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/imageToVideo"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/results"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableFast3d"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/control"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/edit"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate/core"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate/ultra"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/upscale"
+)
+
+// MODULE_NAME is the module name
+const MODULE_NAME = "stabilitytest"
+
+// API_KEY is the API key Client uses. The server accepts any bearer token.
+const API_KEY = "stabilitytest-api-key"
+
+// MAX_REQUEST_BYTES is the request size above which the API answers 413.
+const MAX_REQUEST_BYTES = 10 * 1024 * 1024
+
+// DEFAULT_CREDITS is the starting balance of the fake account.
+const DEFAULT_CREDITS = 100.0
+
+// DEFAULT_PENDING_POLLS is how many polls of an asynchronous result answer 202 before
+// it is ready.
+const DEFAULT_PENDING_POLLS = 1
+
+// IMAGE_SIZE is the width and height of the generated placeholder images.
+const IMAGE_SIZE = 64
+
+// GENERATE_FIELDS are the form fields the sd3 endpoint accepts.
+var GENERATE_FIELDS = []string{"prompt", "aspect_ratio", "mode", "negative_prompt", "model", "seed", "output_format", "strength"}
+
+// CORE_FIELDS are the form fields the core endpoint accepts.
+var CORE_FIELDS = []string{"prompt", "aspect_ratio", "negative_prompt", "seed", "style_preset", "output_format"}
+
+// ULTRA_FIELDS are the form fields the ultra endpoint accepts.
+var ULTRA_FIELDS = []string{"prompt", "aspect_ratio", "negative_prompt", "seed", "output_format", "strength"}
+
+// EDIT_FIELDS are the form fields each edit endpoint accepts besides image and output_format.
+var EDIT_FIELDS = map[string][]string{
+	"inpaint":            {"prompt", "negative_prompt", "grow_mask", "seed"},
+	"outpaint":           {"left", "right", "up", "down", "prompt", "creativity", "seed"},
+	"erase":              {"grow_mask", "seed"},
+	"search-and-replace": {"prompt", "search_prompt", "negative_prompt", "grow_mask", "seed"},
+	"search-and-recolor": {"prompt", "select_prompt", "negative_prompt", "grow_mask", "seed"},
+	"remove-background":  {},
+}
+
+// UPSCALE_FIELDS are the form fields each upscale endpoint accepts besides image and
+// output_format.
+var UPSCALE_FIELDS = map[string][]string{
+	"conservative": {"prompt", "negative_prompt", "creativity", "seed"},
+	"fast":         {},
+	"creative":     {"prompt", "negative_prompt", "creativity", "style_preset", "seed"},
+}
+
+// CONTROL_FIELDS are the form fields each control endpoint accepts besides image and
+// output_format.
+var CONTROL_FIELDS = map[string][]string{
+	"sketch":    {"prompt", "control_strength", "negative_prompt", "seed"},
+	"structure": {"prompt", "control_strength", "negative_prompt", "seed"},
+	"style":     {"prompt", "fidelity", "negative_prompt", "aspect_ratio", "seed"},
+}
+
+// VIDEO_FIELDS are the form fields the image-to-video endpoint accepts besides image.
+var VIDEO_FIELDS = []string{"seed", "cfg_scale", "motion_bucket_id"}
+
+// MODEL_FIELDS are the form fields the stable-fast-3d endpoint accepts besides image.
+var MODEL_FIELDS = []string{"texture_resolution", "foreground_ratio", "remesh"}
+
+// mp4Video is the start of an MP4 file; it is not playable.
+var mp4Video = []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom")
+
+// webpImage is a 1x1 lossless WebP; the standard library has no WebP encoder.
+var webpImage, _ = base64.StdEncoding.DecodeString("UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA==")
+
+// Option is a configuration option.
+type Option func(config *Server)
+
+// Server is an in-process fake of the Stability API, built on httptest. It serves the
+// account, balance, sd3, core and ultra generation, edit, upscale, control,
+// image-to-video, stable-fast-3d and results endpoints,
+// checking the multipart form of every request. Generations succeed unless a Reply is
+// scripted with Enqueue; asynchronous results are pending for a number of polls first.
+type Server struct {
+	log     *slog.Logger
+	account *user.ResponseUser
+	checks  []func(*ReceivedRequest) error
+	pending int
+
+	mu       sync.Mutex
+	credits  float64
+	replies  []*Reply
+	requests []*ReceivedRequest
+	errs     []error
+	results  map[string]*result
+
+	server *httptest.Server
+}
+
+// New starts a new Server. Close it when done.
+func New(opts ...func(*Server)) (*Server, error) {
+	config := &Server{}
+	config.credits = DEFAULT_CREDITS
+	config.pending = DEFAULT_PENDING_POLLS
+	config.results = map[string]*result{}
+	config.account = &user.ResponseUser{
+		Id:    "user-stabilitytest",
+		Email: "stabilitytest@example.com",
+		Organizations: []user.ResponseUserOrg{
+			{Id: "org-stabilitytest", Name: "stabilitytest", Role: "OWNER", IsDefault: true},
+		},
+	}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+user.PATH_USER, config.handleAccount)
+	mux.HandleFunc("GET "+user.PATH_USER_BALANCE, config.handleBalance)
+	mux.HandleFunc("POST "+generate.PATH, config.handleGenerate)
+	mux.HandleFunc("POST "+core.PATH, config.handleGenerate)
+	mux.HandleFunc("POST "+ultra.PATH, config.handleGenerate)
+	for _, editPath := range []string{edit.PATH_INPAINT, edit.PATH_OUTPAINT, edit.PATH_ERASE, edit.PATH_SEARCH_AND_REPLACE, edit.PATH_SEARCH_AND_RECOLOR, edit.PATH_REMOVE_BACKGROUND} {
+		mux.HandleFunc("POST "+editPath, config.handleEdit)
+	}
+	mux.HandleFunc("POST "+upscale.PATH_CONSERVATIVE, config.handleUpscale)
+	mux.HandleFunc("POST "+upscale.PATH_FAST, config.handleUpscale)
+	mux.HandleFunc("POST "+upscale.PATH_CREATIVE, config.handleUpscale)
+	mux.HandleFunc("POST "+control.PATH_SKETCH, config.handleControl)
+	mux.HandleFunc("POST "+control.PATH_STRUCTURE, config.handleControl)
+	mux.HandleFunc("POST "+control.PATH_STYLE, config.handleControl)
+	mux.HandleFunc("POST "+imageToVideo.PATH, config.handleVideo)
+	mux.HandleFunc("POST "+stableFast3d.PATH, config.handleModel)
+	mux.HandleFunc("GET "+results.PATH+"{id}", config.handleResult)
+	config.server = httptest.NewServer(mux)
+
+	return config, nil
+}
+
+// WithCredits sets the starting balance. Successful generations are deducted from it.
+func WithCredits(credits float64) Option {
+	return func(config *Server) {
+		config.credits = credits
+	}
+}
+
+// WithPendingPolls sets how many polls of an asynchronous result answer 202 before it is
+// ready. The default is DEFAULT_PENDING_POLLS.
+func WithPendingPolls(polls int) Option {
+	return func(config *Server) {
+		config.pending = polls
+	}
+}
+
+// WithAccount sets the account returned by /v1/user/account.
+func WithAccount(account *user.ResponseUser) Option {
+	return func(config *Server) {
+		config.account = account
+	}
+}
+
+// WithCheck adds an assertion run on every request. Failures are reported by Verify.
+func WithCheck(check func(*ReceivedRequest) error) Option {
+	return func(config *Server) {
+		config.checks = append(config.checks, check)
+	}
+}
+
+// WithLogger sets the logger.
+func WithLogger(log *slog.Logger) Option {
+	return func(config *Server) {
+		moduleLogger := log.With(
+			slog.Group("module_info",
+				slog.String("module", MODULE_NAME),
+			),
+		)
+		config.log = moduleLogger
+	}
+}
+
+// URL returns the base URL of the server; pass it to stability.WithBaseURL.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Client returns a stability.Stability pointed at the server. Further options are applied
+// after the API key and base URL.
+func (s *Server) Client(opts ...stability.Option) (*stability.Stability, error) {
+	options := []func(*stability.Stability){
+		stability.WithAPIKey(API_KEY),
+		stability.WithBaseURL(s.server.URL),
+	}
+	for _, opt := range opts {
+		options = append(options, opt)
+	}
+	return stability.New(options...)
+}
+
+// Enqueue adds replies for the next generation requests.
+func (s *Server) Enqueue(replies ...*Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, replies...)
+}
+
+// Credits returns the remaining balance.
+func (s *Server) Credits() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.credits
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []*ReceivedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*ReceivedRequest{}, s.requests...)
+}
+
+// LastRequest returns the most recent request, or nil.
+func (s *Server) LastRequest() *ReceivedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return nil
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+// Verify reports invalid requests, failed checks and scripted replies that were never
+// requested.
+func (s *Server) Verify() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errs := append([]error{}, s.errs...)
+	if len(s.replies) > 0 {
+		errs = append(errs, &ErrUnusedReplies{Count: len(s.replies)})
+	}
+	return errors.Join(errs...)
+}
+
+// Moderated returns a 403 reply for a prompt flagged by the moderation system.
+func Moderated() *Reply {
+	return &Reply{
+		StatusCode: http.StatusForbidden,
+		Name:       "content_moderation",
+		Errors:     []string{"Your request was flagged by our content moderation system, as a result your request was denied and you were not charged."},
+	}
+}
+
+// TooLarge returns a 413 reply.
+func TooLarge() *Reply {
+	return &Reply{
+		StatusCode: http.StatusRequestEntityTooLarge,
+		Name:       "payload_too_large",
+		Errors:     []string{"body: payloads cannot be larger than 10MiB in size"},
+	}
+}
+
+// RateLimited returns a 429 reply.
+func RateLimited() *Reply {
+	return &Reply{
+		StatusCode: http.StatusTooManyRequests,
+		Name:       "rate_limit_exceeded",
+		Errors:     []string{"You have exceeded the rate limit of 150 requests within a 10 second period."},
+	}
+}
+
+// Filtered returns a successful reply whose image was blurred by the content filter.
+func Filtered() *Reply {
+	return &Reply{FinishReason: "CONTENT_FILTERED"}
+}
+
+// handleAccount serves /v1/user/account.
+func (s *Server) handleAccount(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.receive(w, r); !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.account)
+}
+
+// handleBalance serves /v1/user/balance.
+func (s *Server) handleBalance(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.receive(w, r); !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, &user.ResponseUserBalance{Credits: s.Credits()})
+}
+
+// handleGenerate serves the sd3, core and ultra generation endpoints.
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength > MAX_REQUEST_BYTES {
+		writeError(w, TooLarge())
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_REQUEST_BYTES)
+
+	received, ok := s.receive(w, r)
+	if !ok {
+		return
+	}
+
+	endpoint := path.Base(r.URL.Path)
+	if err := checkGenerate(received, endpoint); err != nil {
+		s.fail(w, received, err)
+		return
+	}
+
+	model := endpoint
+	if endpoint == "sd3" {
+		model = field(received, "model", generate.DEFAULT_MODEL)
+	}
+	s.writeImage(w, r, received, generate.CREDITS[model])
+}
+
+// handleEdit serves the edit endpoints.
+func (s *Server) handleEdit(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength > MAX_REQUEST_BYTES {
+		writeError(w, TooLarge())
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_REQUEST_BYTES)
+
+	received, ok := s.receive(w, r)
+	if !ok {
+		return
+	}
+
+	operation := path.Base(r.URL.Path)
+	if err := checkEdit(received, operation); err != nil {
+		s.fail(w, received, err)
+		return
+	}
+	s.writeImage(w, r, received, edit.CREDITS[operation])
+}
+
+// handleUpscale serves the upscale endpoints. Creative upscales are asynchronous: they
+// answer with a generation id to poll on the results endpoint.
+func (s *Server) handleUpscale(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength > MAX_REQUEST_BYTES {
+		writeError(w, TooLarge())
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_REQUEST_BYTES)
+
+	received, ok := s.receive(w, r)
+	if !ok {
+		return
+	}
+
+	operation := path.Base(r.URL.Path)
+	if err := checkUpscale(received, operation); err != nil {
+		s.fail(w, received, err)
+		return
+	}
+	if operation != "creative" {
+		s.writeImage(w, r, received, upscale.CREDITS[operation])
+		return
+	}
+	s.start(w, received, upscale.CREDITS[operation], false)
+}
+
+// handleVideo serves /v2beta/image-to-video. Videos are asynchronous: the request
+// answers with a generation id to poll on the results endpoint.
+func (s *Server) handleVideo(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength > MAX_REQUEST_BYTES {
+		writeError(w, TooLarge())
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_REQUEST_BYTES)
+
+	received, ok := s.receive(w, r)
+	if !ok {
+		return
+	}
+
+	if err := checkVideo(received); err != nil {
+		s.fail(w, received, err)
+		return
+	}
+	s.start(w, received, imageToVideo.CREDITS, true)
+}
+
+// handleModel serves /v2beta/3d/stable-fast-3d, answering with a GLB.
+func (s *Server) handleModel(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength > MAX_REQUEST_BYTES {
+		writeError(w, TooLarge())
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_REQUEST_BYTES)
+
+	received, ok := s.receive(w, r)
+	if !ok {
+		return
+	}
+
+	if err := checkModel(received); err != nil {
+		s.fail(w, received, err)
+		return
+	}
+
+	reply := s.next()
+	if reply.StatusCode != 0 && reply.StatusCode != http.StatusOK {
+		writeError(w, reply)
+		return
+	}
+	model := reply.Model
+	if model == nil {
+		model = glbModel()
+	}
+
+	s.mu.Lock()
+	s.credits -= stableFast3d.CREDITS
+	s.mu.Unlock()
+
+	w.Header().Set("content-type", stableFast3d.CONTENT_TYPE_GLB)
+	w.WriteHeader(http.StatusOK)
+	w.Write(model)
+}
+
+// start answers a valid asynchronous request with a generation id, keeping its result
+// pending for the configured number of polls. Error replies are answered at once.
+func (s *Server) start(w http.ResponseWriter, received *ReceivedRequest, credits float64, video bool) {
+	reply := s.next()
+	if reply.StatusCode != 0 && reply.StatusCode != http.StatusOK {
+		writeError(w, reply)
+		return
+	}
+
+	result := newResult(received, reply, credits, s.pending)
+	if video {
+		result.media = reply.Video
+		if result.media == nil {
+			result.media = mp4Video
+		}
+		result.contentType = imageToVideo.CONTENT_TYPE_MP4
+	}
+
+	id := fmt.Sprintf("stabilitytest-%d", time.Now().UnixNano())
+	s.mu.Lock()
+	s.results[id] = result
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, &results.ResponseId{Id: id})
+}
+
+// handleControl serves the control endpoints.
+func (s *Server) handleControl(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength > MAX_REQUEST_BYTES {
+		writeError(w, TooLarge())
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_REQUEST_BYTES)
+
+	received, ok := s.receive(w, r)
+	if !ok {
+		return
+	}
+
+	operation := path.Base(r.URL.Path)
+	if err := checkControl(received, operation); err != nil {
+		s.fail(w, received, err)
+		return
+	}
+	s.writeImage(w, r, received, control.CREDITS[operation])
+}
+
+// handleResult serves /v2beta/results/{id}, answering 202 while the result is pending.
+func (s *Server) handleResult(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.receive(w, r); !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	s.mu.Lock()
+	result, ok := s.results[id]
+	pending := ok && result.pending > 0
+	if pending {
+		result.pending--
+	} else if ok {
+		delete(s.results, id)
+	}
+	s.mu.Unlock()
+
+	switch {
+	case !ok:
+		writeError(w, &Reply{StatusCode: http.StatusNotFound, Name: "not_found", Errors: []string{"generation " + id + " not found"}})
+	case pending:
+		writeJSON(w, http.StatusAccepted, &pendingBody{Id: id, Status: "in-progress"})
+	default:
+		s.writeResult(w, r.Header.Get("accept"), result)
+	}
+}
+
+// writeImage answers a valid image request with the next scripted reply, charging credits
+// on success.
+func (s *Server) writeImage(w http.ResponseWriter, r *http.Request, received *ReceivedRequest, credits float64) {
+	reply := s.next()
+	if reply.StatusCode != 0 && reply.StatusCode != http.StatusOK {
+		writeError(w, reply)
+		return
+	}
+	s.writeResult(w, r.Header.Get("accept"), newResult(received, reply, credits, 0))
+}
+
+// writeResult writes a successful image or video as JSON or raw bytes, depending on
+// accept, and charges its credits.
+func (s *Server) writeResult(w http.ResponseWriter, accept string, result *result) {
+	s.mu.Lock()
+	s.credits -= result.credits
+	s.mu.Unlock()
+
+	if strings.HasPrefix(accept, "application/json") {
+		body := &mediaBody{FinishReason: result.finishReason, Seed: result.seed}
+		if strings.HasPrefix(result.contentType, "video/") {
+			body.Video = base64.StdEncoding.EncodeToString(result.media)
+		} else {
+			body.Image = base64.StdEncoding.EncodeToString(result.media)
+		}
+		writeJSON(w, http.StatusOK, body)
+		return
+	}
+
+	w.Header().Set("content-type", result.contentType)
+	w.Header().Set("finish-reason", result.finishReason)
+	w.Header().Set("seed", strconv.Itoa(result.seed))
+	w.WriteHeader(http.StatusOK)
+	w.Write(result.media)
+}
+
+// receive records a request, parsing its multipart form, and checks its authorization.
+func (s *Server) receive(w http.ResponseWriter, r *http.Request) (*ReceivedRequest, bool) {
+	received := &ReceivedRequest{
+		Time:          time.Now(),
+		Method:        r.Method,
+		Path:          r.URL.Path,
+		Header:        r.Header.Clone(),
+		ContentLength: r.ContentLength,
+		Form:          map[string][]string{},
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, received)
+	s.mu.Unlock()
+
+	if s.log != nil {
+		s.log.Debug("received request", slog.String("method", r.Method), slog.String("path", r.URL.Path))
+	}
+
+	if !strings.HasPrefix(r.Header.Get("authorization"), "Bearer ") {
+		s.fail(w, received, &ErrInvalidField{Field: "authorization", Value: r.Header.Get("authorization")})
+		return nil, false
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("content-type")); err == nil && mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(MAX_REQUEST_BYTES); err != nil {
+			var maxBytes *http.MaxBytesError
+			if errors.As(err, &maxBytes) {
+				writeError(w, TooLarge())
+				return nil, false
+			}
+			s.fail(w, received, err)
+			return nil, false
+		}
+		received.Form = r.MultipartForm.Value
+		received.Files = r.MultipartForm.File
+	} else if r.Method == http.MethodPost {
+		s.fail(w, received, &ErrInvalidField{Field: "content-type", Value: r.Header.Get("content-type")})
+		return nil, false
+	}
+
+	for _, check := range s.checks {
+		if err := check(received); err != nil {
+			s.fail(w, received, err)
+			return nil, false
+		}
+	}
+
+	return received, true
+}
+
+// fail records an invalid request and answers it with 400.
+func (s *Server) fail(w http.ResponseWriter, received *ReceivedRequest, err error) {
+	s.mu.Lock()
+	index := slices.Index(s.requests, received)
+	s.errs = append(s.errs, &ErrInvalidRequest{Err: err, Index: index})
+	s.mu.Unlock()
+
+	statusCode := http.StatusBadRequest
+	name := "bad_request"
+	var invalid *ErrInvalidField
+	if errors.As(err, &invalid) && invalid.Field == "authorization" {
+		statusCode = http.StatusUnauthorized
+		name = "unauthorized"
+	}
+	writeError(w, &Reply{StatusCode: statusCode, Name: name, Errors: []string{err.Error()}})
+}
+
+// next pops the next scripted reply, or returns an empty (successful) one.
+func (s *Server) next() *Reply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.replies) == 0 {
+		return &Reply{}
+	}
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	return reply
+}
+
+// checkGenerate checks the form of a sd3, core or ultra request the way the API does.
+func checkGenerate(received *ReceivedRequest, endpoint string) error {
+	fields := GENERATE_FIELDS
+	switch endpoint {
+	case "core":
+		fields = CORE_FIELDS
+	case "ultra":
+		fields = ULTRA_FIELDS
+	}
+
+	errs := checkFields(received, fields)
+
+	prompt := field(received, "prompt", "")
+	if prompt == "" || len([]rune(prompt)) > generate.MAX_PROMPT_LENGTH {
+		errs = append(errs, &ErrInvalidField{Field: "prompt", Value: prompt})
+	}
+	if negative, ok := received.Form["negative_prompt"]; ok && len([]rune(negative[0])) > generate.MAX_PROMPT_LENGTH {
+		errs = append(errs, &ErrInvalidField{Field: "negative_prompt", Value: negative[0]})
+	}
+
+	for key, allowed := range map[string][]string{
+		"aspect_ratio":  generate.ASPECT_RATIOS,
+		"model":         generate.MODELS,
+		"output_format": generate.OUTPUT_FORMATS,
+		"mode":          {"text-to-image", "image-to-image"},
+		"style_preset":  core.STYLE_PRESETS,
+	} {
+		if values, ok := received.Form[key]; ok && !slices.Contains(allowed, values[0]) {
+			errs = append(errs, &ErrInvalidField{Field: key, Value: values[0]})
+		}
+	}
+
+	if values, ok := received.Form["seed"]; ok {
+		if seed, err := strconv.Atoi(values[0]); err != nil || seed < 0 || seed > generate.MAX_SEED {
+			errs = append(errs, &ErrInvalidField{Field: "seed", Value: values[0]})
+		}
+	}
+
+	if field(received, "mode", "text-to-image") == "image-to-image" {
+		if len(received.Files["image"]) == 0 {
+			errs = append(errs, &ErrInvalidField{Field: "image", Msg: "image-to-image requires an image file"})
+		}
+		strength, err := strconv.ParseFloat(field(received, "strength", ""), 64)
+		if err != nil || strength < 0 || strength > 1 {
+			errs = append(errs, &ErrInvalidField{Field: "strength", Value: field(received, "strength", "")})
+		}
+		if _, ok := received.Form["aspect_ratio"]; ok {
+			errs = append(errs, &ErrInvalidField{Field: "aspect_ratio", Value: field(received, "aspect_ratio", ""), Msg: "aspect_ratio is not allowed in image-to-image mode"})
+		}
+	}
+
+	if endpoint == "ultra" && len(received.Files["image"]) > 0 {
+		strength, err := strconv.ParseFloat(field(received, "strength", ""), 64)
+		if err != nil || strength < 0 || strength > 1 {
+			errs = append(errs, &ErrInvalidField{Field: "strength", Value: field(received, "strength", "")})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkEdit checks the form of an edit request the way the API does.
+func checkEdit(received *ReceivedRequest, operation string) error {
+	errs := checkFields(received, append([]string{"output_format"}, EDIT_FIELDS[operation]...))
+	for key := range received.Files {
+		if key != "image" && (key != "mask" || (operation != "inpaint" && operation != "erase")) {
+			errs = append(errs, &ErrInvalidField{Field: key, Msg: "unknown file"})
+		}
+	}
+	if len(received.Files["image"]) != 1 {
+		errs = append(errs, &ErrInvalidField{Field: "image", Msg: "an image file is required"})
+	}
+
+	required := map[string][]string{
+		"inpaint":            {"prompt"},
+		"search-and-replace": {"prompt", "search_prompt"},
+		"search-and-recolor": {"prompt", "select_prompt"},
+	}
+	for _, key := range required[operation] {
+		if field(received, key, "") == "" {
+			errs = append(errs, &ErrInvalidField{Field: key, Msg: "required"})
+		}
+	}
+	for _, key := range []string{"prompt", "negative_prompt", "search_prompt", "select_prompt"} {
+		if values, ok := received.Form[key]; ok && len([]rune(values[0])) > edit.MAX_PROMPT_LENGTH {
+			errs = append(errs, &ErrInvalidField{Field: key, Value: values[0]})
+		}
+	}
+
+	outputFormats := edit.OUTPUT_FORMATS
+	if operation == "remove-background" {
+		outputFormats = edit.REMOVE_BACKGROUND_OUTPUT_FORMATS
+	}
+	if values, ok := received.Form["output_format"]; ok && !slices.Contains(outputFormats, values[0]) {
+		errs = append(errs, &ErrInvalidField{Field: "output_format", Value: values[0]})
+	}
+
+	for key, max := range map[string]int{
+		"seed":      edit.MAX_SEED,
+		"grow_mask": edit.MAX_GROW_MASK,
+		"left":      edit.MAX_OUTPAINT,
+		"right":     edit.MAX_OUTPAINT,
+		"up":        edit.MAX_OUTPAINT,
+		"down":      edit.MAX_OUTPAINT,
+	} {
+		if values, ok := received.Form[key]; ok {
+			if n, err := strconv.Atoi(values[0]); err != nil || n < 0 || n > max {
+				errs = append(errs, &ErrInvalidField{Field: key, Value: values[0]})
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkUpscale checks the form of an upscale request the way the API does.
+func checkUpscale(received *ReceivedRequest, operation string) error {
+	errs := checkFields(received, append([]string{"output_format"}, UPSCALE_FIELDS[operation]...))
+	if len(received.Files["image"]) != 1 {
+		errs = append(errs, &ErrInvalidField{Field: "image", Msg: "an image file is required"})
+	}
+
+	prompt := field(received, "prompt", "")
+	if operation != "fast" && (prompt == "" || len([]rune(prompt)) > upscale.MAX_PROMPT_LENGTH) {
+		errs = append(errs, &ErrInvalidField{Field: "prompt", Value: prompt})
+	}
+	if values, ok := received.Form["output_format"]; ok && !slices.Contains(upscale.OUTPUT_FORMATS, values[0]) {
+		errs = append(errs, &ErrInvalidField{Field: "output_format", Value: values[0]})
+	}
+	if values, ok := received.Form["style_preset"]; ok && !slices.Contains(core.STYLE_PRESETS, values[0]) {
+		errs = append(errs, &ErrInvalidField{Field: "style_preset", Value: values[0]})
+	}
+	if values, ok := received.Form["seed"]; ok {
+		if seed, err := strconv.Atoi(values[0]); err != nil || seed < 0 || seed > upscale.MAX_SEED {
+			errs = append(errs, &ErrInvalidField{Field: "seed", Value: values[0]})
+		}
+	}
+
+	min, max := upscale.MIN_CONSERVATIVE_CREATIVITY, upscale.MAX_CONSERVATIVE_CREATIVITY
+	if operation == "creative" {
+		min, max = 0, upscale.MAX_CREATIVE_CREATIVITY
+	}
+	if values, ok := received.Form["creativity"]; ok {
+		if creativity, err := strconv.ParseFloat(values[0], 64); err != nil || creativity < min || creativity > max {
+			errs = append(errs, &ErrInvalidField{Field: "creativity", Value: values[0]})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkControl checks the form of a control request the way the API does.
+func checkControl(received *ReceivedRequest, operation string) error {
+	errs := checkFields(received, append([]string{"output_format"}, CONTROL_FIELDS[operation]...))
+	if len(received.Files["image"]) != 1 {
+		errs = append(errs, &ErrInvalidField{Field: "image", Msg: "an image file is required"})
+	}
+
+	prompt := field(received, "prompt", "")
+	if prompt == "" || len([]rune(prompt)) > control.MAX_PROMPT_LENGTH {
+		errs = append(errs, &ErrInvalidField{Field: "prompt", Value: prompt})
+	}
+	if values, ok := received.Form["output_format"]; ok && !slices.Contains(control.OUTPUT_FORMATS, values[0]) {
+		errs = append(errs, &ErrInvalidField{Field: "output_format", Value: values[0]})
+	}
+	if values, ok := received.Form["aspect_ratio"]; ok && !slices.Contains(generate.ASPECT_RATIOS, values[0]) {
+		errs = append(errs, &ErrInvalidField{Field: "aspect_ratio", Value: values[0]})
+	}
+	if values, ok := received.Form["seed"]; ok {
+		if seed, err := strconv.Atoi(values[0]); err != nil || seed < 0 || seed > control.MAX_SEED {
+			errs = append(errs, &ErrInvalidField{Field: "seed", Value: values[0]})
+		}
+	}
+	for _, key := range []string{"control_strength", "fidelity"} {
+		if values, ok := received.Form[key]; ok {
+			if n, err := strconv.ParseFloat(values[0], 64); err != nil || n < 0 || n > 1 {
+				errs = append(errs, &ErrInvalidField{Field: key, Value: values[0]})
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkVideo checks the form of an image-to-video request the way the API does,
+// including the size of the image.
+func checkVideo(received *ReceivedRequest) error {
+	errs := checkFields(received, VIDEO_FIELDS)
+	if files := received.Files["image"]; len(files) != 1 {
+		errs = append(errs, &ErrInvalidField{Field: "image", Msg: "an image file is required"})
+	} else if size, err := imageSize(files[0]); err != nil || !slices.Contains(imageToVideo.SIZES, size) {
+		errs = append(errs, &ErrInvalidField{Err: err, Field: "image", Value: size, Msg: "image size must be one of " + strings.Join(imageToVideo.SIZES, ", ")})
+	}
+
+	for key, limits := range map[string][2]float64{
+		"seed":             {0, imageToVideo.MAX_SEED},
+		"cfg_scale":        {0, imageToVideo.MAX_CFG_SCALE},
+		"motion_bucket_id": {imageToVideo.MIN_MOTION_BUCKET_ID, imageToVideo.MAX_MOTION_BUCKET_ID},
+	} {
+		if values, ok := received.Form[key]; ok {
+			if n, err := strconv.ParseFloat(values[0], 64); err != nil || n < limits[0] || n > limits[1] {
+				errs = append(errs, &ErrInvalidField{Field: key, Value: values[0]})
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkModel checks the form of a stable-fast-3d request the way the API does.
+func checkModel(received *ReceivedRequest) error {
+	errs := checkFields(received, MODEL_FIELDS)
+	if len(received.Files["image"]) != 1 {
+		errs = append(errs, &ErrInvalidField{Field: "image", Msg: "an image file is required"})
+	}
+
+	for key, allowed := range map[string][]string{
+		"texture_resolution": stableFast3d.TEXTURE_RESOLUTIONS,
+		"remesh":             stableFast3d.REMESH_MODES,
+	} {
+		if values, ok := received.Form[key]; ok && !slices.Contains(allowed, values[0]) {
+			errs = append(errs, &ErrInvalidField{Field: key, Value: values[0]})
+		}
+	}
+	if values, ok := received.Form["foreground_ratio"]; ok {
+		if ratio, err := strconv.ParseFloat(values[0], 64); err != nil || ratio < stableFast3d.MIN_FOREGROUND_RATIO || ratio > stableFast3d.MAX_FOREGROUND_RATIO {
+			errs = append(errs, &ErrInvalidField{Field: "foreground_ratio", Value: values[0]})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// imageSize returns the "WIDTHxHEIGHT" of an uploaded PNG or JPEG.
+func imageSize(file *multipart.FileHeader) (string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%dx%d", config.Width, config.Height), nil
+}
+
+// checkFields reports unknown and repeated form fields.
+func checkFields(received *ReceivedRequest, fields []string) []error {
+	errs := []error{}
+	for key, values := range received.Form {
+		if !slices.Contains(fields, key) {
+			errs = append(errs, &ErrInvalidField{Field: key, Value: strings.Join(values, ","), Msg: "unknown form field"})
+		}
+		if len(values) > 1 {
+			errs = append(errs, &ErrInvalidField{Field: key, Value: strings.Join(values, ","), Msg: "repeated form field"})
+		}
+	}
+	return errs
+}
+
+// newResult returns the image answering a valid request, applying the reply's overrides.
+func newResult(received *ReceivedRequest, reply *Reply, credits float64, pending int) *result {
+	outputFormat := field(received, "output_format", generate.DEFAULT_OUTPUT_FORMAT)
+	result := &result{
+		media:        reply.Image,
+		contentType:  "image/" + outputFormat,
+		finishReason: reply.FinishReason,
+		credits:      credits,
+		pending:      pending,
+	}
+	if result.media == nil {
+		result.media = placeholder(outputFormat)
+	}
+	if result.finishReason == "" {
+		result.finishReason = "SUCCESS"
+	}
+	result.seed, _ = strconv.Atoi(field(received, "seed", "0"))
+	if reply.Seed != nil {
+		result.seed = *reply.Seed
+	}
+	return result
+}
+
+// field returns the first value of a form field, or def.
+func field(received *ReceivedRequest, key string, def string) string {
+	if values, ok := received.Form[key]; ok && len(values) > 0 {
+		return values[0]
+	}
+	return def
+}
+
+// placeholder returns a small image in the output format.
+func placeholder(outputFormat string) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, IMAGE_SIZE, IMAGE_SIZE))
+	for y := 0; y < IMAGE_SIZE; y++ {
+		for x := 0; x < IMAGE_SIZE; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 4), G: uint8(y * 4), B: 128, A: 255})
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	switch outputFormat {
+	case "webp":
+		return webpImage
+	case "jpeg":
+		jpeg.Encode(buf, img, nil)
+	default:
+		png.Encode(buf, img)
+	}
+	return buf.Bytes()
+}
+
+// glbModel returns a GLB holding only its asset description.
+func glbModel() []byte {
+	doc := []byte(`{"asset":{"version":"2.0","generator":"stabilitytest"}}`)
+	for len(doc)%4 != 0 {
+		doc = append(doc, ' ')
+	}
+
+	out := binary.LittleEndian.AppendUint32(nil, stableFast3d.GLB_MAGIC)
+	out = binary.LittleEndian.AppendUint32(out, 2)
+	out = binary.LittleEndian.AppendUint32(out, uint32(12+8+len(doc)))
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(doc)))
+	out = binary.LittleEndian.AppendUint32(out, stableFast3d.GLB_CHUNK_JSON)
+	return append(out, doc...)
+}
+
+// writeError writes an API error body.
+func writeError(w http.ResponseWriter, reply *Reply) {
+	writeJSON(w, reply.StatusCode, &errorBody{
+		Id:     fmt.Sprintf("stabilitytest-%d", time.Now().UnixNano()),
+		Name:   reply.Name,
+		Errors: reply.Errors,
+	})
+}
+
+// writeJSON writes a JSON body.
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,221 @@
+package stabilitytest_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/stability/stabilitytest"
+	"github.com/rmrfslashbin/ami/stability/v1/user"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate"
+)
+
+var log = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func newServer(t *testing.T, opts ...func(*stabilitytest.Server)) *stabilitytest.Server {
+	t.Helper()
+	server, err := stabilitytest.New(opts...)
+	if err != nil {
+		t.Fatalf("stabilitytest.New: %v", err)
+	}
+	t.Cleanup(server.Close)
+	return server
+}
+
+// client returns a Stability client pointed at server.
+func client(t *testing.T, server *stabilitytest.Server) *stability.Stability {
+	t.Helper()
+	s, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	return s
+}
+
+func generator(t *testing.T, server *stabilitytest.Server, opts ...func(*generate.StabilityV3)) *generate.StabilityV3 {
+	t.Helper()
+	opts = append([]func(*generate.StabilityV3){generate.WithStability(client(t, server)), generate.WithLogger(log)}, opts...)
+	g, err := generate.New(opts...)
+	if err != nil {
+		t.Fatalf("generate.New: %v", err)
+	}
+	return g
+}
+
+// invalidField returns the first invalid form field reported by Verify.
+func invalidField(t *testing.T, err error) *stabilitytest.ErrInvalidField {
+	t.Helper()
+	var request *stabilitytest.ErrInvalidRequest
+	if !errors.As(err, &request) {
+		t.Fatalf("Verify = %v, want ErrInvalidRequest", err)
+	}
+	var invalid *stabilitytest.ErrInvalidField
+	if !errors.As(request.Err, &invalid) {
+		t.Fatalf("ErrInvalidRequest wraps %v, want ErrInvalidField", request.Err)
+	}
+	return invalid
+}
+
+func TestAccount(t *testing.T) {
+	server := newServer(t, stabilitytest.WithCredits(42))
+
+	u, err := user.New(user.WithStability(client(t, server)), user.WithLogger(log))
+	if err != nil {
+		t.Fatalf("user.New: %v", err)
+	}
+	me, err := u.Me()
+	if err != nil {
+		t.Fatalf("Me: %v", err)
+	}
+	if me.User.Email != "stabilitytest@example.com" {
+		t.Errorf("Me email = %q", me.User.Email)
+	}
+
+	balance, err := u.Balance(nil)
+	if err != nil {
+		t.Fatalf("Balance: %v", err)
+	}
+	if balance.Credits.Credits != 42 {
+		t.Errorf("Balance = %g, want 42", balance.Credits.Credits)
+	}
+	if err := server.Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	server := newServer(t)
+
+	res, err := generator(t, server, generate.WithPrompt("a lighthouse"), generate.WithSeed(7)).Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if res.Image == nil || *res.Image == "" || res.Seed == nil || *res.Seed != 7 {
+		t.Errorf("Generate = %+v, want an image and seed 7", res)
+	}
+	if res.FinishReason == nil || *res.FinishReason != "SUCCESS" {
+		t.Errorf("Generate finish reason = %v, want SUCCESS", res.FinishReason)
+	}
+
+	form := server.LastRequest().Form
+	if form["prompt"][0] != "a lighthouse" || form["model"][0] != generate.DEFAULT_MODEL {
+		t.Errorf("form = %v", form)
+	}
+	if want := stabilitytest.DEFAULT_CREDITS - generate.CREDITS[generate.DEFAULT_MODEL]; server.Credits() != want {
+		t.Errorf("Credits = %g, want %g", server.Credits(), want)
+	}
+	if err := server.Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestMultipartValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		parts map[string]interface{}
+		field string
+	}{
+		{name: "missing prompt", parts: map[string]interface{}{"aspect_ratio": "1:1"}, field: "prompt"},
+		{name: "unknown field", parts: map[string]interface{}{"prompt": "a", "style": "noir"}, field: "style"},
+		{name: "invalid aspect ratio", parts: map[string]interface{}{"prompt": "a", "aspect_ratio": "2:1"}, field: "aspect_ratio"},
+		{name: "seed out of range", parts: map[string]interface{}{"prompt": "a", "seed": -1}, field: "seed"},
+		{name: "image-to-image without image", parts: map[string]interface{}{"prompt": "a", "mode": "image-to-image", "strength": 0.5}, field: "image"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newServer(t)
+			s := client(t, server)
+			request := s.NewRequest(stability.METHOD_POST, generate.PATH)
+			for key, value := range tt.parts {
+				request.AddFormPart(key, value)
+			}
+			request.AddHeader("accept", "application/json")
+
+			res, err := s.Do(request)
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			if res.StatusCode != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", res.StatusCode)
+			}
+
+			if invalid := invalidField(t, server.Verify()); invalid.Field != tt.field {
+				t.Errorf("invalid field = %s, want %s", invalid.Field, tt.field)
+			}
+		})
+	}
+}
+
+func TestRepeatedField(t *testing.T) {
+	server := newServer(t)
+	s := client(t, server)
+	request := s.NewRequest(stability.METHOD_POST, generate.PATH)
+	request.AddFormPart("prompt", "a")
+	request.AddFormPart("prompt", "b")
+
+	if _, err := s.Do(request); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if invalid := invalidField(t, server.Verify()); invalid.Field != "prompt" || invalid.Value != "a,b" {
+		t.Errorf("invalid field = %s=%q, want the repeated prompt", invalid.Field, invalid.Value)
+	}
+}
+
+func TestErrorReplies(t *testing.T) {
+	tests := []struct {
+		name   string
+		reply  *stabilitytest.Reply
+		status int
+	}{
+		{name: "moderated", reply: stabilitytest.Moderated(), status: http.StatusForbidden},
+		{name: "too large", reply: stabilitytest.TooLarge(), status: http.StatusRequestEntityTooLarge},
+		{name: "rate limited", reply: stabilitytest.RateLimited(), status: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newServer(t)
+			server.Enqueue(tt.reply)
+
+			res, err := generator(t, server, generate.WithPrompt("a lighthouse")).Generate()
+			var httpErr *stability.ErrHTTP
+			if !errors.As(err, &httpErr) || httpErr.StatusCode != tt.status {
+				t.Fatalf("Generate = %v, want ErrHTTP %d", err, tt.status)
+			}
+			if res == nil || res.Errors == nil || res.Errors.Name != tt.reply.Name {
+				t.Errorf("Generate errors = %+v, want %s", res, tt.reply.Name)
+			}
+			if server.Credits() != stabilitytest.DEFAULT_CREDITS {
+				t.Errorf("Credits = %g, want no charge", server.Credits())
+			}
+			if err := server.Verify(); err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestOversizedRequest(t *testing.T) {
+	server := newServer(t)
+	s := client(t, server)
+	request := s.NewRequest(stability.METHOD_POST, generate.PATH)
+	request.AddFormPart("prompt", strings.Repeat("a", stabilitytest.MAX_REQUEST_BYTES+1))
+
+	res, err := s.Do(request)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if res.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", res.StatusCode)
+	}
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(res.Body, &body); err != nil || body["name"] != "payload_too_large" {
+		t.Errorf("body = %s, want payload_too_large", res.Body)
+	}
+}
@@ -0,0 +1,91 @@
+package stabilitytest
+
+import (
+	"mime/multipart"
+	"net/http"
+	"time"
+)
+
+// Reply is a scripted answer to a generation request.
+type Reply struct {
+	// StatusCode is the HTTP status. The default is 200.
+	StatusCode int
+
+	// Name is the error name of an error reply, e.g. "content_moderation".
+	Name string
+
+	// Errors are the error messages of an error reply.
+	Errors []string
+
+	// Image is the image returned on success. The default is a small generated image in
+	// the requested output format.
+	Image []byte
+
+	// Video is the video returned by image-to-video. The default is a placeholder MP4.
+	Video []byte
+
+	// Model is the GLB returned by stable-fast-3d. The default is a GLB without meshes.
+	Model []byte
+
+	// FinishReason is the finish reason. The default is "SUCCESS".
+	FinishReason string
+
+	// Seed is the seed returned. The default is the requested seed.
+	Seed *int
+}
+
+// ReceivedRequest is a request the server received.
+type ReceivedRequest struct {
+	// Time is when the request arrived.
+	Time time.Time
+
+	// Method is the HTTP method.
+	Method string
+
+	// Path is the URL path.
+	Path string
+
+	// Header are the request headers.
+	Header http.Header
+
+	// ContentLength is the declared body length, or -1 if the body was chunked.
+	ContentLength int64
+
+	// Form are the multipart text fields.
+	Form map[string][]string
+
+	// Files are the multipart file parts.
+	Files map[string][]*multipart.FileHeader
+}
+
+// errorBody is the JSON shape of an API error.
+type errorBody struct {
+	Id     string   `json:"id"`
+	Name   string   `json:"name"`
+	Errors []string `json:"errors"`
+}
+
+// mediaBody is the JSON shape of a generation answered with accept: application/json.
+type mediaBody struct {
+	Image        string `json:"image,omitempty"`
+	Video        string `json:"video,omitempty"`
+	FinishReason string `json:"finish_reason"`
+	Seed         int    `json:"seed"`
+}
+
+// pendingBody is the JSON shape of a result that is still in progress.
+type pendingBody struct {
+	Id     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// result is a successful image or video, written at once or, for asynchronous requests, after
+// pending polls.
+type result struct {
+	media        []byte
+	contentType  string
+	finishReason string
+	seed         int
+	credits      float64
+	pending      int
+}
@@ -1,7 +1,49 @@
 package stability
 
+import (
+	"mime"
+	"net/http"
+	"strconv"
+)
+
 type StabilityResponse struct {
 	StatusCode int                 `json:"status_code,omitempty"`
 	Body       []byte              `json:"body,omitempty"`
 	Headers    map[string][]string `json:"headers,omitempty"`
+
+	// Written is the number of body bytes copied to the writer of DoStream.
+	Written int64 `json:"written,omitempty"`
+}
+
+// Header returns the first value of a response header, matched case-insensitively.
+func (r *StabilityResponse) Header(key string) string {
+	return http.Header(r.Headers).Get(key)
+}
+
+// ContentType returns the media type of the body, e.g. "video/mp4", without parameters.
+func (r *StabilityResponse) ContentType() string {
+	mediaType, _, err := mime.ParseMediaType(r.Header("content-type"))
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// IsJSON reports whether the body is JSON rather than raw media bytes.
+func (r *StabilityResponse) IsJSON() bool {
+	return r.ContentType() == "application/json"
+}
+
+// FinishReason returns the finish-reason header sent with raw media bytes.
+func (r *StabilityResponse) FinishReason() string {
+	return r.Header("finish-reason")
+}
+
+// Seed returns the seed header sent with raw media bytes, if there is one.
+func (r *StabilityResponse) Seed() (int, bool) {
+	seed, err := strconv.Atoi(r.Header("seed"))
+	if err != nil {
+		return 0, false
+	}
+	return seed, true
 }
@@ -2,8 +2,14 @@ package user
 
 import "github.com/rmrfslashbin/ami/stability"
 
+// PATH_USER is the path of V1 user requests
+const PATH_USER = "/v1/user/account"
+
+// PATH_USER_BALANCE is the path of V1 user balance requests
+const PATH_USER_BALANCE = "/v1/user/balance"
+
 // ENDPOINT_USER is the endpoint for V1 user requests
-var ENDPOINT_USER = stability.ENDPOINT_ROOT + "/v1/user/account"
+var ENDPOINT_USER = stability.ENDPOINT_ROOT + PATH_USER
 
 // ENDPOINT_USER_BALANCE_V1 is the endpoint for V1 user balance requests
-var ENDPOINT_USER_BALANCE = stability.ENDPOINT_ROOT + "/v1/user/balance"
+var ENDPOINT_USER_BALANCE = stability.ENDPOINT_ROOT + PATH_USER_BALANCE
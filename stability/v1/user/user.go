@@ -54,9 +54,10 @@ func WithStability(stability *stability.Stability) Option {
 }
 
 func (c *StableUser) Me() (*Response, error) {
-	c.stability.AddHeader("accept", "application/json")
+	request := c.stability.NewRequest(stability.METHOD_GET, PATH_USER)
+	request.AddHeader("accept", "application/json")
 
-	res, err := c.stability.Do(&ENDPOINT_USER, stability.METHOD_GET)
+	res, err := c.stability.Do(request)
 	if err != nil {
 		return nil, err
 	}
@@ -76,23 +77,24 @@ func (c *StableUser) Me() (*Response, error) {
 }
 
 func (c *StableUser) Balance(input *BalanceInput) (*Response, error) {
-	c.stability.AddHeader("accept", "application/json")
+	request := c.stability.NewRequest(stability.METHOD_GET, PATH_USER_BALANCE)
+	request.AddHeader("accept", "application/json")
 
 	if input != nil {
 		if input.Organization != nil {
-			c.stability.AddFormPart("Organization", *input.Organization)
+			request.AddHeader("Organization", *input.Organization)
 		}
 
 		if input.StabilityClientID != nil {
-			c.stability.AddFormPart("Stability-Client-ID", *input.StabilityClientID)
+			request.AddHeader("Stability-Client-ID", *input.StabilityClientID)
 		}
 
 		if input.StabilityClientVersion != nil {
-			c.stability.AddFormPart("Stability-Client-Version", *input.StabilityClientVersion)
+			request.AddHeader("Stability-Client-Version", *input.StabilityClientVersion)
 		}
 	}
 
-	res, err := c.stability.Do(&ENDPOINT_USER_BALANCE, stability.METHOD_GET)
+	res, err := c.stability.Do(request)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,30 @@
+package imageToVideo
+
+// PATH is the path of image-to-video requests
+const PATH = "/v2beta/image-to-video"
+
+// CREDITS is the price of a successful video
+const CREDITS = 20.0
+
+// ACCEPT_VIDEO asks for the result as raw MP4 bytes
+const ACCEPT_VIDEO = "video/*"
+
+// CONTENT_TYPE_MP4 is the content type of a finished video
+const CONTENT_TYPE_MP4 = "video/mp4"
+
+// FINISH_REASON_SUCCESS and FINISH_REASON_CONTENT_FILTERED are the finish reasons of a video
+const FINISH_REASON_SUCCESS = "SUCCESS"
+const FINISH_REASON_CONTENT_FILTERED = "CONTENT_FILTERED"
+
+// SIZES are the supported input image sizes
+var SIZES = []string{"1024x576", "576x1024", "768x768"}
+
+// MAX_SEED is the maximum seed value
+const MAX_SEED = 4294967294
+
+// MAX_CFG_SCALE is the maximum cfg scale
+const MAX_CFG_SCALE = 10
+
+// MIN_MOTION_BUCKET_ID and MAX_MOTION_BUCKET_ID bound the motion bucket id
+const MIN_MOTION_BUCKET_ID = 1
+const MAX_MOTION_BUCKET_ID = 255
@@ -0,0 +1,158 @@
+package imageToVideo
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ErrMissingStability struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingStability) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing stability- use WithStability to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrMissingLogger struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingLogger) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing logger- use WithLogger to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrMissingImage struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingImage) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing image- use WithImage or SetImage to set it. Must be one of " + strings.Join(SIZES, ", ")
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidSeed struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidSeed) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid seed- use WithSeed or SetSeed to set it. Must be between 0 and %d", MAX_SEED)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidCfgScale struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidCfgScale) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid cfg scale- use WithCfgScale or SetCfgScale to set it. Must be between 0 and %d", MAX_CFG_SCALE)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidMotionBucketId struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidMotionBucketId) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid motion bucket id- use WithMotionBucketId or SetMotionBucketId to set it. Must be between %d and %d", MIN_MOTION_BUCKET_ID, MAX_MOTION_BUCKET_ID)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrUnexpectedContentType struct {
+	Err         error
+	Msg         string
+	ContentType string
+}
+
+func (e *ErrUnexpectedContentType) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "unexpected content type; expected " + CONTENT_TYPE_MP4
+	}
+	if e.ContentType != "" {
+		msg += fmt.Sprintf(": %s", e.ContentType)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrContentFiltered struct {
+	Err error
+	Msg string
+	Id  string
+}
+
+func (e *ErrContentFiltered) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "video was filtered by the moderation system"
+	}
+	if e.Id != "" {
+		msg += fmt.Sprintf(": %s", e.Id)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrMissingVideo struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingVideo) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "response holds no video"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
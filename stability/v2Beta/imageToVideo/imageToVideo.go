@@ -0,0 +1,344 @@
+package imageToVideo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/rmrfslashbin/ami/helper"
+	"github.com/rmrfslashbin/ami/instrument"
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/results"
+)
+
+//https://platform.stability.ai/docs/api-reference#tag/Image-to-Video
+
+/*
+Method: POST
+Headers:
+- authorization: Bearer ${API_KEY}
+- content-type: multipart/form-data
+
+Body: form-data
+Required:
+- image (file) 1024x576, 576x1024 or 768x768
+
+Optional:
+- seed (0 .. 4294967294)
+- cfg_scale ([0 .. 10], default 1.8) how strongly the video sticks to the original image
+- motion_bucket_id ([1 .. 255], default 127) lower values mean less motion
+
+Outputs:
+- {"id"}; the MP4 is fetched from /v2beta/results/{id} with accept: video/* (raw bytes,
+  finish-reason and seed headers) -- OR -- application/json ({video (base64), finish_reason, seed})
+
+Credits: Flat rate of 20 credits per successful generation. You will not be charged for failed generations.
+*/
+
+// MODULE_NAME is the module name
+const MODULE_NAME = "imageToVideo"
+
+// Option is a function that takes a pointer to a Config struct and sets a value.
+type Option func(config *ImageToVideo)
+
+// Configuration structure.
+type ImageToVideo struct {
+	log            *slog.Logger
+	stability      *stability.Stability
+	image          *stability.FilePart
+	seed           *int
+	cfgScale       *float64
+	motionBucketId *int
+	pollInterval   time.Duration
+	pollTimeout    time.Duration
+}
+
+// New creates a new ImageToVideo instance.
+func New(opts ...func(*ImageToVideo)) (*ImageToVideo, error) {
+	config := &ImageToVideo{
+		pollInterval: results.DEFAULT_INTERVAL,
+		pollTimeout:  results.DEFAULT_TIMEOUT,
+	}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.stability == nil {
+		return nil, &ErrMissingStability{}
+	}
+
+	if config.log == nil {
+		return nil, &ErrMissingLogger{}
+	}
+
+	return config, nil
+}
+
+// WithLogger sets the logger for the ImageToVideo instance.
+func WithLogger(log *slog.Logger) Option {
+	return func(config *ImageToVideo) {
+		moduleLogger := log.With(
+			slog.Group("module_info",
+				slog.String("module", MODULE_NAME),
+			),
+		)
+		config.log = moduleLogger
+	}
+}
+
+// WithStability sets the stability instance for the ImageToVideo instance.
+func WithStability(stability *stability.Stability) Option {
+	return func(config *ImageToVideo) {
+		config.stability = stability
+	}
+}
+
+// WithImage sets the starting image, read from a path.
+func WithImage(path string) Option {
+	return func(config *ImageToVideo) {
+		config.image = &stability.FilePart{Path: path}
+	}
+}
+
+// WithImageBytes sets the starting image.
+func WithImageBytes(data []byte) Option {
+	return func(config *ImageToVideo) {
+		config.image = &stability.FilePart{Data: data}
+	}
+}
+
+// WithImageReader sets the starting image, streamed from r. r is read once, so the
+// instance can only start one generation.
+func WithImageReader(r io.Reader) Option {
+	return func(config *ImageToVideo) {
+		config.image = &stability.FilePart{Reader: r}
+	}
+}
+
+// WithSeed sets the seed for the ImageToVideo instance.
+func WithSeed(seed int) Option {
+	return func(config *ImageToVideo) {
+		config.seed = &seed
+	}
+}
+
+// WithCfgScale sets how strongly the video sticks to the starting image, from 0 to 10.
+func WithCfgScale(cfgScale float64) Option {
+	return func(config *ImageToVideo) {
+		config.cfgScale = &cfgScale
+	}
+}
+
+// WithMotionBucketId sets the amount of motion, from 1 to 255. Lower values mean less motion.
+func WithMotionBucketId(motionBucketId int) Option {
+	return func(config *ImageToVideo) {
+		config.motionBucketId = &motionBucketId
+	}
+}
+
+// WithPollInterval sets the time between polls for the video. The default is
+// results.DEFAULT_INTERVAL.
+func WithPollInterval(interval time.Duration) Option {
+	return func(config *ImageToVideo) {
+		config.pollInterval = interval
+	}
+}
+
+// WithPollTimeout sets how long to wait for the video. The default is
+// results.DEFAULT_TIMEOUT.
+func WithPollTimeout(timeout time.Duration) Option {
+	return func(config *ImageToVideo) {
+		config.pollTimeout = timeout
+	}
+}
+
+// SetImage sets the starting image.
+func (c *ImageToVideo) SetImage(image *stability.FilePart) {
+	c.image = image
+}
+
+// SetSeed sets the seed for the ImageToVideo instance.
+func (c *ImageToVideo) SetSeed(seed int) {
+	c.seed = &seed
+}
+
+// SetCfgScale sets how strongly the video sticks to the starting image.
+func (c *ImageToVideo) SetCfgScale(cfgScale float64) {
+	c.cfgScale = &cfgScale
+}
+
+// SetMotionBucketId sets the amount of motion.
+func (c *ImageToVideo) SetMotionBucketId(motionBucketId int) {
+	c.motionBucketId = &motionBucketId
+}
+
+// Generate starts a video and waits for it.
+func (c *ImageToVideo) Generate(ctx context.Context) (*Response, error) {
+	id, err := c.Start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.Result(ctx, id)
+}
+
+// Start starts a video and returns its generation id. Fetch the video with Result, or
+// check on it once with Fetch.
+func (c *ImageToVideo) Start(ctx context.Context) (string, error) {
+	request := &Request{
+		Image:          c.image,
+		Seed:           c.seed,
+		CfgScale:       c.cfgScale,
+		MotionBucketId: c.motionBucketId,
+	}
+	if err := request.Validate(); err != nil {
+		return "", err
+	}
+
+	form := c.stability.NewRequest(stability.METHOD_POST, PATH)
+	form.AddFormPart("image", request.Image)
+	addField(form, "seed", request.Seed)
+	addField(form, "cfg_scale", request.CfgScale)
+	addField(form, "motion_bucket_id", request.MotionBucketId)
+
+	res, err := c.stability.DoContext(ctx, form)
+	if err != nil {
+		return "", err
+	}
+	id, err := results.DecodeId(res)
+	if err != nil {
+		return "", err
+	}
+
+	c.log.Info("video started", slog.String("id", id))
+	return id, nil
+}
+
+// Fetch checks on a video once. It returns a results.ErrPending while the video is in
+// progress and a results.ErrFailed if it failed.
+func (c *ImageToVideo) Fetch(ctx context.Context, id string) (*Response, error) {
+	poller, err := c.poller()
+	if err != nil {
+		return nil, err
+	}
+	res, err := poller.Fetch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return c.finish(ctx, id, res)
+}
+
+// Result waits for a video started with Start. It returns a results.ErrTimeout if the
+// video is not ready within the poll timeout and a results.ErrFailed if it failed.
+func (c *ImageToVideo) Result(ctx context.Context, id string) (*Response, error) {
+	poller, err := c.poller()
+	if err != nil {
+		return nil, err
+	}
+	res, err := poller.Wait(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return c.finish(ctx, id, res)
+}
+
+// Save writes the MP4 to filename.
+func (r *Response) Save(filename string) error {
+	if len(r.Video) == 0 {
+		return &ErrMissingVideo{}
+	}
+	mode := os.FileMode(0644)
+	return helper.SaveFile(&helper.SaveFileInput{
+		Filename: filename,
+		Data:     r.Video,
+		FileMode: &mode,
+	})
+}
+
+// poller returns a results poller asking for raw MP4 bytes.
+func (c *ImageToVideo) poller() (*results.Poller, error) {
+	return results.New(
+		results.WithStability(c.stability),
+		results.WithLogger(c.log),
+		results.WithInterval(c.pollInterval),
+		results.WithTimeout(c.pollTimeout),
+		results.WithAccept(ACCEPT_VIDEO),
+	)
+}
+
+// finish decodes a finished video, logs it and records its credits.
+func (c *ImageToVideo) finish(ctx context.Context, id string, res *stability.StabilityResponse) (*Response, error) {
+	response, err := decode(id, res)
+	if err != nil {
+		return response, err
+	}
+
+	attrs := []any{slog.String("id", id), slog.Int("bytes", len(response.Video))}
+	if response.FinishReason != nil {
+		attrs = append(attrs, slog.String("finish_reason", *response.FinishReason))
+	}
+	if response.Seed != nil {
+		attrs = append(attrs, slog.Int("seed", *response.Seed))
+	}
+	c.log.Info("video generated", attrs...)
+
+	c.stability.GetInstrumentation().Add(ctx, instrument.METRIC_CREDITS, CREDITS,
+		instrument.String(instrument.ATTR_STABILITY_IMAGE_MODEL, MODULE_NAME))
+
+	if response.FinishReason != nil && *response.FinishReason == FINISH_REASON_CONTENT_FILTERED {
+		return response, &ErrContentFiltered{Id: id}
+	}
+	return response, nil
+}
+
+// decode reads a finished video, sent either as raw MP4 bytes with finish-reason and seed
+// headers or as JSON with the video base64 encoded.
+func decode(id string, res *stability.StabilityResponse) (*Response, error) {
+	response := &Response{Id: id}
+
+	if res.IsJSON() {
+		body := &struct {
+			Video        string  `json:"video"`
+			FinishReason *string `json:"finish_reason"`
+			Seed         *int    `json:"seed"`
+		}{}
+		if err := json.Unmarshal(res.Body, body); err != nil {
+			return nil, &ErrUnexpectedContentType{Err: err, ContentType: res.ContentType()}
+		}
+		video, err := base64.StdEncoding.DecodeString(body.Video)
+		if err != nil {
+			return nil, &ErrMissingVideo{Err: err}
+		}
+		response.Video = video
+		response.FinishReason = body.FinishReason
+		response.Seed = body.Seed
+	} else {
+		if contentType := res.ContentType(); contentType != CONTENT_TYPE_MP4 {
+			return nil, &ErrUnexpectedContentType{ContentType: contentType}
+		}
+		response.Video = res.Body
+		if finishReason := res.FinishReason(); finishReason != "" {
+			response.FinishReason = &finishReason
+		}
+		if seed, ok := res.Seed(); ok {
+			response.Seed = &seed
+		}
+	}
+
+	if len(response.Video) == 0 && (response.FinishReason == nil || *response.FinishReason != FINISH_REASON_CONTENT_FILTERED) {
+		return nil, &ErrMissingVideo{}
+	}
+	return response, nil
+}
+
+// addField appends a form field if it is set.
+func addField[T any](form *stability.Request, key string, field *T) {
+	if field != nil {
+		form.AddFormPart(key, *field)
+	}
+}
@@ -0,0 +1,149 @@
+package imageToVideo_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rmrfslashbin/ami/stability/stabilitytest"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/imageToVideo"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/results"
+)
+
+var log = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// pngImage returns a PNG of the given size.
+func pngImage(t *testing.T, width int, height int) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("encoding PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func newVideo(t *testing.T, server *stabilitytest.Server, opts ...func(*imageToVideo.ImageToVideo)) *imageToVideo.ImageToVideo {
+	t.Helper()
+	s, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	opts = append([]func(*imageToVideo.ImageToVideo){
+		imageToVideo.WithStability(s),
+		imageToVideo.WithLogger(log),
+		imageToVideo.WithPollInterval(time.Millisecond),
+	}, opts...)
+	v, err := imageToVideo.New(opts...)
+	if err != nil {
+		t.Fatalf("imageToVideo.New: %v", err)
+	}
+	return v
+}
+
+func newServer(t *testing.T, opts ...func(*stabilitytest.Server)) *stabilitytest.Server {
+	t.Helper()
+	server, err := stabilitytest.New(opts...)
+	if err != nil {
+		t.Fatalf("stabilitytest.New: %v", err)
+	}
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGenerate(t *testing.T) {
+	server := newServer(t, stabilitytest.WithPendingPolls(2))
+	video := []byte("not really an mp4")
+	server.Enqueue(&stabilitytest.Reply{Video: video})
+	v := newVideo(t, server, imageToVideo.WithImageBytes(pngImage(t, 768, 768)), imageToVideo.WithSeed(9), imageToVideo.WithMotionBucketId(127))
+
+	res, err := v.Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if !bytes.Equal(res.Video, video) || res.Seed == nil || *res.Seed != 9 || res.FinishReason == nil || *res.FinishReason != imageToVideo.FINISH_REASON_SUCCESS {
+		t.Errorf("Generate = %d bytes, seed %v, finish reason %v", len(res.Video), res.Seed, res.FinishReason)
+	}
+
+	// the start request, two pending polls and the raw MP4
+	requests := server.Requests()
+	if len(requests) != 4 || requests[3].Header.Get("accept") != imageToVideo.ACCEPT_VIDEO {
+		t.Errorf("server received %d requests", len(requests))
+	}
+	if want := stabilitytest.DEFAULT_CREDITS - imageToVideo.CREDITS; server.Credits() != want {
+		t.Errorf("Credits = %g, want %g", server.Credits(), want)
+	}
+	if err := server.Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "video.mp4")
+	if err := res.Save(filename); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if saved, err := os.ReadFile(filename); err != nil || !bytes.Equal(saved, video) {
+		t.Errorf("saved %d bytes, %v", len(saved), err)
+	}
+}
+
+func TestFetchPending(t *testing.T) {
+	server := newServer(t)
+	v := newVideo(t, server, imageToVideo.WithImageBytes(pngImage(t, 1024, 576)))
+
+	id, err := v.Start(context.Background())
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	var pending *results.ErrPending
+	if _, err := v.Fetch(context.Background(), id); !errors.As(err, &pending) {
+		t.Fatalf("Fetch = %v, want ErrPending", err)
+	}
+	if res, err := v.Fetch(context.Background(), id); err != nil || len(res.Video) == 0 {
+		t.Errorf("Fetch = %+v, %v, want the video", res, err)
+	}
+}
+
+func TestContentFiltered(t *testing.T) {
+	server := newServer(t)
+	server.Enqueue(stabilitytest.Filtered())
+	v := newVideo(t, server, imageToVideo.WithImageBytes(pngImage(t, 576, 1024)))
+
+	res, err := v.Generate(context.Background())
+	var filtered *imageToVideo.ErrContentFiltered
+	if !errors.As(err, &filtered) || res == nil || *res.FinishReason != imageToVideo.FINISH_REASON_CONTENT_FILTERED {
+		t.Errorf("Generate = %+v, %v, want ErrContentFiltered with the response", res, err)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	server := newServer(t)
+
+	// the size is checked by the API, and failed starts are not charged
+	var failed *results.ErrFailed
+	if _, err := newVideo(t, server, imageToVideo.WithImageBytes(pngImage(t, 512, 512))).Start(context.Background()); !errors.As(err, &failed) || failed.StatusCode != http.StatusBadRequest {
+		t.Errorf("Start with a 512x512 image = %v, want ErrFailed 400", err)
+	}
+	if server.Credits() != stabilitytest.DEFAULT_CREDITS {
+		t.Errorf("Credits = %g, want no charge", server.Credits())
+	}
+
+	var cfgScale *imageToVideo.ErrInvalidCfgScale
+	if _, err := newVideo(t, server, imageToVideo.WithImageBytes(pngImage(t, 768, 768)), imageToVideo.WithCfgScale(11)).Start(context.Background()); !errors.As(err, &cfgScale) {
+		t.Errorf("Start = %v, want ErrInvalidCfgScale", err)
+	}
+	var missing *imageToVideo.ErrMissingImage
+	if _, err := newVideo(t, server).Start(context.Background()); !errors.As(err, &missing) {
+		t.Errorf("Start = %v, want ErrMissingImage", err)
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("server received %d requests, want only the 512x512 one", n)
+	}
+}
@@ -0,0 +1,44 @@
+package imageToVideo
+
+import (
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/validate"
+)
+
+// Request holds the form fields of an image-to-video request. The tags are checked by
+// validate.Struct.
+type Request struct {
+	Image          *stability.FilePart `json:"image" required:"true"`
+	Seed           *int                `json:"seed" min:"0" max:"4294967294"`
+	CfgScale       *float64            `json:"cfg_scale" min:"0" max:"10"`
+	MotionBucketId *int                `json:"motion_bucket_id" min:"1" max:"255"`
+}
+
+// Response is a finished video.
+type Response struct {
+	// Id is the generation id.
+	Id string
+
+	// Video is the MP4 file.
+	Video []byte
+
+	// FinishReason is SUCCESS or CONTENT_FILTERED.
+	FinishReason *string
+
+	// Seed is the seed used.
+	Seed *int
+}
+
+// fieldErrors maps request fields to this package's typed errors.
+var fieldErrors = validate.ErrorMap{
+	"image":            func(err error) error { return &ErrMissingImage{Err: err} },
+	"seed":             func(err error) error { return &ErrInvalidSeed{Err: err} },
+	"cfg_scale":        func(err error) error { return &ErrInvalidCfgScale{Err: err} },
+	"motion_bucket_id": func(err error) error { return &ErrInvalidMotionBucketId{Err: err} },
+}
+
+// Validate checks the request against its tags and maps every violation onto this
+// package's typed errors. All errors are returned joined.
+func (r *Request) Validate() error {
+	return validate.StructErrors(r, fieldErrors)
+}
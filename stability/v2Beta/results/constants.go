@@ -0,0 +1,15 @@
+package results
+
+import "time"
+
+// PATH is the path of results requests; the generation id is appended
+const PATH = "/v2beta/results/"
+
+// DEFAULT_INTERVAL is the default time between polls
+const DEFAULT_INTERVAL = 10 * time.Second
+
+// DEFAULT_TIMEOUT is the default time to wait for a result
+const DEFAULT_TIMEOUT = 10 * time.Minute
+
+// DEFAULT_ACCEPT asks for the result as JSON, with media base64 encoded
+const DEFAULT_ACCEPT = "application/json"
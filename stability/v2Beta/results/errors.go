@@ -0,0 +1,115 @@
+package results
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type ErrMissingStability struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingStability) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing stability- use WithStability to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrMissingId struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingId) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing generation id"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrPending struct {
+	Err error
+	Msg string
+	Id  string
+}
+
+func (e *ErrPending) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "generation still in progress"
+	}
+	if e.Id != "" {
+		msg += fmt.Sprintf(": %s", e.Id)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrTimeout struct {
+	Err     error
+	Msg     string
+	Id      string
+	Timeout time.Duration
+}
+
+func (e *ErrTimeout) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "timed out waiting for generation"
+	}
+	if e.Id != "" {
+		msg += fmt.Sprintf(" %s", e.Id)
+	}
+	if e.Timeout != 0 {
+		msg += fmt.Sprintf(" after %s", e.Timeout)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrFailed struct {
+	Err        error
+	Msg        string
+	Id         string
+	StatusCode int
+	Name       string
+	Errors     []string
+}
+
+func (e *ErrFailed) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "generation failed"
+	}
+	if e.Id != "" {
+		msg += fmt.Sprintf(" %s", e.Id)
+	}
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(": %d", e.StatusCode)
+	}
+	if e.Name != "" {
+		msg += fmt.Sprintf(" %s", e.Name)
+	}
+	if len(e.Errors) > 0 {
+		msg += ": " + strings.Join(e.Errors, "; ")
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
@@ -0,0 +1,204 @@
+package results
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/rmrfslashbin/ami/stability"
+)
+
+//https://platform.stability.ai/docs/api-reference#tag/Results
+
+/*
+Method: GET /v2beta/results/{id}
+Headers:
+- authorization: Bearer ${API_KEY}
+- accept: application/json to receive media as base64 -- OR -- the media type, e.g. image/* or video/*
+
+Responses:
+- 200: the finished generation
+- 202: {"id", "status": "in-progress"}; poll again later
+- 4xx/5xx: {"id", "name", "errors"}; the generation failed or the id is unknown
+
+Results are kept for 24 hours.
+*/
+
+// MODULE_NAME is the module name
+const MODULE_NAME = "results"
+
+// Option is a function that takes a pointer to a Config struct and sets a value.
+type Option func(config *Poller)
+
+// Poller fetches the results of asynchronous generations, e.g. creative upscales.
+type Poller struct {
+	log       *slog.Logger
+	stability *stability.Stability
+	interval  time.Duration
+	timeout   time.Duration
+	accept    string
+}
+
+// New creates a new Poller instance.
+func New(opts ...func(*Poller)) (*Poller, error) {
+	config := &Poller{
+		interval: DEFAULT_INTERVAL,
+		timeout:  DEFAULT_TIMEOUT,
+		accept:   DEFAULT_ACCEPT,
+	}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.stability == nil {
+		return nil, &ErrMissingStability{}
+	}
+
+	if config.log == nil {
+		config.log = config.stability.GetLogger()
+	}
+
+	return config, nil
+}
+
+// WithLogger sets the logger for the Poller instance.
+func WithLogger(log *slog.Logger) Option {
+	return func(config *Poller) {
+		moduleLogger := log.With(
+			slog.Group("module_info",
+				slog.String("module", MODULE_NAME),
+			),
+		)
+		config.log = moduleLogger
+	}
+}
+
+// WithStability sets the stability instance for the Poller instance.
+func WithStability(stability *stability.Stability) Option {
+	return func(config *Poller) {
+		config.stability = stability
+	}
+}
+
+// WithInterval sets the time between polls. The default is DEFAULT_INTERVAL.
+func WithInterval(interval time.Duration) Option {
+	return func(config *Poller) {
+		config.interval = interval
+	}
+}
+
+// WithTimeout sets how long Wait polls before giving up. The default is DEFAULT_TIMEOUT.
+func WithTimeout(timeout time.Duration) Option {
+	return func(config *Poller) {
+		config.timeout = timeout
+	}
+}
+
+// WithAccept sets the accept header of results requests, e.g. "video/*" to receive raw
+// MP4 bytes. The default is DEFAULT_ACCEPT.
+func WithAccept(accept string) Option {
+	return func(config *Poller) {
+		config.accept = accept
+	}
+}
+
+// Fetch requests a result once. It returns an ErrPending while the generation is in
+// progress and an ErrFailed if it failed.
+func (p *Poller) Fetch(ctx context.Context, id string) (*stability.StabilityResponse, error) {
+	if id == "" {
+		return nil, &ErrMissingId{}
+	}
+
+	request := p.stability.NewRequest(stability.METHOD_GET, PATH+id)
+	request.AddHeader("accept", p.accept)
+
+	res, err := p.stability.DoContext(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return res, nil
+	case http.StatusAccepted:
+		return nil, &ErrPending{Id: id}
+	default:
+		failure := &ResponseErrors{}
+		json.Unmarshal(res.Body, failure)
+		return nil, &ErrFailed{
+			Id:         id,
+			StatusCode: res.StatusCode,
+			Name:       failure.Name,
+			Errors:     failure.Errors,
+		}
+	}
+}
+
+// Wait polls a result every interval until it is ready, it fails, the timeout passes or
+// ctx is done. A timeout is reported as ErrTimeout; a done ctx as its error.
+func (p *Poller) Wait(ctx context.Context, id string) (*stability.StabilityResponse, error) {
+	pollCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		res, err := p.Fetch(pollCtx, id)
+
+		var pending *ErrPending
+		if err == nil || !errors.As(err, &pending) {
+			if err != nil && ctx.Err() == nil && errors.Is(pollCtx.Err(), context.DeadlineExceeded) {
+				return nil, &ErrTimeout{Err: err, Id: id, Timeout: p.timeout}
+			}
+			if err == nil {
+				p.log.Debug("result ready",
+					slog.String("id", id),
+					slog.Int("polls", attempt),
+					slog.Duration("waited", time.Since(start)))
+			}
+			return res, err
+		}
+
+		p.log.Debug("result pending", slog.String("id", id), slog.Int("polls", attempt))
+
+		select {
+		case <-ticker.C:
+		case <-pollCtx.Done():
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, &ErrTimeout{Id: id, Timeout: p.timeout}
+		}
+	}
+}
+
+// DecodeId decodes the generation id from the response of an asynchronous request.
+// Error statuses are returned as ErrFailed.
+func DecodeId(res *stability.StabilityResponse) (string, error) {
+	failure := &ResponseErrors{}
+	if res.StatusCode != http.StatusOK {
+		json.Unmarshal(res.Body, failure)
+		return "", &ErrFailed{
+			Id:         failure.Id,
+			StatusCode: res.StatusCode,
+			Name:       failure.Name,
+			Errors:     failure.Errors,
+		}
+	}
+
+	response := &ResponseId{}
+	if err := json.Unmarshal(res.Body, response); err != nil {
+		return "", &ErrFailed{StatusCode: res.StatusCode, Err: err}
+	}
+	if response.Id == "" {
+		return "", &ErrMissingId{}
+	}
+	return response.Id, nil
+}
@@ -0,0 +1,134 @@
+package results_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/stability/stabilitytest"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/results"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/upscale"
+)
+
+var log = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// start starts a creative upscale on a server answering pending polls 202s and returns
+// the client and the generation id.
+func start(t *testing.T, pending int) (*stabilitytest.Server, *stability.Stability, string) {
+	t.Helper()
+	server, err := stabilitytest.New(stabilitytest.WithPendingPolls(pending))
+	if err != nil {
+		t.Fatalf("stabilitytest.New: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	s, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	u, err := upscale.New(upscale.WithStability(s), upscale.WithLogger(log), upscale.WithImageBytes(buf.Bytes()), upscale.WithPrompt("a cat"))
+	if err != nil {
+		t.Fatalf("upscale.New: %v", err)
+	}
+	id, err := u.StartCreative(context.Background())
+	if err != nil {
+		t.Fatalf("StartCreative: %v", err)
+	}
+	return server, s, id
+}
+
+func newPoller(t *testing.T, s *stability.Stability, opts ...func(*results.Poller)) *results.Poller {
+	t.Helper()
+	opts = append([]func(*results.Poller){results.WithStability(s), results.WithLogger(log), results.WithInterval(time.Millisecond)}, opts...)
+	p, err := results.New(opts...)
+	if err != nil {
+		t.Fatalf("results.New: %v", err)
+	}
+	return p
+}
+
+func TestFetch(t *testing.T) {
+	_, s, id := start(t, 1)
+	p := newPoller(t, s)
+
+	var pending *results.ErrPending
+	if _, err := p.Fetch(context.Background(), id); !errors.As(err, &pending) || pending.Id != id {
+		t.Fatalf("first Fetch = %v, want ErrPending", err)
+	}
+	res, err := p.Fetch(context.Background(), id)
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("second Fetch = %v, %v", res, err)
+	}
+
+	// results are removed once fetched
+	var failed *results.ErrFailed
+	if _, err := p.Fetch(context.Background(), id); !errors.As(err, &failed) || failed.StatusCode != http.StatusNotFound {
+		t.Errorf("third Fetch = %v, want ErrFailed 404", err)
+	}
+	var missing *results.ErrMissingId
+	if _, err := p.Fetch(context.Background(), ""); !errors.As(err, &missing) {
+		t.Errorf("Fetch without id = %v, want ErrMissingId", err)
+	}
+}
+
+func TestWait(t *testing.T) {
+	server, s, id := start(t, 3)
+
+	res, err := newPoller(t, s).Wait(context.Background(), id)
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("Wait = %v, %v", res, err)
+	}
+	if n := len(server.Requests()); n != 5 {
+		t.Errorf("server received %d requests, want the start, 3 pending polls and the result", n)
+	}
+	if err := server.Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	_, s, id := start(t, 1000)
+
+	var timeout *results.ErrTimeout
+	_, err := newPoller(t, s, results.WithTimeout(50*time.Millisecond)).Wait(context.Background(), id)
+	if !errors.As(err, &timeout) || timeout.Id != id || timeout.Timeout != 50*time.Millisecond {
+		t.Errorf("Wait = %v, want ErrTimeout", err)
+	}
+}
+
+func TestWaitCanceled(t *testing.T) {
+	_, s, id := start(t, 1000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	_, err := newPoller(t, s).Wait(ctx, id)
+	var timeout *results.ErrTimeout
+	if !errors.Is(err, context.Canceled) || errors.As(err, &timeout) {
+		t.Errorf("Wait = %v, want context.Canceled", err)
+	}
+}
+
+func TestErrorMessages(t *testing.T) {
+	err := &results.ErrTimeout{Id: "abc", Timeout: time.Minute}
+	want := "timed out waiting for generation abc after 1m0s"
+	for i := 0; i < 2; i++ {
+		if got := err.Error(); got != want {
+			t.Errorf("Error call %d = %q, want %q", i, got, want)
+		}
+	}
+	if err.Msg != "" {
+		t.Errorf("Error set Msg to %q", err.Msg)
+	}
+}
@@ -0,0 +1,13 @@
+package results
+
+// ResponseId is the body of an asynchronous request: the id of the generation to poll.
+type ResponseId struct {
+	Id string `json:"id"`
+}
+
+// ResponseErrors is the body of a failed generation.
+type ResponseErrors struct {
+	Errors []string `json:"errors,omitempty"`
+	Id     string   `json:"id,omitempty"`
+	Name   string   `json:"name,omitempty"`
+}
@@ -0,0 +1,20 @@
+package stableFast3d
+
+// PATH is the path of Stable Fast 3D requests
+const PATH = "/v2beta/3d/stable-fast-3d"
+
+// CREDITS is the price of a successful model
+const CREDITS = 2.0
+
+// CONTENT_TYPE_GLB is the content type of a binary glTF model
+const CONTENT_TYPE_GLB = "model/gltf-binary"
+
+// TEXTURE_RESOLUTIONS is a list of valid texture resolutions, in pixels
+var TEXTURE_RESOLUTIONS = []string{"512", "1024", "2048"}
+
+// REMESH_MODES is a list of valid remesh modes
+var REMESH_MODES = []string{"none", "triangle", "quad"}
+
+// MIN_FOREGROUND_RATIO and MAX_FOREGROUND_RATIO bound the foreground ratio
+const MIN_FOREGROUND_RATIO = 0.1
+const MAX_FOREGROUND_RATIO = 1
@@ -0,0 +1,154 @@
+package stableFast3d
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ErrMissingStability struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingStability) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing stability- use WithStability to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrMissingLogger struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingLogger) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing logger- use WithLogger to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrMissingImage struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingImage) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing image- use WithImage or SetImage to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidTextureResolution struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidTextureResolution) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid texture resolution- use WithTextureResolution or SetTextureResolution to set it. Must be one of " + strings.Join(TEXTURE_RESOLUTIONS, ", ")
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidForegroundRatio struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidForegroundRatio) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid foreground ratio- use WithForegroundRatio or SetForegroundRatio to set it. Must be between %g and %d", MIN_FOREGROUND_RATIO, MAX_FOREGROUND_RATIO)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidRemesh struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidRemesh) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid remesh mode- use WithRemesh or SetRemesh to set it. Must be one of " + strings.Join(REMESH_MODES, ", ")
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrUnexpectedContentType struct {
+	Err         error
+	Msg         string
+	ContentType string
+}
+
+func (e *ErrUnexpectedContentType) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "unexpected content type; expected " + CONTENT_TYPE_GLB
+	}
+	if e.ContentType != "" {
+		msg += fmt.Sprintf(": %s", e.ContentType)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrMissingModel struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingModel) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "response holds no model"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidGLB struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidGLB) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid GLB"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
@@ -0,0 +1,187 @@
+package stableFast3d
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// GLB layout: a 12 byte header (magic, version, length) followed by chunks, each a
+// 4 byte length, a 4 byte type and the data. The first chunk is JSON, the optional
+// second one the binary buffer.
+// https://registry.khronos.org/glTF/specs/2.0/glTF-2.0.html#binary-gltf-layout
+const (
+	GLB_MAGIC      = 0x46546C67 // "glTF"
+	GLB_CHUNK_JSON = 0x4E4F534A // "JSON"
+	GLB_CHUNK_BIN  = 0x004E4942 // "BIN\x00"
+)
+
+// GLB is the metadata of a binary glTF model.
+type GLB struct {
+	// Version is the glTF container version, 2 for every current file.
+	Version uint32
+
+	// Length is the total length of the file in bytes.
+	Length uint32
+
+	// Generator is the tool that wrote the file, if it says so.
+	Generator string
+
+	Meshes    []Mesh
+	Textures  []Texture
+	Materials int
+}
+
+// Mesh is the metadata of a mesh.
+type Mesh struct {
+	Name       string
+	Primitives int
+
+	// Vertices is the number of vertex positions over all primitives.
+	Vertices int
+
+	// Triangles is the number of triangles over all indexed triangle primitives.
+	Triangles int
+}
+
+// Texture is the metadata of an embedded texture image.
+type Texture struct {
+	Name     string
+	MimeType string
+	Bytes    int
+
+	// Width and Height are read from PNG and JPEG images, and zero otherwise.
+	Width  int
+	Height int
+}
+
+// gltf is the part of the glTF JSON needed for the metadata.
+type gltf struct {
+	Asset struct {
+		Version   string `json:"version"`
+		Generator string `json:"generator"`
+	} `json:"asset"`
+	Meshes []struct {
+		Name       string `json:"name"`
+		Primitives []struct {
+			Attributes map[string]int `json:"attributes"`
+			Indices    *int           `json:"indices"`
+			Mode       *int           `json:"mode"`
+		} `json:"primitives"`
+	} `json:"meshes"`
+	Accessors []struct {
+		Count int `json:"count"`
+	} `json:"accessors"`
+	BufferViews []struct {
+		Buffer     int `json:"buffer"`
+		ByteOffset int `json:"byteOffset"`
+		ByteLength int `json:"byteLength"`
+	} `json:"bufferViews"`
+	Images []struct {
+		Name       string `json:"name"`
+		MimeType   string `json:"mimeType"`
+		BufferView *int   `json:"bufferView"`
+	} `json:"images"`
+	Materials []json.RawMessage `json:"materials"`
+}
+
+// ParseGLB reads the meshes and textures of a binary glTF model without loading its
+// geometry.
+func ParseGLB(data []byte) (*GLB, error) {
+	if len(data) < 20 {
+		return nil, &ErrInvalidGLB{Err: errors.New("file is too short")}
+	}
+	if binary.LittleEndian.Uint32(data[0:4]) != GLB_MAGIC {
+		return nil, &ErrInvalidGLB{Err: errors.New("missing glTF magic")}
+	}
+
+	glb := &GLB{
+		Version: binary.LittleEndian.Uint32(data[4:8]),
+		Length:  binary.LittleEndian.Uint32(data[8:12]),
+	}
+	if int(glb.Length) > len(data) {
+		return nil, &ErrInvalidGLB{Err: fmt.Errorf("file is truncated: %d of %d bytes", len(data), glb.Length)}
+	}
+
+	// walk the chunks
+	var jsonChunk, binChunk []byte
+	for offset := 12; offset+8 <= int(glb.Length); {
+		length := int(binary.LittleEndian.Uint32(data[offset : offset+4]))
+		chunkType := binary.LittleEndian.Uint32(data[offset+4 : offset+8])
+		start := offset + 8
+		if length < 0 || start+length > int(glb.Length) {
+			return nil, &ErrInvalidGLB{Err: fmt.Errorf("chunk at %d overruns the file", offset)}
+		}
+		switch chunkType {
+		case GLB_CHUNK_JSON:
+			jsonChunk = data[start : start+length]
+		case GLB_CHUNK_BIN:
+			binChunk = data[start : start+length]
+		}
+		offset = start + length
+	}
+	if jsonChunk == nil {
+		return nil, &ErrInvalidGLB{Err: errors.New("missing JSON chunk")}
+	}
+
+	doc := &gltf{}
+	if err := json.Unmarshal(bytes.TrimRight(jsonChunk, " \x00"), doc); err != nil {
+		return nil, &ErrInvalidGLB{Err: err}
+	}
+	glb.Generator = doc.Asset.Generator
+	glb.Materials = len(doc.Materials)
+
+	// count the accessor, or zero if the index is out of range
+	count := func(index int) int {
+		if index < 0 || index >= len(doc.Accessors) {
+			return 0
+		}
+		return doc.Accessors[index].Count
+	}
+
+	for _, m := range doc.Meshes {
+		mesh := Mesh{Name: m.Name, Primitives: len(m.Primitives)}
+		for _, primitive := range m.Primitives {
+			if position, ok := primitive.Attributes["POSITION"]; ok {
+				mesh.Vertices += count(position)
+			}
+			// mode 4 (triangles) is the default
+			if primitive.Mode != nil && *primitive.Mode != 4 {
+				continue
+			}
+			if primitive.Indices != nil {
+				mesh.Triangles += count(*primitive.Indices) / 3
+			} else if position, ok := primitive.Attributes["POSITION"]; ok {
+				mesh.Triangles += count(position) / 3
+			}
+		}
+		glb.Meshes = append(glb.Meshes, mesh)
+	}
+
+	for _, img := range doc.Images {
+		texture := Texture{Name: img.Name, MimeType: img.MimeType}
+		if img.BufferView != nil && *img.BufferView >= 0 && *img.BufferView < len(doc.BufferViews) {
+			view := doc.BufferViews[*img.BufferView]
+			if view.ByteLength < 0 || view.ByteOffset < 0 {
+				return nil, &ErrInvalidGLB{Err: fmt.Errorf("buffer view %d has a negative offset or length", *img.BufferView)}
+			}
+			texture.Bytes = view.ByteLength
+			// only buffer 0 lives in the BIN chunk; compare without adding, which could overflow
+			if view.Buffer == 0 && view.ByteOffset <= len(binChunk) && view.ByteLength <= len(binChunk)-view.ByteOffset {
+				config, _, err := image.DecodeConfig(bytes.NewReader(binChunk[view.ByteOffset : view.ByteOffset+view.ByteLength]))
+				if err == nil {
+					texture.Width = config.Width
+					texture.Height = config.Height
+				}
+			}
+		}
+		glb.Textures = append(glb.Textures, texture)
+	}
+
+	return glb, nil
+}
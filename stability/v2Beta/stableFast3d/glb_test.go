@@ -0,0 +1,159 @@
+package stableFast3d_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/png"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableFast3d"
+)
+
+// MODEL_JSON describes a model with an indexed mesh, a point cloud and a PNG texture in
+// buffer view 0. Accessors: 0 positions (24), 1 indices (36), 2 points (10).
+const MODEL_JSON = `{
+	"asset": {"version": "2.0", "generator": "glb_test"},
+	"meshes": [
+		{"name": "cube", "primitives": [{"attributes": {"POSITION": 0}, "indices": 1}]},
+		{"name": "cloud", "primitives": [{"attributes": {"POSITION": 2}, "mode": 0}, {"attributes": {"POSITION": 0}}]}
+	],
+	"accessors": [{"count": 24}, {"count": 36}, {"count": 10}],
+	"bufferViews": [{"buffer": 0, "byteOffset": 0, "byteLength": %LENGTH%}],
+	"images": [{"name": "albedo", "mimeType": "image/png", "bufferView": 0}],
+	"materials": [{}, {}]
+}`
+
+// texture returns a 3x2 PNG.
+func texture(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatalf("encoding PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// chunk returns a GLB chunk, padded to 4 bytes.
+func chunk(chunkType uint32, data []byte, pad byte) []byte {
+	for len(data)%4 != 0 {
+		data = append(data, pad)
+	}
+	out := binary.LittleEndian.AppendUint32(nil, uint32(len(data)))
+	out = binary.LittleEndian.AppendUint32(out, chunkType)
+	return append(out, data...)
+}
+
+// glb assembles a GLB file from chunks.
+func glb(chunks ...[]byte) []byte {
+	body := bytes.Join(chunks, nil)
+	out := binary.LittleEndian.AppendUint32(nil, stableFast3d.GLB_MAGIC)
+	out = binary.LittleEndian.AppendUint32(out, 2)
+	out = binary.LittleEndian.AppendUint32(out, uint32(12+len(body)))
+	return append(out, body...)
+}
+
+// model returns a GLB with json as its JSON chunk, %LENGTH% replaced by the texture size.
+func model(t *testing.T, json string) []byte {
+	t.Helper()
+	tex := texture(t)
+	json = strings.ReplaceAll(json, "%LENGTH%", strconv.Itoa(len(tex)))
+	return glb(chunk(stableFast3d.GLB_CHUNK_JSON, []byte(json), ' '), chunk(stableFast3d.GLB_CHUNK_BIN, tex, 0))
+}
+
+func TestParseGLB(t *testing.T) {
+	data := model(t, MODEL_JSON)
+	got, err := stableFast3d.ParseGLB(data)
+	if err != nil {
+		t.Fatalf("ParseGLB: %v", err)
+	}
+
+	want := &stableFast3d.GLB{
+		Version:   2,
+		Length:    uint32(len(data)),
+		Generator: "glb_test",
+		Meshes: []stableFast3d.Mesh{
+			{Name: "cube", Primitives: 1, Vertices: 24, Triangles: 12},
+			// points are not triangles; the second primitive is unindexed triangles
+			{Name: "cloud", Primitives: 2, Vertices: 34, Triangles: 8},
+		},
+		Textures:  []stableFast3d.Texture{{Name: "albedo", MimeType: "image/png", Bytes: len(texture(t)), Width: 3, Height: 2}},
+		Materials: 2,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseGLB =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseGLBTolerated(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want stableFast3d.Texture
+	}{
+		{name: "offset past the buffer", json: `{"bufferViews": [{"byteOffset": 1000000, "byteLength": 4}], "images": [{"bufferView": 0}]}`,
+			want: stableFast3d.Texture{Bytes: 4}},
+		{name: "length past the buffer", json: `{"bufferViews": [{"byteLength": 1000000}], "images": [{"bufferView": 0}]}`,
+			want: stableFast3d.Texture{Bytes: 1000000}},
+		{name: "offset that would overflow", json: `{"bufferViews": [{"byteOffset": 9223372036854775807, "byteLength": 1}], "images": [{"bufferView": 0}]}`,
+			want: stableFast3d.Texture{Bytes: 1}},
+		{name: "buffer view out of range", json: `{"images": [{"name": "lost", "bufferView": 5}]}`,
+			want: stableFast3d.Texture{Name: "lost"}},
+		{name: "other buffer", json: `{"bufferViews": [{"buffer": 1, "byteLength": 4}], "images": [{"bufferView": 0}]}`,
+			want: stableFast3d.Texture{Bytes: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stableFast3d.ParseGLB(model(t, tt.json))
+			if err != nil {
+				t.Fatalf("ParseGLB: %v", err)
+			}
+			if len(got.Textures) != 1 || got.Textures[0] != tt.want {
+				t.Errorf("Textures = %+v, want [%+v]", got.Textures, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGLBInvalid(t *testing.T) {
+	valid := glb(chunk(stableFast3d.GLB_CHUNK_JSON, []byte(`{}`), ' '))
+
+	badMagic := bytes.Clone(valid)
+	copy(badMagic, "gltf")
+
+	truncated := glb(chunk(stableFast3d.GLB_CHUNK_JSON, []byte(`{"asset": {}}`), ' '))
+	truncated = truncated[:len(truncated)-4]
+
+	overrun := glb(chunk(stableFast3d.GLB_CHUNK_JSON, []byte(`{}`), ' '))
+	binary.LittleEndian.PutUint32(overrun[12:16], 0xFFFFFFF0)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "too short", data: valid[:19]},
+		{name: "bad magic", data: badMagic},
+		{name: "truncated", data: truncated},
+		{name: "chunk overruns the file", data: overrun},
+		{name: "missing JSON chunk", data: glb(chunk(stableFast3d.GLB_CHUNK_BIN, []byte{1, 2, 3, 4}, 0))},
+		{name: "invalid JSON", data: glb(chunk(stableFast3d.GLB_CHUNK_JSON, []byte(`{"meshes": `), ' '))},
+		{name: "negative byteLength", data: model(t, `{"bufferViews": [{"byteLength": -1}], "images": [{"bufferView": 0}]}`)},
+		{name: "negative byteOffset", data: model(t, `{"bufferViews": [{"byteOffset": -8, "byteLength": 4}], "images": [{"bufferView": 0}]}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := stableFast3d.ParseGLB(tt.data)
+			var invalid *stableFast3d.ErrInvalidGLB
+			if !errors.As(err, &invalid) {
+				t.Errorf("got %v, want ErrInvalidGLB", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package stableFast3d
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/rmrfslashbin/ami/helper"
+	"github.com/rmrfslashbin/ami/instrument"
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate"
+	"github.com/rmrfslashbin/ami/validate"
+)
+
+//https://platform.stability.ai/docs/api-reference#tag/3D
+
+/*
+Method: POST
+Headers:
+- authorization: Bearer ${API_KEY}
+- content-type: multipart/form-data
+
+Body: form-data
+Required:
+- image (file)
+
+Optional:
+- texture_resolution (512, 1024 or 2048; default 1024)
+- foreground_ratio ([0.1 .. 1], default 0.85) padding around the object
+- remesh (none, triangle or quad; default none)
+
+Outputs:
+- binary glTF (model/gltf-binary)
+
+Credits: Flat rate of 2 credits per successful generation. You will not be charged for failed generations.
+*/
+
+// MODULE_NAME is the module name
+const MODULE_NAME = "stableFast3d"
+
+// Option is a function that takes a pointer to a Config struct and sets a value.
+type Option func(config *StableFast3d)
+
+// Configuration structure.
+type StableFast3d struct {
+	log               *slog.Logger
+	stability         *stability.Stability
+	image             *stability.FilePart
+	textureResolution *int
+	foregroundRatio   *float64
+	remesh            *string
+}
+
+func init() {
+	validate.RegisterEnum("stability.3d.texture_resolutions", TEXTURE_RESOLUTIONS)
+	validate.RegisterEnum("stability.3d.remesh_modes", REMESH_MODES)
+}
+
+// New creates a new StableFast3d instance.
+func New(opts ...func(*StableFast3d)) (*StableFast3d, error) {
+	config := &StableFast3d{}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.stability == nil {
+		return nil, &ErrMissingStability{}
+	}
+
+	if config.log == nil {
+		return nil, &ErrMissingLogger{}
+	}
+
+	return config, nil
+}
+
+// WithLogger sets the logger for the StableFast3d instance.
+func WithLogger(log *slog.Logger) Option {
+	return func(config *StableFast3d) {
+		moduleLogger := log.With(
+			slog.Group("module_info",
+				slog.String("module", MODULE_NAME),
+			),
+		)
+		config.log = moduleLogger
+	}
+}
+
+// WithStability sets the stability instance for the StableFast3d instance.
+func WithStability(stability *stability.Stability) Option {
+	return func(config *StableFast3d) {
+		config.stability = stability
+	}
+}
+
+// WithImage sets the image of the object, read from a path.
+func WithImage(path string) Option {
+	return func(config *StableFast3d) {
+		config.image = &stability.FilePart{Path: path}
+	}
+}
+
+// WithImageBytes sets the image of the object.
+func WithImageBytes(data []byte) Option {
+	return func(config *StableFast3d) {
+		config.image = &stability.FilePart{Data: data}
+	}
+}
+
+// WithImageReader sets the image of the object, streamed from r. r is read once, so the
+// instance can only generate once.
+func WithImageReader(r io.Reader) Option {
+	return func(config *StableFast3d) {
+		config.image = &stability.FilePart{Reader: r}
+	}
+}
+
+// WithTextureResolution sets the texture resolution in pixels: 512, 1024 or 2048.
+func WithTextureResolution(textureResolution int) Option {
+	return func(config *StableFast3d) {
+		config.textureResolution = &textureResolution
+	}
+}
+
+// WithForegroundRatio sets how much of the frame the object fills, from 0.1 to 1.
+func WithForegroundRatio(foregroundRatio float64) Option {
+	return func(config *StableFast3d) {
+		config.foregroundRatio = &foregroundRatio
+	}
+}
+
+// WithRemesh sets the remesh mode: none, triangle or quad.
+func WithRemesh(remesh string) Option {
+	return func(config *StableFast3d) {
+		config.remesh = &remesh
+	}
+}
+
+// SetImage sets the image of the object.
+func (c *StableFast3d) SetImage(image *stability.FilePart) {
+	c.image = image
+}
+
+// SetTextureResolution sets the texture resolution in pixels.
+func (c *StableFast3d) SetTextureResolution(textureResolution int) {
+	c.textureResolution = &textureResolution
+}
+
+// SetForegroundRatio sets how much of the frame the object fills.
+func (c *StableFast3d) SetForegroundRatio(foregroundRatio float64) {
+	c.foregroundRatio = &foregroundRatio
+}
+
+// SetRemesh sets the remesh mode.
+func (c *StableFast3d) SetRemesh(remesh string) {
+	c.remesh = &remesh
+}
+
+// Generate generates a model of the object in the image.
+func (c *StableFast3d) Generate() (*Response, error) {
+	request := &Request{
+		Image:             c.image,
+		TextureResolution: c.textureResolution,
+		ForegroundRatio:   c.foregroundRatio,
+		Remesh:            c.remesh,
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	form := c.stability.NewRequest(stability.METHOD_POST, PATH)
+	form.AddFormPart("image", request.Image)
+	addField(form, "texture_resolution", request.TextureResolution)
+	addField(form, "foreground_ratio", request.ForegroundRatio)
+	addField(form, "remesh", request.Remesh)
+
+	ctx := context.Background()
+	res, err := c.stability.DoContext(ctx, form)
+	if err != nil {
+		return nil, err
+	}
+	response, err := decode(res, form.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	c.log.Info("model generated", slog.Int("bytes", len(response.Model)))
+	c.stability.GetInstrumentation().Add(ctx, instrument.METRIC_CREDITS, CREDITS,
+		instrument.String(instrument.ATTR_STABILITY_IMAGE_MODEL, MODULE_NAME))
+	return response, nil
+}
+
+// Save writes the GLB to filename.
+func (r *Response) Save(filename string) error {
+	if len(r.Model) == 0 {
+		return &ErrMissingModel{}
+	}
+	mode := os.FileMode(0644)
+	return helper.SaveFile(&helper.SaveFileInput{
+		Filename: filename,
+		Data:     r.Model,
+		FileMode: &mode,
+	})
+}
+
+// Parse reports the meshes and textures of the GLB.
+func (r *Response) Parse() (*GLB, error) {
+	return ParseGLB(r.Model)
+}
+
+// decode reads a generated model. Error statuses are returned as ErrHTTP.
+func decode(res *stability.StabilityResponse, url string) (*Response, error) {
+	if res.StatusCode != http.StatusOK {
+		failure := &generate.ResponseErrors{}
+		json.Unmarshal(res.Body, failure)
+		return nil, &stability.ErrHTTP{
+			StatusCode: res.StatusCode,
+			Url:        url,
+			Err:        errors.New(strings.Join(failure.Errors, "; ")),
+		}
+	}
+	if contentType := res.ContentType(); contentType != CONTENT_TYPE_GLB && contentType != "application/octet-stream" {
+		return nil, &ErrUnexpectedContentType{ContentType: contentType}
+	}
+	if len(res.Body) == 0 {
+		return nil, &ErrMissingModel{}
+	}
+	return &Response{Model: res.Body}, nil
+}
+
+// addField appends a form field if it is set.
+func addField[T any](form *stability.Request, key string, field *T) {
+	if field != nil {
+		form.AddFormPart(key, *field)
+	}
+}
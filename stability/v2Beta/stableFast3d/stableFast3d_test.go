@@ -0,0 +1,82 @@
+package stableFast3d_test
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/stability/stabilitytest"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableFast3d"
+)
+
+var log = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func newStableFast3d(t *testing.T, opts ...func(*stableFast3d.StableFast3d)) (*stabilitytest.Server, *stableFast3d.StableFast3d) {
+	t.Helper()
+	server, err := stabilitytest.New()
+	if err != nil {
+		t.Fatalf("stabilitytest.New: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	s, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	opts = append([]func(*stableFast3d.StableFast3d){stableFast3d.WithStability(s), stableFast3d.WithLogger(log), stableFast3d.WithImageBytes(texture(t))}, opts...)
+	c, err := stableFast3d.New(opts...)
+	if err != nil {
+		t.Fatalf("stableFast3d.New: %v", err)
+	}
+	return server, c
+}
+
+func TestGenerate(t *testing.T) {
+	server, c := newStableFast3d(t, stableFast3d.WithTextureResolution(1024), stableFast3d.WithRemesh("quad"))
+	server.Enqueue(&stabilitytest.Reply{Model: model(t, MODEL_JSON)})
+
+	res, err := c.Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	parsed, err := res.Parse()
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(parsed.Meshes) != 2 || len(parsed.Textures) != 1 {
+		t.Errorf("Parse = %+v", parsed)
+	}
+
+	form := server.LastRequest().Form
+	if form["texture_resolution"][0] != "1024" || form["remesh"][0] != "quad" {
+		t.Errorf("form = %v", form)
+	}
+	if want := stabilitytest.DEFAULT_CREDITS - stableFast3d.CREDITS; server.Credits() != want {
+		t.Errorf("Credits = %g, want %g", server.Credits(), want)
+	}
+	if err := server.Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	server, c := newStableFast3d(t)
+	server.Enqueue(stabilitytest.Moderated())
+
+	var httpErr *stability.ErrHTTP
+	if _, err := c.Generate(); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusForbidden {
+		t.Errorf("Generate = %v, want ErrHTTP 403", err)
+	}
+
+	c.SetForegroundRatio(0.05)
+	var ratio *stableFast3d.ErrInvalidForegroundRatio
+	if _, err := c.Generate(); !errors.As(err, &ratio) {
+		t.Errorf("Generate = %v, want ErrInvalidForegroundRatio", err)
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("server received %d requests, want only the moderated one", n)
+	}
+}
@@ -0,0 +1,35 @@
+package stableFast3d
+
+import (
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/validate"
+)
+
+// Request holds the form fields of a Stable Fast 3D request. The tags are checked by
+// validate.Struct.
+type Request struct {
+	Image             *stability.FilePart `json:"image" required:"true"`
+	TextureResolution *int                `json:"texture_resolution" enum:"@stability.3d.texture_resolutions"`
+	ForegroundRatio   *float64            `json:"foreground_ratio" min:"0.1" max:"1"`
+	Remesh            *string             `json:"remesh" enum:"@stability.3d.remesh_modes"`
+}
+
+// Response is a generated model.
+type Response struct {
+	// Model is the binary glTF (GLB) file.
+	Model []byte
+}
+
+// fieldErrors maps request fields to this package's typed errors.
+var fieldErrors = validate.ErrorMap{
+	"image":              func(err error) error { return &ErrMissingImage{Err: err} },
+	"texture_resolution": func(err error) error { return &ErrInvalidTextureResolution{Err: err} },
+	"foreground_ratio":   func(err error) error { return &ErrInvalidForegroundRatio{Err: err} },
+	"remesh":             func(err error) error { return &ErrInvalidRemesh{Err: err} },
+}
+
+// Validate checks the request against its tags and maps every violation onto this
+// package's typed errors. All errors are returned joined.
+func (r *Request) Validate() error {
+	return validate.StructErrors(r, fieldErrors)
+}
@@ -0,0 +1,26 @@
+package control
+
+// Paths of the control endpoints
+const (
+	PATH_SKETCH    = "/v2beta/stable-image/control/sketch"
+	PATH_STRUCTURE = "/v2beta/stable-image/control/structure"
+	PATH_STYLE     = "/v2beta/stable-image/control/style"
+)
+
+// CREDITS is the price of a successful generation per operation.
+var CREDITS = map[string]float64{
+	"sketch":    3,
+	"structure": 3,
+	"style":     4,
+}
+
+// OUTPUT_FORMATS is a list of valid output formats for control endpoints
+var OUTPUT_FORMATS = []string{"jpeg", "png", "webp"}
+
+// MAX_PROMPT_LENGTH is the maximum length of a prompt
+const MAX_PROMPT_LENGTH = 10000
+
+// MAX_SEED is the maximum seed value
+const MAX_SEED = 4294967294
+
+const DEFAULT_OUTPUT_FORMAT = "png"
@@ -0,0 +1,307 @@
+package control
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate"
+	"github.com/rmrfslashbin/ami/validate"
+)
+
+//https://platform.stability.ai/docs/api-reference#tag/Control
+
+/*
+Method: POST
+Headers:
+- authorization: Bearer ${API_KEY}
+- content-type: multipart/form-data
+- accept: image/* -- OR -- application/json to receive image as base64 string
+
+Body: form-data, every operation requires a control image (file) and a prompt
+- sketch: control_strength ([0 .. 1], default 0.7), negative_prompt, seed, output_format
+- structure: control_strength ([0 .. 1], default 0.7), negative_prompt, seed, output_format
+- style: fidelity ([0 .. 1], default 0.5), negative_prompt, aspect_ratio, seed, output_format
+
+Credits: see CREDITS. You will not be charged for failed generations.
+*/
+
+// MODULE_NAME is the module name
+const MODULE_NAME = "control"
+
+// Option is a function that takes a pointer to a Config struct and sets a value.
+type Option func(config *Control)
+
+// Configuration structure. The same configuration serves every operation; each operation
+// sends only the fields it accepts.
+type Control struct {
+	log             *slog.Logger
+	stability       *stability.Stability
+	image           *stability.FilePart
+	prompt          *string
+	negativePrompt  *string
+	controlStrength *float64
+	fidelity        *float64
+	aspectRatio     *string
+	seed            *int
+	outputFormat    *string
+}
+
+func init() {
+	validate.RegisterEnum("stability.control.output_formats", OUTPUT_FORMATS)
+}
+
+// New creates a new Control instance.
+func New(opts ...func(*Control)) (*Control, error) {
+	config := &Control{}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.stability == nil {
+		return nil, &ErrMissingStability{}
+	}
+
+	if config.log == nil {
+		return nil, &ErrMissingLogger{}
+	}
+
+	return config, nil
+}
+
+// WithLogger sets the logger for the Control instance.
+func WithLogger(log *slog.Logger) Option {
+	return func(config *Control) {
+		moduleLogger := log.With(
+			slog.Group("module_info",
+				slog.String("module", MODULE_NAME),
+			),
+		)
+		config.log = moduleLogger
+	}
+}
+
+// WithStability sets the stability instance for the Control instance.
+func WithStability(stability *stability.Stability) Option {
+	return func(config *Control) {
+		config.stability = stability
+	}
+}
+
+// WithImage sets the control image, read from a path.
+func WithImage(path string) Option {
+	return func(config *Control) {
+		config.image = &stability.FilePart{Path: path}
+	}
+}
+
+// WithImageBytes sets the control image.
+func WithImageBytes(data []byte) Option {
+	return func(config *Control) {
+		config.image = &stability.FilePart{Data: data}
+	}
+}
+
+// WithImageReader sets the control image, streamed from r. r is read once, so the
+// instance can only generate once.
+func WithImageReader(r io.Reader) Option {
+	return func(config *Control) {
+		config.image = &stability.FilePart{Reader: r}
+	}
+}
+
+// WithPrompt sets the prompt for the Control instance.
+func WithPrompt(prompt string) Option {
+	return func(config *Control) {
+		config.prompt = &prompt
+	}
+}
+
+// WithNegativePrompt sets the negative prompt for the Control instance.
+func WithNegativePrompt(negativePrompt string) Option {
+	return func(config *Control) {
+		config.negativePrompt = &negativePrompt
+	}
+}
+
+// WithControlStrength sets how closely sketch and structure follow the control image,
+// from 0 to 1.
+func WithControlStrength(controlStrength float64) Option {
+	return func(config *Control) {
+		config.controlStrength = &controlStrength
+	}
+}
+
+// WithFidelity sets how closely style follows the style of the control image, from 0 to 1.
+func WithFidelity(fidelity float64) Option {
+	return func(config *Control) {
+		config.fidelity = &fidelity
+	}
+}
+
+// WithAspectRatio sets the aspect ratio of style generations.
+func WithAspectRatio(aspectRatio string) Option {
+	return func(config *Control) {
+		config.aspectRatio = &aspectRatio
+	}
+}
+
+// WithSeed sets the seed for the Control instance.
+func WithSeed(seed int) Option {
+	return func(config *Control) {
+		config.seed = &seed
+	}
+}
+
+// WithOutputFormat sets the output format for the Control instance.
+func WithOutputFormat(outputFormat string) Option {
+	return func(config *Control) {
+		config.outputFormat = &outputFormat
+	}
+}
+
+// SetImage sets the control image.
+func (c *Control) SetImage(image *stability.FilePart) {
+	c.image = image
+}
+
+// SetPrompt sets the prompt for the Control instance.
+func (c *Control) SetPrompt(prompt string) {
+	c.prompt = &prompt
+}
+
+// SetNegativePrompt sets the negative prompt for the Control instance.
+func (c *Control) SetNegativePrompt(negativePrompt string) {
+	c.negativePrompt = &negativePrompt
+}
+
+// SetControlStrength sets how closely sketch and structure follow the control image.
+func (c *Control) SetControlStrength(controlStrength float64) {
+	c.controlStrength = &controlStrength
+}
+
+// SetFidelity sets how closely style follows the style of the control image.
+func (c *Control) SetFidelity(fidelity float64) {
+	c.fidelity = &fidelity
+}
+
+// SetAspectRatio sets the aspect ratio of style generations.
+func (c *Control) SetAspectRatio(aspectRatio string) {
+	c.aspectRatio = &aspectRatio
+}
+
+// SetSeed sets the seed for the Control instance.
+func (c *Control) SetSeed(seed int) {
+	c.seed = &seed
+}
+
+// SetOutputFormat sets the output format for the Control instance.
+func (c *Control) SetOutputFormat(outputFormat string) {
+	c.outputFormat = &outputFormat
+}
+
+// Sketch turns a rough sketch into a refined image.
+func (c *Control) Sketch() (*generate.Response, error) {
+	return c.sketch(PATH_SKETCH, "sketch")
+}
+
+// Structure generates an image that keeps the structure of the control image.
+func (c *Control) Structure() (*generate.Response, error) {
+	return c.sketch(PATH_STRUCTURE, "structure")
+}
+
+// Style generates an image in the style of the control image.
+func (c *Control) Style() (*generate.Response, error) {
+	// validate prompt
+	if c.prompt == nil {
+		return nil, &ErrMissingPrompt{}
+	}
+
+	request := &StyleRequest{
+		Image:          c.image,
+		Prompt:         *c.prompt,
+		Fidelity:       c.fidelity,
+		NegativePrompt: c.negativePrompt,
+		AspectRatio:    c.aspectRatio,
+		Seed:           c.seed,
+		OutputFormat:   c.format(),
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	form := c.stability.NewRequest(stability.METHOD_POST, PATH_STYLE)
+	form.AddFormPart("image", request.Image)
+	form.AddFormPart("prompt", request.Prompt)
+	addField(form, "fidelity", request.Fidelity)
+	addField(form, "negative_prompt", request.NegativePrompt)
+	addField(form, "aspect_ratio", request.AspectRatio)
+	addField(form, "seed", request.Seed)
+	form.AddFormPart("output_format", request.OutputFormat)
+
+	return c.send(form, &generate.SendOptions{Model: "style", OutputFormat: request.OutputFormat, Seed: value(request.Seed)})
+}
+
+// sketch sends a sketch or structure request, which take the same fields.
+func (c *Control) sketch(path string, operation string) (*generate.Response, error) {
+	// validate prompt
+	if c.prompt == nil {
+		return nil, &ErrMissingPrompt{}
+	}
+
+	request := &SketchRequest{
+		Image:           c.image,
+		Prompt:          *c.prompt,
+		ControlStrength: c.controlStrength,
+		NegativePrompt:  c.negativePrompt,
+		Seed:            c.seed,
+		OutputFormat:    c.format(),
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	form := c.stability.NewRequest(stability.METHOD_POST, path)
+	form.AddFormPart("image", request.Image)
+	form.AddFormPart("prompt", request.Prompt)
+	addField(form, "control_strength", request.ControlStrength)
+	addField(form, "negative_prompt", request.NegativePrompt)
+	addField(form, "seed", request.Seed)
+	form.AddFormPart("output_format", request.OutputFormat)
+
+	return c.send(form, &generate.SendOptions{Model: operation, OutputFormat: request.OutputFormat, Seed: value(request.Seed)})
+}
+
+// send executes a control request, decoding the response like a generation.
+// options.Model is the operation, which sets the credits.
+func (c *Control) send(form *stability.Request, options *generate.SendOptions) (*generate.Response, error) {
+	form.AddHeader("accept", "application/json")
+	options.Credits = CREDITS[options.Model]
+	return generate.Send(c.stability, c.log, form, options)
+}
+
+// format returns the output format, or the default.
+func (c *Control) format() string {
+	if c.outputFormat == nil {
+		return DEFAULT_OUTPUT_FORMAT
+	}
+	return *c.outputFormat
+}
+
+// addField appends a form field if it is set.
+func addField[T any](form *stability.Request, key string, field *T) {
+	if field != nil {
+		form.AddFormPart(key, *field)
+	}
+}
+
+// value dereferences an optional field, returning the zero value if it is unset.
+func value[T any](field *T) T {
+	var zero T
+	if field == nil {
+		return zero
+	}
+	return *field
+}
@@ -0,0 +1,113 @@
+package control_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/rmrfslashbin/ami/stability/stabilitytest"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/control"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate"
+)
+
+var log = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// pngImage returns a small PNG.
+func pngImage(t *testing.T) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("encoding PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func newControl(t *testing.T, opts ...func(*control.Control)) (*stabilitytest.Server, *control.Control) {
+	t.Helper()
+	server, err := stabilitytest.New()
+	if err != nil {
+		t.Fatalf("stabilitytest.New: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	s, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	opts = append([]func(*control.Control){control.WithStability(s), control.WithLogger(log), control.WithImageBytes(pngImage(t))}, opts...)
+	c, err := control.New(opts...)
+	if err != nil {
+		t.Fatalf("control.New: %v", err)
+	}
+	return server, c
+}
+
+func TestOperations(t *testing.T) {
+	tests := []struct {
+		operation string
+		path      string
+		opts      []func(*control.Control)
+		run       func(*control.Control) (*generate.Response, error)
+		field     string
+	}{
+		{operation: "sketch", path: control.PATH_SKETCH, opts: []func(*control.Control){control.WithControlStrength(0.7)},
+			run: (*control.Control).Sketch, field: "control_strength"},
+		{operation: "structure", path: control.PATH_STRUCTURE, opts: []func(*control.Control){control.WithControlStrength(0.7)},
+			run: (*control.Control).Structure, field: "control_strength"},
+		{operation: "style", path: control.PATH_STYLE, opts: []func(*control.Control){control.WithFidelity(0.7), control.WithAspectRatio("16:9")},
+			run: (*control.Control).Style, field: "fidelity"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.operation, func(t *testing.T) {
+			server, c := newControl(t, append([]func(*control.Control){control.WithPrompt("a castle")}, tt.opts...)...)
+
+			res, err := tt.run(c)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.operation, err)
+			}
+			if res.Image == nil || *res.Image == "" {
+				t.Errorf("%s = %+v, want an image", tt.operation, res)
+			}
+			received := server.LastRequest()
+			if received.Path != tt.path || len(received.Form[tt.field]) != 1 || received.Form[tt.field][0] != "0.7" {
+				t.Errorf("received %s %v", received.Path, received.Form)
+			}
+			if want := stabilitytest.DEFAULT_CREDITS - control.CREDITS[tt.operation]; server.Credits() != want {
+				t.Errorf("Credits = %g, want %g", server.Credits(), want)
+			}
+			if err := server.Verify(); err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	server, c := newControl(t)
+
+	var prompt *control.ErrMissingPrompt
+	if _, err := c.Sketch(); !errors.As(err, &prompt) {
+		t.Errorf("Sketch without prompt = %v, want ErrMissingPrompt", err)
+	}
+
+	c.SetPrompt("a castle")
+	c.SetControlStrength(1.5)
+	var strength *control.ErrInvalidControlStrength
+	if _, err := c.Structure(); !errors.As(err, &strength) {
+		t.Errorf("Structure = %v, want ErrInvalidControlStrength", err)
+	}
+
+	c.SetAspectRatio("2:1")
+	var aspectRatio *control.ErrInvalidAspectRatio
+	if _, err := c.Style(); !errors.As(err, &aspectRatio) {
+		t.Errorf("Style = %v, want ErrInvalidAspectRatio", err)
+	}
+	if n := len(server.Requests()); n != 0 {
+		t.Errorf("%d requests sent for invalid generations", n)
+	}
+}
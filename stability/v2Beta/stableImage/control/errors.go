@@ -0,0 +1,186 @@
+package control
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate"
+)
+
+type ErrMissingStability struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingStability) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing stability- use WithStability to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrMissingLogger struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingLogger) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing logger- use WithLogger to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrMissingPrompt struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingPrompt) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing prompt- use WithPrompt or SetPrompt to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrMissingImage struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingImage) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing control image- use WithImage or SetImage to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidPromptLength struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidPromptLength) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid prompt- use WithPrompt or SetPrompt to set it. Must be between 1 and %d characters", MAX_PROMPT_LENGTH)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidNegativePromptLength struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidNegativePromptLength) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid negative prompt- use WithNegativePrompt or SetNegativePrompt to set it. If set, the prompt must be between 1 and %d characters", MAX_PROMPT_LENGTH)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidControlStrength struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidControlStrength) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid control strength- use WithControlStrength or SetControlStrength to set it. Must be between 0 and 1"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidFidelity struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidFidelity) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid fidelity- use WithFidelity or SetFidelity to set it. Must be between 0 and 1"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidAspectRatio struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidAspectRatio) Error() string {
+	validAspectRatios := strings.Join(generate.ASPECT_RATIOS, ", ")
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid aspect ratio- use WithAspectRatio or SetAspectRatio to set it. Must be one of " + validAspectRatios
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidSeed struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidSeed) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid seed- use WithSeed or SetSeed to set it. Must be between 0 and %d", MAX_SEED)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidOutputFormat struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidOutputFormat) Error() string {
+	validOutputFormats := strings.Join(OUTPUT_FORMATS, ", ")
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid output format- use WithOutputFormat or SetOutputFormat to set it. Must be one of " + validOutputFormats
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
@@ -0,0 +1,52 @@
+package control
+
+import (
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/validate"
+)
+
+// SketchRequest holds the form fields of a sketch or structure request. The tags are
+// checked by validate.Struct.
+type SketchRequest struct {
+	Image           *stability.FilePart `json:"image" required:"true"`
+	Prompt          string              `json:"prompt" required:"true" min:"1" max:"10000"`
+	ControlStrength *float64            `json:"control_strength" min:"0" max:"1"`
+	NegativePrompt  *string             `json:"negative_prompt" min:"1" max:"10000"`
+	Seed            *int                `json:"seed" min:"0" max:"4294967294"`
+	OutputFormat    string              `json:"output_format" enum:"@stability.control.output_formats"`
+}
+
+// StyleRequest holds the form fields of a style request.
+type StyleRequest struct {
+	Image          *stability.FilePart `json:"image" required:"true"`
+	Prompt         string              `json:"prompt" required:"true" min:"1" max:"10000"`
+	Fidelity       *float64            `json:"fidelity" min:"0" max:"1"`
+	NegativePrompt *string             `json:"negative_prompt" min:"1" max:"10000"`
+	AspectRatio    *string             `json:"aspect_ratio" enum:"@stability.aspect_ratios"`
+	Seed           *int                `json:"seed" min:"0" max:"4294967294"`
+	OutputFormat   string              `json:"output_format" enum:"@stability.control.output_formats"`
+}
+
+// Validate checks the request against its tags and maps every violation onto this
+// package's typed errors.
+func (r *SketchRequest) Validate() error {
+	return validate.StructErrors(r, fieldErrors)
+}
+
+// Validate checks the request against its tags and maps every violation onto this
+// package's typed errors.
+func (r *StyleRequest) Validate() error {
+	return validate.StructErrors(r, fieldErrors)
+}
+
+// fieldErrors maps request fields to this package's typed errors.
+var fieldErrors = validate.ErrorMap{
+	"image":            func(err error) error { return &ErrMissingImage{Err: err} },
+	"prompt":           func(err error) error { return &ErrInvalidPromptLength{Err: err} },
+	"negative_prompt":  func(err error) error { return &ErrInvalidNegativePromptLength{Err: err} },
+	"control_strength": func(err error) error { return &ErrInvalidControlStrength{Err: err} },
+	"fidelity":         func(err error) error { return &ErrInvalidFidelity{Err: err} },
+	"aspect_ratio":     func(err error) error { return &ErrInvalidAspectRatio{Err: err} },
+	"seed":             func(err error) error { return &ErrInvalidSeed{Err: err} },
+	"output_format":    func(err error) error { return &ErrInvalidOutputFormat{Err: err} },
+}
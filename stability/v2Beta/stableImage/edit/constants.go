@@ -0,0 +1,47 @@
+package edit
+
+import "github.com/rmrfslashbin/ami/stability"
+
+// Paths of the edit endpoints
+const (
+	PATH_INPAINT            = "/v2beta/stable-image/edit/inpaint"
+	PATH_OUTPAINT           = "/v2beta/stable-image/edit/outpaint"
+	PATH_ERASE              = "/v2beta/stable-image/edit/erase"
+	PATH_SEARCH_AND_REPLACE = "/v2beta/stable-image/edit/search-and-replace"
+	PATH_SEARCH_AND_RECOLOR = "/v2beta/stable-image/edit/search-and-recolor"
+	PATH_REMOVE_BACKGROUND  = "/v2beta/stable-image/edit/remove-background"
+)
+
+// ENDPOINT_ROOT is the root of the edit endpoints
+var ENDPOINT_ROOT = stability.ENDPOINT_ROOT + "/v2beta/stable-image/edit"
+
+// CREDITS is the price of a successful edit per operation.
+var CREDITS = map[string]float64{
+	"inpaint":            3,
+	"outpaint":           4,
+	"erase":              3,
+	"search-and-replace": 4,
+	"search-and-recolor": 5,
+	"remove-background":  2,
+}
+
+// OUTPUT_FORMATS is a list of valid output formats for edit endpoints
+var OUTPUT_FORMATS = []string{"jpeg", "png", "webp"}
+
+// REMOVE_BACKGROUND_OUTPUT_FORMATS is a list of valid output formats for remove-background;
+// JPEG has no transparency.
+var REMOVE_BACKGROUND_OUTPUT_FORMATS = []string{"png", "webp"}
+
+// MAX_PROMPT_LENGTH is the maximum length of a prompt
+const MAX_PROMPT_LENGTH = 10000
+
+// MAX_GROW_MASK is the maximum number of pixels a mask is grown by
+const MAX_GROW_MASK = 100
+
+// MAX_OUTPAINT is the maximum number of pixels to outpaint in each direction
+const MAX_OUTPAINT = 2000
+
+// MAX_SEED is the maximum seed value
+const MAX_SEED = 4294967294
+
+const DEFAULT_OUTPUT_FORMAT = "png"
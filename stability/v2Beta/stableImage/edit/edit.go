@@ -0,0 +1,448 @@
+package edit
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate"
+	"github.com/rmrfslashbin/ami/validate"
+)
+
+//https://platform.stability.ai/docs/api-reference#tag/Edit
+
+/*
+Method: POST
+Headers:
+- authorization: Bearer ${API_KEY}
+- content-type: multipart/form-data
+- accept: image/* -- OR -- application/json to receive image as base64 string
+
+Body: form-data, every operation requires an image (file)
+- inpaint: prompt, mask, negative_prompt, grow_mask, seed, output_format
+- outpaint: left, right, up, down ([0 .. 2000] pixels, at least one), prompt, creativity ([0 .. 1]), seed, output_format
+- erase: mask, grow_mask, seed, output_format
+- search-and-replace: prompt, search_prompt, negative_prompt, grow_mask, seed, output_format
+- search-and-recolor: prompt, select_prompt, negative_prompt, grow_mask, seed, output_format
+- remove-background: output_format (png or webp)
+
+Without a mask, inpaint and erase use the alpha channel of the image.
+
+Credits: see CREDITS. You will not be charged for failed edits.
+*/
+
+// MODULE_NAME is the module name
+const MODULE_NAME = "edit"
+
+// Option is a function that takes a pointer to a Config struct and sets a value.
+type Option func(config *Edit)
+
+// Configuration structure. The same configuration serves every operation; each operation
+// sends only the fields it accepts.
+type Edit struct {
+	log            *slog.Logger
+	stability      *stability.Stability
+	image          *stability.FilePart
+	mask           *stability.FilePart
+	prompt         *string
+	negativePrompt *string
+	searchPrompt   *string
+	selectPrompt   *string
+	growMask       *int
+	left           *int
+	right          *int
+	up             *int
+	down           *int
+	creativity     *float64
+	seed           *int
+	outputFormat   *string
+}
+
+func init() {
+	validate.RegisterEnum("stability.edit.output_formats", OUTPUT_FORMATS)
+	validate.RegisterEnum("stability.edit.background_formats", REMOVE_BACKGROUND_OUTPUT_FORMATS)
+}
+
+// New creates a new Edit instance.
+func New(opts ...func(*Edit)) (*Edit, error) {
+	config := &Edit{}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.stability == nil {
+		return nil, &ErrMissingStability{}
+	}
+
+	if config.log == nil {
+		return nil, &ErrMissingLogger{}
+	}
+
+	return config, nil
+}
+
+// WithLogger sets the logger for the Edit instance.
+func WithLogger(log *slog.Logger) Option {
+	return func(config *Edit) {
+		moduleLogger := log.With(
+			slog.Group("module_info",
+				slog.String("module", MODULE_NAME),
+			),
+		)
+		config.log = moduleLogger
+	}
+}
+
+// WithStability sets the stability instance for the Edit instance.
+func WithStability(stability *stability.Stability) Option {
+	return func(config *Edit) {
+		config.stability = stability
+	}
+}
+
+// WithImage sets the image to edit, read from a path.
+func WithImage(path string) Option {
+	return func(config *Edit) {
+		config.image = &stability.FilePart{Path: path}
+	}
+}
+
+// WithImageBytes sets the image to edit.
+func WithImageBytes(data []byte) Option {
+	return func(config *Edit) {
+		config.image = &stability.FilePart{Data: data}
+	}
+}
+
+// WithImageReader sets the image to edit, streamed from r. r is read once, so the
+// instance can only edit once.
+func WithImageReader(r io.Reader) Option {
+	return func(config *Edit) {
+		config.image = &stability.FilePart{Reader: r}
+	}
+}
+
+// WithMask sets the inpaint or erase mask, read from a path. White areas are edited.
+func WithMask(path string) Option {
+	return func(config *Edit) {
+		config.mask = &stability.FilePart{Path: path}
+	}
+}
+
+// WithMaskBytes sets the inpaint or erase mask.
+func WithMaskBytes(data []byte) Option {
+	return func(config *Edit) {
+		config.mask = &stability.FilePart{Data: data}
+	}
+}
+
+// WithPrompt sets the prompt for the Edit instance.
+func WithPrompt(prompt string) Option {
+	return func(config *Edit) {
+		config.prompt = &prompt
+	}
+}
+
+// WithNegativePrompt sets the negative prompt for the Edit instance.
+func WithNegativePrompt(negativePrompt string) Option {
+	return func(config *Edit) {
+		config.negativePrompt = &negativePrompt
+	}
+}
+
+// WithSearchPrompt sets what search-and-replace replaces.
+func WithSearchPrompt(searchPrompt string) Option {
+	return func(config *Edit) {
+		config.searchPrompt = &searchPrompt
+	}
+}
+
+// WithSelectPrompt sets what search-and-recolor recolors.
+func WithSelectPrompt(selectPrompt string) Option {
+	return func(config *Edit) {
+		config.selectPrompt = &selectPrompt
+	}
+}
+
+// WithGrowMask grows the edges of the mask by up to MAX_GROW_MASK pixels.
+func WithGrowMask(pixels int) Option {
+	return func(config *Edit) {
+		config.growMask = &pixels
+	}
+}
+
+// WithOutpaint sets how many pixels outpaint adds in each direction.
+func WithOutpaint(left int, right int, up int, down int) Option {
+	return func(config *Edit) {
+		config.SetOutpaint(left, right, up, down)
+	}
+}
+
+// WithCreativity sets how creative outpaint is, from 0 to 1.
+func WithCreativity(creativity float64) Option {
+	return func(config *Edit) {
+		config.creativity = &creativity
+	}
+}
+
+// WithSeed sets the seed for the Edit instance.
+func WithSeed(seed int) Option {
+	return func(config *Edit) {
+		config.seed = &seed
+	}
+}
+
+// WithOutputFormat sets the output format for the Edit instance.
+func WithOutputFormat(outputFormat string) Option {
+	return func(config *Edit) {
+		config.outputFormat = &outputFormat
+	}
+}
+
+// SetImage sets the image to edit.
+func (c *Edit) SetImage(image *stability.FilePart) {
+	c.image = image
+}
+
+// SetMask sets the inpaint or erase mask. A nil mask uses the alpha channel of the image.
+func (c *Edit) SetMask(mask *stability.FilePart) {
+	c.mask = mask
+}
+
+// SetPrompt sets the prompt for the Edit instance.
+func (c *Edit) SetPrompt(prompt string) {
+	c.prompt = &prompt
+}
+
+// SetNegativePrompt sets the negative prompt for the Edit instance.
+func (c *Edit) SetNegativePrompt(negativePrompt string) {
+	c.negativePrompt = &negativePrompt
+}
+
+// SetSearchPrompt sets what search-and-replace replaces.
+func (c *Edit) SetSearchPrompt(searchPrompt string) {
+	c.searchPrompt = &searchPrompt
+}
+
+// SetSelectPrompt sets what search-and-recolor recolors.
+func (c *Edit) SetSelectPrompt(selectPrompt string) {
+	c.selectPrompt = &selectPrompt
+}
+
+// SetGrowMask sets how many pixels the mask is grown by.
+func (c *Edit) SetGrowMask(pixels int) {
+	c.growMask = &pixels
+}
+
+// SetOutpaint sets how many pixels outpaint adds in each direction.
+func (c *Edit) SetOutpaint(left int, right int, up int, down int) {
+	c.left, c.right, c.up, c.down = &left, &right, &up, &down
+}
+
+// SetCreativity sets how creative outpaint is.
+func (c *Edit) SetCreativity(creativity float64) {
+	c.creativity = &creativity
+}
+
+// SetSeed sets the seed for the Edit instance.
+func (c *Edit) SetSeed(seed int) {
+	c.seed = &seed
+}
+
+// SetOutputFormat sets the output format for the Edit instance.
+func (c *Edit) SetOutputFormat(outputFormat string) {
+	c.outputFormat = &outputFormat
+}
+
+// Inpaint fills the masked area of the image from the prompt.
+func (c *Edit) Inpaint() (*generate.Response, error) {
+	request := &InpaintRequest{
+		Image:          c.image,
+		Mask:           c.mask,
+		Prompt:         value(c.prompt),
+		NegativePrompt: c.negativePrompt,
+		GrowMask:       c.growMask,
+		Seed:           c.seed,
+		OutputFormat:   c.format(),
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	form := c.stability.NewRequest(stability.METHOD_POST, PATH_INPAINT)
+	form.AddFormPart("image", request.Image)
+	addFile(form, "mask", request.Mask)
+	form.AddFormPart("prompt", request.Prompt)
+	addField(form, "negative_prompt", request.NegativePrompt)
+	addField(form, "grow_mask", request.GrowMask)
+	addField(form, "seed", request.Seed)
+	form.AddFormPart("output_format", request.OutputFormat)
+
+	return c.send(form, &generate.SendOptions{Model: "inpaint", OutputFormat: request.OutputFormat, Seed: value(request.Seed)})
+}
+
+// Outpaint extends the image in any direction.
+func (c *Edit) Outpaint() (*generate.Response, error) {
+	request := &OutpaintRequest{
+		Image:        c.image,
+		Left:         c.left,
+		Right:        c.right,
+		Up:           c.up,
+		Down:         c.down,
+		Prompt:       c.prompt,
+		Creativity:   c.creativity,
+		Seed:         c.seed,
+		OutputFormat: c.format(),
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	form := c.stability.NewRequest(stability.METHOD_POST, PATH_OUTPAINT)
+	form.AddFormPart("image", request.Image)
+	addField(form, "left", request.Left)
+	addField(form, "right", request.Right)
+	addField(form, "up", request.Up)
+	addField(form, "down", request.Down)
+	addField(form, "prompt", request.Prompt)
+	addField(form, "creativity", request.Creativity)
+	addField(form, "seed", request.Seed)
+	form.AddFormPart("output_format", request.OutputFormat)
+
+	return c.send(form, &generate.SendOptions{Model: "outpaint", OutputFormat: request.OutputFormat, Seed: value(request.Seed)})
+}
+
+// Erase removes the masked objects from the image.
+func (c *Edit) Erase() (*generate.Response, error) {
+	request := &EraseRequest{
+		Image:        c.image,
+		Mask:         c.mask,
+		GrowMask:     c.growMask,
+		Seed:         c.seed,
+		OutputFormat: c.format(),
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	form := c.stability.NewRequest(stability.METHOD_POST, PATH_ERASE)
+	form.AddFormPart("image", request.Image)
+	addFile(form, "mask", request.Mask)
+	addField(form, "grow_mask", request.GrowMask)
+	addField(form, "seed", request.Seed)
+	form.AddFormPart("output_format", request.OutputFormat)
+
+	return c.send(form, &generate.SendOptions{Model: "erase", OutputFormat: request.OutputFormat, Seed: value(request.Seed)})
+}
+
+// SearchAndReplace replaces the objects matching the search prompt with the prompt.
+func (c *Edit) SearchAndReplace() (*generate.Response, error) {
+	request := &SearchAndReplaceRequest{
+		Image:          c.image,
+		Prompt:         value(c.prompt),
+		SearchPrompt:   value(c.searchPrompt),
+		NegativePrompt: c.negativePrompt,
+		GrowMask:       c.growMask,
+		Seed:           c.seed,
+		OutputFormat:   c.format(),
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	form := c.stability.NewRequest(stability.METHOD_POST, PATH_SEARCH_AND_REPLACE)
+	form.AddFormPart("image", request.Image)
+	form.AddFormPart("prompt", request.Prompt)
+	form.AddFormPart("search_prompt", request.SearchPrompt)
+	addField(form, "negative_prompt", request.NegativePrompt)
+	addField(form, "grow_mask", request.GrowMask)
+	addField(form, "seed", request.Seed)
+	form.AddFormPart("output_format", request.OutputFormat)
+
+	return c.send(form, &generate.SendOptions{Model: "search-and-replace", OutputFormat: request.OutputFormat, Seed: value(request.Seed)})
+}
+
+// SearchAndRecolor recolors the objects matching the select prompt as the prompt describes.
+func (c *Edit) SearchAndRecolor() (*generate.Response, error) {
+	request := &SearchAndRecolorRequest{
+		Image:          c.image,
+		Prompt:         value(c.prompt),
+		SelectPrompt:   value(c.selectPrompt),
+		NegativePrompt: c.negativePrompt,
+		GrowMask:       c.growMask,
+		Seed:           c.seed,
+		OutputFormat:   c.format(),
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	form := c.stability.NewRequest(stability.METHOD_POST, PATH_SEARCH_AND_RECOLOR)
+	form.AddFormPart("image", request.Image)
+	form.AddFormPart("prompt", request.Prompt)
+	form.AddFormPart("select_prompt", request.SelectPrompt)
+	addField(form, "negative_prompt", request.NegativePrompt)
+	addField(form, "grow_mask", request.GrowMask)
+	addField(form, "seed", request.Seed)
+	form.AddFormPart("output_format", request.OutputFormat)
+
+	return c.send(form, &generate.SendOptions{Model: "search-and-recolor", OutputFormat: request.OutputFormat, Seed: value(request.Seed)})
+}
+
+// RemoveBackground segments the foreground and removes the background.
+func (c *Edit) RemoveBackground() (*generate.Response, error) {
+	request := &RemoveBackgroundRequest{
+		Image:        c.image,
+		OutputFormat: c.format(),
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	form := c.stability.NewRequest(stability.METHOD_POST, PATH_REMOVE_BACKGROUND)
+	form.AddFormPart("image", request.Image)
+	form.AddFormPart("output_format", request.OutputFormat)
+
+	return c.send(form, &generate.SendOptions{Model: "remove-background", OutputFormat: request.OutputFormat})
+}
+
+// send executes an edit, decoding the response like a generation. options.Model is the
+// operation, which sets the credits.
+func (c *Edit) send(form *stability.Request, options *generate.SendOptions) (*generate.Response, error) {
+	form.AddHeader("accept", "application/json")
+	options.Credits = CREDITS[options.Model]
+	return generate.Send(c.stability, c.log, form, options)
+}
+
+// format returns the output format, or the default.
+func (c *Edit) format() string {
+	if c.outputFormat == nil {
+		return DEFAULT_OUTPUT_FORMAT
+	}
+	return *c.outputFormat
+}
+
+// addField appends a form field if it is set.
+func addField[T any](form *stability.Request, key string, field *T) {
+	if field != nil {
+		form.AddFormPart(key, *field)
+	}
+}
+
+// addFile appends a file part if it is set.
+func addFile(form *stability.Request, key string, file *stability.FilePart) {
+	if file != nil {
+		form.AddFormPart(key, file)
+	}
+}
+
+// value dereferences an optional field, returning the zero value if it is unset.
+func value[T any](field *T) T {
+	var zero T
+	if field == nil {
+		return zero
+	}
+	return *field
+}
@@ -0,0 +1,130 @@
+package edit_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/rmrfslashbin/ami/stability/stabilitytest"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/edit"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate"
+)
+
+var log = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// pngImage returns a small PNG.
+func pngImage(t *testing.T) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("encoding PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func newEdit(t *testing.T, opts ...func(*edit.Edit)) (*stabilitytest.Server, *edit.Edit) {
+	t.Helper()
+	server, err := stabilitytest.New()
+	if err != nil {
+		t.Fatalf("stabilitytest.New: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	s, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	opts = append([]func(*edit.Edit){edit.WithStability(s), edit.WithLogger(log), edit.WithImageBytes(pngImage(t))}, opts...)
+	e, err := edit.New(opts...)
+	if err != nil {
+		t.Fatalf("edit.New: %v", err)
+	}
+	return server, e
+}
+
+func TestOperations(t *testing.T) {
+	tests := []struct {
+		operation string
+		path      string
+		opts      []func(*edit.Edit)
+		run       func(*edit.Edit) (*generate.Response, error)
+	}{
+		{operation: "inpaint", path: edit.PATH_INPAINT,
+			opts: []func(*edit.Edit){edit.WithPrompt("a hat"), edit.WithMaskBytes(pngImage(t)), edit.WithGrowMask(10)},
+			run:  (*edit.Edit).Inpaint},
+		{operation: "outpaint", path: edit.PATH_OUTPAINT,
+			opts: []func(*edit.Edit){edit.WithOutpaint(10, 0, 20, 0), edit.WithCreativity(0.5)},
+			run:  (*edit.Edit).Outpaint},
+		{operation: "erase", path: edit.PATH_ERASE,
+			opts: []func(*edit.Edit){edit.WithMaskBytes(pngImage(t))},
+			run:  (*edit.Edit).Erase},
+		{operation: "search-and-replace", path: edit.PATH_SEARCH_AND_REPLACE,
+			opts: []func(*edit.Edit){edit.WithPrompt("a dog"), edit.WithSearchPrompt("cat"), edit.WithSeed(3)},
+			run:  (*edit.Edit).SearchAndReplace},
+		{operation: "search-and-recolor", path: edit.PATH_SEARCH_AND_RECOLOR,
+			opts: []func(*edit.Edit){edit.WithPrompt("red"), edit.WithSelectPrompt("hat")},
+			run:  (*edit.Edit).SearchAndRecolor},
+		{operation: "remove-background", path: edit.PATH_REMOVE_BACKGROUND,
+			opts: []func(*edit.Edit){edit.WithOutputFormat("webp")},
+			run:  (*edit.Edit).RemoveBackground},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.operation, func(t *testing.T) {
+			server, e := newEdit(t, tt.opts...)
+
+			res, err := tt.run(e)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.operation, err)
+			}
+			if res.Image == nil || *res.Image == "" {
+				t.Errorf("%s = %+v, want an image", tt.operation, res)
+			}
+			if received := server.LastRequest(); received.Path != tt.path || len(received.Files["image"]) != 1 {
+				t.Errorf("received %s with files %v", received.Path, received.Files)
+			}
+			if want := stabilitytest.DEFAULT_CREDITS - edit.CREDITS[tt.operation]; server.Credits() != want {
+				t.Errorf("Credits = %g, want %g", server.Credits(), want)
+			}
+			if err := server.Verify(); err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestOperationsInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []func(*edit.Edit)
+		run   func(*edit.Edit) (*generate.Response, error)
+		check func(error) bool
+	}{
+		{name: "inpaint without prompt", run: (*edit.Edit).Inpaint,
+			check: func(err error) bool { var e *edit.ErrInvalidPromptLength; return errors.As(err, &e) }},
+		{name: "outpaint without direction", run: (*edit.Edit).Outpaint,
+			check: func(err error) bool { var e *edit.ErrInvalidOutpaint; return errors.As(err, &e) }},
+		{name: "grow mask out of range", opts: []func(*edit.Edit){edit.WithGrowMask(101)}, run: (*edit.Edit).Erase,
+			check: func(err error) bool { var e *edit.ErrInvalidGrowMask; return errors.As(err, &e) }},
+		{name: "search-and-replace without search prompt", opts: []func(*edit.Edit){edit.WithPrompt("a dog")}, run: (*edit.Edit).SearchAndReplace,
+			check: func(err error) bool { var e *edit.ErrInvalidSearchPrompt; return errors.As(err, &e) }},
+		{name: "remove-background to jpeg", opts: []func(*edit.Edit){edit.WithOutputFormat("jpeg")}, run: (*edit.Edit).RemoveBackground,
+			check: func(err error) bool { var e *edit.ErrInvalidOutputFormat; return errors.As(err, &e) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, e := newEdit(t, tt.opts...)
+			if _, err := tt.run(e); !tt.check(err) {
+				t.Errorf("error = %v", err)
+			}
+			if n := len(server.Requests()); n != 0 {
+				t.Errorf("%d requests sent for an invalid edit", n)
+			}
+		})
+	}
+}
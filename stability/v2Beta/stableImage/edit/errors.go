@@ -0,0 +1,199 @@
+package edit
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ErrMissingStability struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingStability) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing stability- use WithStability to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrMissingLogger struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingLogger) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing logger- use WithLogger to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrMissingImage struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingImage) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing image- use WithImage or SetImage to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidPromptLength struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidPromptLength) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid prompt- use WithPrompt or SetPrompt to set it. Must be between 1 and %d characters", MAX_PROMPT_LENGTH)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidNegativePromptLength struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidNegativePromptLength) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid negative prompt- use WithNegativePrompt or SetNegativePrompt to set it. If set, the prompt must be between 1 and %d characters", MAX_PROMPT_LENGTH)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidSearchPrompt struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidSearchPrompt) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid search prompt- use WithSearchPrompt or SetSearchPrompt to set it. Must be between 1 and %d characters", MAX_PROMPT_LENGTH)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidSelectPrompt struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidSelectPrompt) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid select prompt- use WithSelectPrompt or SetSelectPrompt to set it. Must be between 1 and %d characters", MAX_PROMPT_LENGTH)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidGrowMask struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidGrowMask) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid grow mask- use WithGrowMask or SetGrowMask to set it. Must be between 0 and %d", MAX_GROW_MASK)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidOutpaint struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidOutpaint) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid outpaint- use WithOutpaint or SetOutpaint to set it. Each direction must be between 0 and %d and at least one must be set", MAX_OUTPAINT)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidCreativity struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidCreativity) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid creativity- use WithCreativity or SetCreativity to set it. Must be between 0 and 1"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidSeed struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidSeed) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid seed- use WithSeed or SetSeed to set it. Must be between 0 and %d", MAX_SEED)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidOutputFormat struct {
+	Err     error
+	Msg     string
+	Formats []string
+}
+
+func (e *ErrInvalidOutputFormat) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid output format- use WithOutputFormat or SetOutputFormat to set it. Must be one of " + strings.Join(e.Formats, ", ")
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
@@ -0,0 +1,138 @@
+package edit
+
+import (
+	"errors"
+
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/validate"
+)
+
+// InpaintRequest holds the form fields of an inpaint request. The tags are checked by
+// validate.Struct.
+type InpaintRequest struct {
+	Image          *stability.FilePart `json:"image" required:"true"`
+	Mask           *stability.FilePart `json:"mask"`
+	Prompt         string              `json:"prompt" required:"true" min:"1" max:"10000"`
+	NegativePrompt *string             `json:"negative_prompt" min:"1" max:"10000"`
+	GrowMask       *int                `json:"grow_mask" min:"0" max:"100"`
+	Seed           *int                `json:"seed" min:"0" max:"4294967294"`
+	OutputFormat   string              `json:"output_format" enum:"@stability.edit.output_formats"`
+}
+
+// OutpaintRequest holds the form fields of an outpaint request.
+type OutpaintRequest struct {
+	Image        *stability.FilePart `json:"image" required:"true"`
+	Left         *int                `json:"left" min:"0" max:"2000"`
+	Right        *int                `json:"right" min:"0" max:"2000"`
+	Up           *int                `json:"up" min:"0" max:"2000"`
+	Down         *int                `json:"down" min:"0" max:"2000"`
+	Prompt       *string             `json:"prompt" min:"1" max:"10000"`
+	Creativity   *float64            `json:"creativity" min:"0" max:"1"`
+	Seed         *int                `json:"seed" min:"0" max:"4294967294"`
+	OutputFormat string              `json:"output_format" enum:"@stability.edit.output_formats"`
+}
+
+// EraseRequest holds the form fields of an erase request.
+type EraseRequest struct {
+	Image        *stability.FilePart `json:"image" required:"true"`
+	Mask         *stability.FilePart `json:"mask"`
+	GrowMask     *int                `json:"grow_mask" min:"0" max:"100"`
+	Seed         *int                `json:"seed" min:"0" max:"4294967294"`
+	OutputFormat string              `json:"output_format" enum:"@stability.edit.output_formats"`
+}
+
+// SearchAndReplaceRequest holds the form fields of a search-and-replace request.
+type SearchAndReplaceRequest struct {
+	Image          *stability.FilePart `json:"image" required:"true"`
+	Prompt         string              `json:"prompt" required:"true" min:"1" max:"10000"`
+	SearchPrompt   string              `json:"search_prompt" required:"true" min:"1" max:"10000"`
+	NegativePrompt *string             `json:"negative_prompt" min:"1" max:"10000"`
+	GrowMask       *int                `json:"grow_mask" min:"0" max:"100"`
+	Seed           *int                `json:"seed" min:"0" max:"4294967294"`
+	OutputFormat   string              `json:"output_format" enum:"@stability.edit.output_formats"`
+}
+
+// SearchAndRecolorRequest holds the form fields of a search-and-recolor request.
+type SearchAndRecolorRequest struct {
+	Image          *stability.FilePart `json:"image" required:"true"`
+	Prompt         string              `json:"prompt" required:"true" min:"1" max:"10000"`
+	SelectPrompt   string              `json:"select_prompt" required:"true" min:"1" max:"10000"`
+	NegativePrompt *string             `json:"negative_prompt" min:"1" max:"10000"`
+	GrowMask       *int                `json:"grow_mask" min:"0" max:"100"`
+	Seed           *int                `json:"seed" min:"0" max:"4294967294"`
+	OutputFormat   string              `json:"output_format" enum:"@stability.edit.output_formats"`
+}
+
+// RemoveBackgroundRequest holds the form fields of a remove-background request.
+type RemoveBackgroundRequest struct {
+	Image        *stability.FilePart `json:"image" required:"true"`
+	OutputFormat string              `json:"output_format" enum:"@stability.edit.background_formats"`
+}
+
+// Validate checks the request against its tags.
+func (r *InpaintRequest) Validate() error {
+	return validateRequest(r, OUTPUT_FORMATS)
+}
+
+// Validate checks the request against its tags and that it extends the image in at
+// least one direction.
+func (r *OutpaintRequest) Validate() error {
+	extended := false
+	for _, pixels := range []*int{r.Left, r.Right, r.Up, r.Down} {
+		if pixels != nil && *pixels > 0 {
+			extended = true
+		}
+	}
+	if !extended {
+		return validateRequest(r, OUTPUT_FORMATS, &ErrInvalidOutpaint{Err: errors.New("no direction is set")})
+	}
+	return validateRequest(r, OUTPUT_FORMATS)
+}
+
+// Validate checks the request against its tags.
+func (r *EraseRequest) Validate() error {
+	return validateRequest(r, OUTPUT_FORMATS)
+}
+
+// Validate checks the request against its tags.
+func (r *SearchAndReplaceRequest) Validate() error {
+	return validateRequest(r, OUTPUT_FORMATS)
+}
+
+// Validate checks the request against its tags.
+func (r *SearchAndRecolorRequest) Validate() error {
+	return validateRequest(r, OUTPUT_FORMATS)
+}
+
+// Validate checks the request against its tags.
+func (r *RemoveBackgroundRequest) Validate() error {
+	return validateRequest(r, REMOVE_BACKGROUND_OUTPUT_FORMATS)
+}
+
+// validateRequest checks a request against its tags and maps every violation onto this
+// package's typed errors. All errors, including errs, are returned joined.
+func validateRequest(r interface{}, outputFormats []string, errs ...error) error {
+	return validate.StructErrors(r, fieldErrors(outputFormats), errs...)
+}
+
+// fieldErrors maps request fields to this package's typed errors. Output format errors
+// list the formats of the operation.
+func fieldErrors(outputFormats []string) validate.ErrorMap {
+	return validate.ErrorMap{
+		"image":           func(err error) error { return &ErrMissingImage{Err: err} },
+		"prompt":          func(err error) error { return &ErrInvalidPromptLength{Err: err} },
+		"negative_prompt": func(err error) error { return &ErrInvalidNegativePromptLength{Err: err} },
+		"search_prompt":   func(err error) error { return &ErrInvalidSearchPrompt{Err: err} },
+		"select_prompt":   func(err error) error { return &ErrInvalidSelectPrompt{Err: err} },
+		"grow_mask":       func(err error) error { return &ErrInvalidGrowMask{Err: err} },
+		"left":            func(err error) error { return &ErrInvalidOutpaint{Err: err} },
+		"right":           func(err error) error { return &ErrInvalidOutpaint{Err: err} },
+		"up":              func(err error) error { return &ErrInvalidOutpaint{Err: err} },
+		"down":            func(err error) error { return &ErrInvalidOutpaint{Err: err} },
+		"creativity":      func(err error) error { return &ErrInvalidCreativity{Err: err} },
+		"seed":            func(err error) error { return &ErrInvalidSeed{Err: err} },
+		"output_format": func(err error) error {
+			return &ErrInvalidOutputFormat{Err: err, Formats: outputFormats}
+		},
+	}
+}
@@ -2,12 +2,34 @@ package generate
 
 import "github.com/rmrfslashbin/ami/stability"
 
+// PATH is the path of V3 requests
+const PATH = "/v2beta/stable-image/generate/sd3"
+
 // ENDPOINT is the endpoint for V3 requests
-var ENDPOINT = stability.ENDPOINT_ROOT + "/v2beta/stable-image/generate/sd3"
+var ENDPOINT = stability.ENDPOINT_ROOT + PATH
+
+// MODE_TEXT_TO_IMAGE generates from the prompt alone.
+const MODE_TEXT_TO_IMAGE = "text-to-image"
+
+// MODE_IMAGE_TO_IMAGE generates from the prompt and an input image.
+const MODE_IMAGE_TO_IMAGE = "image-to-image"
+
+// ACCEPT_IMAGE asks for the raw image bytes instead of base64 JSON.
+const ACCEPT_IMAGE = "image/*"
 
 // MODELS is a list of valid models for V3 endpoints
 var MODELS = []string{"sd3-medium", "sd3-large", "sd3-large-turbo"}
 
+// CREDITS is the price of a successful generation per model. Core and Ultra are keyed by
+// endpoint name.
+var CREDITS = map[string]float64{
+	"sd3-large":       6.5,
+	"sd3-large-turbo": 4,
+	"sd3-medium":      3.5,
+	"core":            3,
+	"ultra":           8,
+}
+
 // OUTPUT_FORMATS is a list of valid output formats for V3 endpoints
 var OUTPUT_FORMATS = []string{"jpeg", "png", "webp"}
 
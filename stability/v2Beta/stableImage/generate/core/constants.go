@@ -0,0 +1,19 @@
+package core
+
+import "github.com/rmrfslashbin/ami/stability"
+
+// PATH is the path of Core requests
+const PATH = "/v2beta/stable-image/generate/core"
+
+// ENDPOINT is the endpoint for Core requests
+var ENDPOINT = stability.ENDPOINT_ROOT + PATH
+
+// MODEL names Core in logs, spans and generate.CREDITS
+const MODEL = "core"
+
+// STYLE_PRESETS is a list of valid style presets
+var STYLE_PRESETS = []string{
+	"3d-model", "analog-film", "anime", "cinematic", "comic-book", "digital-art", "enhance",
+	"fantasy-art", "isometric", "line-art", "low-poly", "modeling-compound", "neon-punk",
+	"origami", "photographic", "pixel-art", "tile-texture",
+}
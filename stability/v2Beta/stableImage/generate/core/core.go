@@ -0,0 +1,220 @@
+package core
+
+import (
+	"log/slog"
+
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate"
+	"github.com/rmrfslashbin/ami/validate"
+)
+
+//https://platform.stability.ai/docs/api-reference#tag/Generate/paths/~1v2beta~1stable-image~1generate~1core/post
+
+/*
+Method: POST
+Headers:
+- authorization: Bearer ${API_KEY}
+- content-type: multipart/form-data
+- accept: image/* -- OR -- application/json to receive image as base64 string
+
+Body: form-data
+Required:
+- prompt ([1 .. 10000] characters)
+
+Optional:
+- aspect_ratio (default 1:1; enum)
+- negative_prompt (< 10000 chars)
+- seed (0 .. 4294967294)
+- style_preset (enum)
+- output_format (default png; enum)
+
+Credits:
+- Flat rate of 3 credits per successful generation. You will not be charged for failed generations.
+*/
+
+// MODULE_NAME is the module name
+const MODULE_NAME = "core"
+
+// Option is a function that takes a pointer to a Config struct and sets a value.
+type Option func(config *Core)
+
+// Configuration structure.
+type Core struct {
+	log            *slog.Logger
+	stability      *stability.Stability
+	prompt         *string
+	aspectRatio    *string
+	negativePrompt *string
+	seed           *int
+	stylePreset    *string
+	outputFormat   *string
+}
+
+var _ generate.Generator = (*Core)(nil)
+
+func init() {
+	validate.RegisterEnum("stability.style_presets", STYLE_PRESETS)
+}
+
+// New creates a new Core instance.
+func New(opts ...func(*Core)) (*Core, error) {
+	config := &Core{}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.stability == nil {
+		return nil, &generate.ErrMissingStability{}
+	}
+
+	if config.log == nil {
+		return nil, &generate.ErrMissingLogger{}
+	}
+
+	return config, nil
+}
+
+// WithLogger sets the logger for the Core instance.
+func WithLogger(log *slog.Logger) Option {
+	return func(config *Core) {
+		moduleLogger := log.With(
+			slog.Group("module_info",
+				slog.String("module", MODULE_NAME),
+			),
+		)
+		config.log = moduleLogger
+	}
+}
+
+// WithStability sets the stability instance for the Core instance.
+func WithStability(stability *stability.Stability) Option {
+	return func(config *Core) {
+		config.stability = stability
+	}
+}
+
+// WithPrompt sets the prompt for the Core instance.
+func WithPrompt(prompt string) Option {
+	return func(config *Core) {
+		config.prompt = &prompt
+	}
+}
+
+// WithAspectRatio sets the aspect ratio for the Core instance.
+func WithAspectRatio(aspectRatio string) Option {
+	return func(config *Core) {
+		config.aspectRatio = &aspectRatio
+	}
+}
+
+// WithNegativePrompt sets the negative prompt for the Core instance.
+func WithNegativePrompt(negativePrompt string) Option {
+	return func(config *Core) {
+		config.negativePrompt = &negativePrompt
+	}
+}
+
+// WithSeed sets the seed for the Core instance.
+func WithSeed(seed int) Option {
+	return func(config *Core) {
+		config.seed = &seed
+	}
+}
+
+// WithStylePreset guides the image towards a style, one of STYLE_PRESETS.
+func WithStylePreset(stylePreset string) Option {
+	return func(config *Core) {
+		config.stylePreset = &stylePreset
+	}
+}
+
+// WithOutputFormat sets the output format for the Core instance.
+func WithOutputFormat(outputFormat string) Option {
+	return func(config *Core) {
+		config.outputFormat = &outputFormat
+	}
+}
+
+// SetPrompt sets the prompt for the Core instance.
+func (c *Core) SetPrompt(prompt string) {
+	c.prompt = &prompt
+}
+
+// SetNegativePrompt sets the negative prompt for the Core instance.
+func (c *Core) SetNegativePrompt(negativePrompt string) {
+	c.negativePrompt = &negativePrompt
+}
+
+// SetAspectRatio sets the aspect ratio for the Core instance.
+func (c *Core) SetAspectRatio(aspectRatio string) {
+	c.aspectRatio = &aspectRatio
+}
+
+// SetSeed sets the seed for the Core instance.
+func (c *Core) SetSeed(seed int) {
+	c.seed = &seed
+}
+
+// SetStylePreset sets the style preset for the Core instance.
+func (c *Core) SetStylePreset(stylePreset string) {
+	c.stylePreset = &stylePreset
+}
+
+// SetOutputFormat sets the output format for the Core instance.
+func (c *Core) SetOutputFormat(outputFormat string) {
+	c.outputFormat = &outputFormat
+}
+
+// Generate generates an image using the Core instance.
+func (c *Core) Generate() (*generate.Response, error) {
+	// validate prompt
+	if c.prompt == nil {
+		return nil, &generate.ErrMissingPrompt{}
+	}
+
+	// apply defaults without changing the configuration, so it can be reused
+	request := &Request{
+		Prompt:         *c.prompt,
+		AspectRatio:    generate.DEFAULT_ASPECT_RATIO,
+		NegativePrompt: c.negativePrompt,
+		Seed:           generate.DEFAULT_SEED,
+		StylePreset:    c.stylePreset,
+		OutputFormat:   generate.DEFAULT_OUTPUT_FORMAT,
+	}
+	if c.aspectRatio != nil {
+		request.AspectRatio = *c.aspectRatio
+	}
+	if c.seed != nil {
+		request.Seed = *c.seed
+	}
+	if c.outputFormat != nil {
+		request.OutputFormat = *c.outputFormat
+	}
+
+	// validate the request, reporting every invalid field
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	form := c.stability.NewRequest(stability.METHOD_POST, PATH)
+	form.AddFormPart("prompt", request.Prompt)
+	if request.NegativePrompt != nil {
+		form.AddFormPart("negative_prompt", *request.NegativePrompt)
+	}
+	form.AddFormPart("aspect_ratio", request.AspectRatio)
+	form.AddFormPart("seed", request.Seed)
+	if request.StylePreset != nil {
+		form.AddFormPart("style_preset", *request.StylePreset)
+	}
+	form.AddFormPart("output_format", request.OutputFormat)
+	form.AddHeader("accept", "application/json")
+
+	return generate.Send(c.stability, c.log, form, &generate.SendOptions{
+		Model:        MODEL,
+		Credits:      generate.CREDITS[MODEL],
+		OutputFormat: request.OutputFormat,
+		Seed:         request.Seed,
+	})
+}
@@ -0,0 +1,74 @@
+package core_test
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/rmrfslashbin/ami/stability/stabilitytest"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate/core"
+)
+
+var log = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func newCore(t *testing.T, opts ...func(*core.Core)) (*stabilitytest.Server, *core.Core) {
+	t.Helper()
+	server, err := stabilitytest.New()
+	if err != nil {
+		t.Fatalf("stabilitytest.New: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	s, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	opts = append([]func(*core.Core){core.WithStability(s), core.WithLogger(log)}, opts...)
+	c, err := core.New(opts...)
+	if err != nil {
+		t.Fatalf("core.New: %v", err)
+	}
+	return server, c
+}
+
+func TestGenerate(t *testing.T) {
+	server, c := newCore(t, core.WithPrompt("a lighthouse"), core.WithStylePreset("anime"))
+
+	// Core is used through the shared Generator interface
+	var g generate.Generator = c
+	g.SetSeed(7)
+	res, err := g.Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if res.Image == nil || *res.Image == "" || res.Seed == nil || *res.Seed != 7 {
+		t.Errorf("Generate = %+v, want an image and seed 7", res)
+	}
+
+	received := server.LastRequest()
+	if received.Path != core.PATH || received.Form["style_preset"][0] != "anime" {
+		t.Errorf("received %s %v", received.Path, received.Form)
+	}
+	if want := stabilitytest.DEFAULT_CREDITS - generate.CREDITS[core.MODEL]; server.Credits() != want {
+		t.Errorf("Credits = %g, want %g", server.Credits(), want)
+	}
+	if err := server.Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	server, c := newCore(t, core.WithPrompt("a lighthouse"), core.WithStylePreset("noir"), core.WithSeed(-1))
+
+	_, err := c.Generate()
+	var preset *core.ErrInvalidStylePreset
+	var seed *generate.ErrInvalidSeed
+	if !errors.As(err, &preset) || !errors.As(err, &seed) {
+		t.Errorf("Generate = %v, want ErrInvalidStylePreset and ErrInvalidSeed", err)
+	}
+	if n := len(server.Requests()); n != 0 {
+		t.Errorf("%d requests sent for an invalid generation", n)
+	}
+}
@@ -0,0 +1,20 @@
+package core
+
+import "strings"
+
+type ErrInvalidStylePreset struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidStylePreset) Error() string {
+	validStylePresets := strings.Join(STYLE_PRESETS, ", ")
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid style preset- use WithStylePreset or SetStylePreset to set it. Must be one of " + validStylePresets
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
@@ -0,0 +1,32 @@
+package core
+
+import (
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate"
+	"github.com/rmrfslashbin/ami/validate"
+)
+
+// Request holds the form fields of a Core request. The tags are checked by validate.Struct.
+type Request struct {
+	Prompt         string  `json:"prompt" required:"true" min:"1" max:"10000"`
+	AspectRatio    string  `json:"aspect_ratio" enum:"@stability.aspect_ratios"`
+	NegativePrompt *string `json:"negative_prompt" min:"1" max:"10000"`
+	Seed           int     `json:"seed" min:"0" max:"4294967294"`
+	StylePreset    *string `json:"style_preset" enum:"@stability.style_presets"`
+	OutputFormat   string  `json:"output_format" enum:"@stability.output_formats"`
+}
+
+// fieldErrors maps request fields to the generate package's typed errors.
+var fieldErrors = validate.ErrorMap{
+	"prompt":          func(err error) error { return &generate.ErrInvalidPromptLength{Err: err} },
+	"negative_prompt": func(err error) error { return &generate.ErrInvalidNegativePromptLength{Err: err} },
+	"aspect_ratio":    func(err error) error { return &generate.ErrInvalidAspectRatio{Err: err} },
+	"seed":            func(err error) error { return &generate.ErrInvalidSeed{Err: err} },
+	"style_preset":    func(err error) error { return &ErrInvalidStylePreset{Err: err} },
+	"output_format":   func(err error) error { return &generate.ErrInvalidOutputFormat{Err: err} },
+}
+
+// Validate checks the request against its tags and maps every violation onto the
+// generate package's typed errors. All errors are returned joined.
+func (r *Request) Validate() error {
+	return validate.StructErrors(r, fieldErrors)
+}
@@ -210,3 +210,71 @@ func (e *ErrInvalidNegativePromptLength) Error() string {
 	}
 	return e.Msg
 }
+
+type ErrMissingImage struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingImage) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing image- use WithImage or SetImage to set it in image-to-image mode"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidStrength struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidStrength) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid strength- use WithStrength or SetStrength to set it. Required in image-to-image mode and must be between 0 and 1"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrAspectRatioWithImage struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrAspectRatioWithImage) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "aspect ratio is not supported in image-to-image mode; the output matches the input image"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrOutputFile struct {
+	Err      error
+	Msg      string
+	Filename string
+}
+
+func (e *ErrOutputFile) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "unable to write output file"
+	}
+	if e.Filename != "" {
+		msg = msg + ": " + e.Filename
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
@@ -1,11 +1,12 @@
 package generate
 
 import (
-	"encoding/json"
+	"io"
 	"log/slog"
-	"slices"
+	"os"
 
 	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/validate"
 )
 
 // path: stability/stability.go
@@ -24,8 +25,10 @@ Required:
 - prompt ([1 .. 10000] characters)
 
 Optional:
-- aspect_ratio (default 1:1; enum)
+- aspect_ratio (default 1:1; enum) text-to-image only
 - mode: text-to-image || image-to-image (default text-to-image)
+- image (file) required for image-to-image
+- strength ([0 .. 1]) required for image-to-image
 - negative_prompt (< 10000 chars) not valid for sd3-turbo
 - model (default sd3; enum)
 - seed (0 .. 4294967294)
@@ -52,11 +55,20 @@ type StabilityV3 struct {
 	stability      *stability.Stability
 	prompt         *string
 	aspectRatio    *string
-	mode           string
+	image          *stability.FilePart
+	strength       *float64
 	negativePrompt *string
 	model          *string
 	seed           *int
 	outputFormat   *string
+	output         io.Writer
+	outputFile     *string
+}
+
+func init() {
+	validate.RegisterEnum("stability.aspect_ratios", ASPECT_RATIOS)
+	validate.RegisterEnum("stability.models", MODELS)
+	validate.RegisterEnum("stability.output_formats", OUTPUT_FORMATS)
 }
 
 // New creates a new StabilityV3 instance.
@@ -119,6 +131,36 @@ func WithNegativePrompt(negativePrompt string) Option {
 	}
 }
 
+// WithImage sets the input image, read from a path, and switches to image-to-image mode.
+func WithImage(path string) Option {
+	return func(config *StabilityV3) {
+		config.image = &stability.FilePart{Path: path}
+	}
+}
+
+// WithImageBytes sets the input image and switches to image-to-image mode.
+func WithImageBytes(data []byte) Option {
+	return func(config *StabilityV3) {
+		config.image = &stability.FilePart{Data: data}
+	}
+}
+
+// WithImageReader sets the input image, streamed from r, and switches to image-to-image
+// mode. r is read once, so the instance can only generate once.
+func WithImageReader(r io.Reader) Option {
+	return func(config *StabilityV3) {
+		config.image = &stability.FilePart{Reader: r}
+	}
+}
+
+// WithStrength sets how much the input image is changed in image-to-image mode, from 0
+// (an identical image) to 1 (the input is ignored). It is required with an image.
+func WithStrength(strength float64) Option {
+	return func(config *StabilityV3) {
+		config.strength = &strength
+	}
+}
+
 // WithModel sets the model for the StabilityV3 instance.
 func WithModel(model string) Option {
 	return func(config *StabilityV3) {
@@ -140,6 +182,24 @@ func WithOutputFormat(outputFormat string) Option {
 	}
 }
 
+// WithOutput streams the raw image bytes to w instead of returning them base64 encoded in
+// Response.Image. The finish reason and seed are still set in the Response.
+func WithOutput(w io.Writer) Option {
+	return func(config *StabilityV3) {
+		config.output = w
+		config.outputFile = nil
+	}
+}
+
+// WithOutputFile streams the raw image bytes to a file, which is created or truncated.
+// Response.Filename is set to filename.
+func WithOutputFile(filename string) Option {
+	return func(config *StabilityV3) {
+		config.outputFile = &filename
+		config.output = nil
+	}
+}
+
 // SetPrompt sets the prompt for the StabilityV3 instance.
 func (c *StabilityV3) SetPrompt(prompt string) {
 	c.prompt = &prompt
@@ -155,6 +215,17 @@ func (c *StabilityV3) SetAspectRatio(aspectRatio string) {
 	c.aspectRatio = &aspectRatio
 }
 
+// SetImage sets the input image and switches to image-to-image mode. A nil image switches
+// back to text-to-image.
+func (c *StabilityV3) SetImage(image *stability.FilePart) {
+	c.image = image
+}
+
+// SetStrength sets the image-to-image strength.
+func (c *StabilityV3) SetStrength(strength float64) {
+	c.strength = &strength
+}
+
 // SetModel sets the model for the StabilityV3 instance.
 func (c *StabilityV3) SetModel(model string) {
 	c.model = &model
@@ -165,6 +236,23 @@ func (c *StabilityV3) SetSeed(seed int) {
 	c.seed = &seed
 }
 
+// SetOutputFormat sets the output format for the StabilityV3 instance.
+func (c *StabilityV3) SetOutputFormat(outputFormat string) {
+	c.outputFormat = &outputFormat
+}
+
+// SetOutput streams the raw image bytes to w. A nil w switches back to base64 JSON.
+func (c *StabilityV3) SetOutput(w io.Writer) {
+	c.output = w
+	c.outputFile = nil
+}
+
+// SetOutputFile streams the raw image bytes to a file.
+func (c *StabilityV3) SetOutputFile(filename string) {
+	c.outputFile = &filename
+	c.output = nil
+}
+
 // SetStability sets the stability instance for the StabilityV3 instance.
 func (c *StabilityV3) SetStability(stability *stability.Stability) {
 	c.stability = stability
@@ -177,93 +265,104 @@ func (c *StabilityV3) Generate() (*Response, error) {
 		return nil, &ErrMissingPrompt{}
 	}
 
-	// check length of prompt
-	if len(*c.prompt) < 1 || len(*c.prompt) > MAX_PROMPT_LENGTH {
-		return nil, &ErrInvalidPromptLength{}
+	// apply defaults without changing the configuration, so it can be reused
+	request := &Request{
+		Prompt:         *c.prompt,
+		Mode:           MODE_TEXT_TO_IMAGE,
+		NegativePrompt: c.negativePrompt,
+		Model:          DEFAULT_MODEL,
+		Seed:           DEFAULT_SEED,
+		OutputFormat:   DEFAULT_OUTPUT_FORMAT,
 	}
-
-	c.stability.AddFormPart("prompt", *c.prompt)
-
-	// validate negative prompt
-	if c.negativePrompt != nil {
-		// check length of negative prompt
-		if len(*c.negativePrompt) < 1 || len(*c.negativePrompt) > MAX_PROMPT_LENGTH {
-			return nil, &ErrInvalidNegativePromptLength{}
-		}
-		c.stability.AddFormPart("negative_prompt", *c.negativePrompt)
+	if c.model != nil {
+		request.Model = *c.model
 	}
-
-	// validate aspect ratio
-	if c.aspectRatio == nil {
-		aspectRatio := DEFAULT_ASPECT_RATIO
-		c.aspectRatio = &aspectRatio
-	} else {
-		// validate aspect ratio
-		if !slices.Contains(ASPECT_RATIOS, *c.aspectRatio) {
-			return nil, &ErrInvalidAspectRatio{}
-		}
+	if c.seed != nil {
+		request.Seed = *c.seed
+	}
+	if c.outputFormat != nil {
+		request.OutputFormat = *c.outputFormat
 	}
-	c.stability.AddFormPart("aspect_ratio", *c.aspectRatio)
 
-	// validate model
-	if c.model == nil {
-		model := DEFAULT_MODEL
-		c.model = &model
+	// an input image switches to image-to-image, which takes no aspect ratio
+	if c.image != nil {
+		request.Mode = MODE_IMAGE_TO_IMAGE
+		request.Image = c.image
+		request.Strength = c.strength
+		if c.aspectRatio != nil {
+			request.AspectRatio = *c.aspectRatio
+		}
 	} else {
-		// validate model
-		if !slices.Contains(MODELS, *c.model) {
-			return nil, &ErrInvalidModel{}
+		request.AspectRatio = DEFAULT_ASPECT_RATIO
+		if c.aspectRatio != nil {
+			request.AspectRatio = *c.aspectRatio
 		}
 	}
-	c.stability.AddFormPart("model", *c.model)
 
-	// validate seed
-	if c.seed == nil {
-		seed := DEFAULT_SEED
-		c.seed = &seed
-	} else {
-		// validate seed
-		if *c.seed < 0 || *c.seed > MAX_SEED {
-			return nil, &ErrInvalidSeed{}
-		}
+	// validate the request, reporting every invalid field
+	if err := request.Validate(); err != nil {
+		return nil, err
 	}
-	c.stability.AddFormPart("seed", *c.seed)
 
-	// validate output format
-	if c.outputFormat == nil {
-		outputFormat := DEFAULT_OUTPUT_FORMAT
-		c.outputFormat = &outputFormat
+	form := c.stability.NewRequest(stability.METHOD_POST, PATH)
+	form.AddFormPart("prompt", request.Prompt)
+	if request.NegativePrompt != nil {
+		form.AddFormPart("negative_prompt", *request.NegativePrompt)
+	}
+	if request.Mode == MODE_IMAGE_TO_IMAGE {
+		form.AddFormPart("image", request.Image)
+		form.AddFormPart("strength", *request.Strength)
 	} else {
-		// validate output format
-		if !slices.Contains(OUTPUT_FORMATS, *c.outputFormat) {
-			return nil, &ErrInvalidOutputFormat{}
-		}
+		form.AddFormPart("aspect_ratio", request.AspectRatio)
 	}
-	c.stability.AddFormPart("output_format", *c.outputFormat)
-	c.stability.AddHeader("accept", "application/json")
+	form.AddFormPart("model", request.Model)
+	form.AddFormPart("seed", request.Seed)
+	form.AddFormPart("output_format", request.OutputFormat)
+	form.AddFormPart("mode", request.Mode)
+
+	// raw mode streams the image instead of holding it base64 encoded in memory
+	if c.output != nil || c.outputFile != nil {
+		form.AddHeader("accept", ACCEPT_IMAGE)
+		return c.generateRaw(form, request)
+	}
+	form.AddHeader("accept", "application/json")
 
-	// set default values. Only text-to-image is supported
-	c.mode = "text-to-image"
-	c.stability.AddFormPart("mode", c.mode)
+	return Send(c.stability, c.log, form, c.sendOptions(request, nil))
+}
 
-	// Execute the request
-	res, err := c.stability.Do(&ENDPOINT, stability.METHOD_POST)
-	if err != nil {
-		return nil, err
+// generateRaw sends a request accepting image/* and streams the image to the output
+// writer or file. A file is removed again if the generation fails.
+func (c *StabilityV3) generateRaw(form *stability.Request, request *Request) (*Response, error) {
+	if c.output != nil {
+		return Send(c.stability, c.log, form, c.sendOptions(request, c.output))
 	}
 
-	// check if response is nil
-	if res == nil {
-		return nil, &ErrEmptyResponse{}
+	filename := *c.outputFile
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, &ErrOutputFile{Err: err, Filename: filename}
 	}
 
-	// create a response object
-	response := &Response{}
-
-	// Unmarshal the response body
-	if err = json.Unmarshal(res.Body, response); err != nil {
-		return nil, &ErrUnableToParseResponse{Err: err, Response: res.Body}
+	response, err := Send(c.stability, c.log, form, c.sendOptions(request, file))
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = &ErrOutputFile{Err: closeErr, Filename: filename}
+	}
+	if err != nil {
+		os.Remove(filename)
+		return response, err
 	}
 
+	response.Filename = &filename
 	return response, nil
 }
+
+// sendOptions describes the image of a request, streamed to output if it is set.
+func (c *StabilityV3) sendOptions(request *Request, output io.Writer) *SendOptions {
+	return &SendOptions{
+		Model:        request.Model,
+		Credits:      CREDITS[request.Model],
+		OutputFormat: request.OutputFormat,
+		Seed:         request.Seed,
+		Output:       output,
+	}
+}
@@ -0,0 +1,200 @@
+package generate_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/stability/stabilitytest"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate"
+)
+
+var log = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// pngImage returns a small PNG.
+func pngImage(t *testing.T) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("encoding PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func newGenerator(t *testing.T, opts ...func(*generate.StabilityV3)) (*stabilitytest.Server, *generate.StabilityV3) {
+	t.Helper()
+	server, err := stabilitytest.New()
+	if err != nil {
+		t.Fatalf("stabilitytest.New: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	s, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	opts = append([]func(*generate.StabilityV3){generate.WithStability(s), generate.WithLogger(log)}, opts...)
+	g, err := generate.New(opts...)
+	if err != nil {
+		t.Fatalf("generate.New: %v", err)
+	}
+	return server, g
+}
+
+func TestGenerateImageToImage(t *testing.T) {
+	img := pngImage(t)
+	server, g := newGenerator(t, generate.WithPrompt("a cat in a hat"), generate.WithImageBytes(img), generate.WithStrength(0.6))
+
+	// the configuration is not changed by Generate, so it can run again
+	for i := 0; i < 2; i++ {
+		res, err := g.Generate()
+		if err != nil {
+			t.Fatalf("Generate %d: %v", i, err)
+		}
+		if res.Image == nil || *res.Image == "" {
+			t.Errorf("Generate %d returned no image", i)
+		}
+
+		received := server.LastRequest()
+		if mode := received.Form["mode"]; len(mode) != 1 || mode[0] != generate.MODE_IMAGE_TO_IMAGE {
+			t.Errorf("mode = %v", mode)
+		}
+		if strength := received.Form["strength"]; len(strength) != 1 || strength[0] != "0.6" {
+			t.Errorf("strength = %v", strength)
+		}
+		if _, ok := received.Form["aspect_ratio"]; ok {
+			t.Errorf("aspect_ratio sent in image-to-image mode")
+		}
+		if files := received.Files["image"]; len(files) != 1 || files[0].Size != int64(len(img)) {
+			t.Errorf("image files = %+v", files)
+		}
+	}
+	if err := server.Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestGenerateImageToImageValidation(t *testing.T) {
+	img := pngImage(t)
+
+	tests := []struct {
+		name  string
+		opts  []func(*generate.StabilityV3)
+		check func(error) bool
+	}{
+		{name: "missing strength", opts: []func(*generate.StabilityV3){generate.WithImageBytes(img)},
+			check: func(err error) bool { var e *generate.ErrInvalidStrength; return errors.As(err, &e) }},
+		{name: "strength out of range", opts: []func(*generate.StabilityV3){generate.WithImageBytes(img), generate.WithStrength(1.5)},
+			check: func(err error) bool { var e *generate.ErrInvalidStrength; return errors.As(err, &e) }},
+		{name: "aspect ratio with image", opts: []func(*generate.StabilityV3){generate.WithImageBytes(img), generate.WithStrength(0.5), generate.WithAspectRatio("1:1")},
+			check: func(err error) bool { var e *generate.ErrAspectRatioWithImage; return errors.As(err, &e) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]func(*generate.StabilityV3){generate.WithPrompt("a cat")}, tt.opts...)
+			server, g := newGenerator(t, opts...)
+			if _, err := g.Generate(); !tt.check(err) {
+				t.Errorf("Generate = %v", err)
+			}
+			if n := len(server.Requests()); n != 0 {
+				t.Errorf("%d requests sent for an invalid generation", n)
+			}
+		})
+	}
+}
+
+func TestGenerateRaw(t *testing.T) {
+	server, g := newGenerator(t, generate.WithPrompt("a lighthouse"), generate.WithSeed(11))
+
+	buf := &bytes.Buffer{}
+	g.SetOutput(buf)
+	res, err := g.Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if res.Image != nil || res.Seed == nil || *res.Seed != 11 || res.FinishReason == nil || *res.FinishReason != "SUCCESS" {
+		t.Errorf("Generate = %+v, want the headers and no base64 image", res)
+	}
+	if _, err := png.DecodeConfig(buf); err != nil {
+		t.Errorf("streamed image: %v", err)
+	}
+	if accept := server.LastRequest().Header.Get("accept"); accept != generate.ACCEPT_IMAGE {
+		t.Errorf("accept = %q, want %q", accept, generate.ACCEPT_IMAGE)
+	}
+
+	filename := filepath.Join(t.TempDir(), "lighthouse.png")
+	g.SetOutputFile(filename)
+	res, err = g.Generate()
+	if err != nil {
+		t.Fatalf("Generate to file: %v", err)
+	}
+	if res.Filename == nil || *res.Filename != filename {
+		t.Errorf("Filename = %v, want %s", res.Filename, filename)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("reading image: %v", err)
+	}
+	if _, err := png.DecodeConfig(bytes.NewReader(data)); err != nil {
+		t.Errorf("saved image: %v", err)
+	}
+	if err := server.Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestGenerateRawFailed(t *testing.T) {
+	server, g := newGenerator(t, generate.WithPrompt("a lighthouse"))
+	server.Enqueue(stabilitytest.Moderated())
+
+	filename := filepath.Join(t.TempDir(), "lighthouse.png")
+	g.SetOutputFile(filename)
+	res, err := g.Generate()
+	var httpErr *stability.ErrHTTP
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != 403 || res == nil || res.Errors.Name != "content_moderation" {
+		t.Errorf("Generate = %+v, %v, want the 403 errors", res, err)
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("output file left behind: %v", err)
+	}
+
+	var output *generate.ErrOutputFile
+	g.SetOutputFile(filepath.Join(t.TempDir(), "missing", "lighthouse.png"))
+	if _, err := g.Generate(); !errors.As(err, &output) {
+		t.Errorf("Generate = %v, want ErrOutputFile", err)
+	}
+}
+
+func TestSend(t *testing.T) {
+	server, err := stabilitytest.New()
+	if err != nil {
+		t.Fatalf("stabilitytest.New: %v", err)
+	}
+	t.Cleanup(server.Close)
+	s, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+
+	form := s.NewRequest(stability.METHOD_POST, generate.PATH)
+	form.AddFormPart("prompt", "a lighthouse")
+	form.AddFormPart("seed", 3)
+	form.AddHeader("accept", generate.ACCEPT_IMAGE)
+
+	buf := &bytes.Buffer{}
+	res, err := generate.Send(s, log, form, &generate.SendOptions{Model: generate.DEFAULT_MODEL, Seed: 3, Output: buf})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if res.Seed == nil || *res.Seed != 3 || buf.Len() == 0 {
+		t.Errorf("Send = %+v with %d bytes, want seed 3 and the image", res, buf.Len())
+	}
+}
@@ -0,0 +1,14 @@
+package generate
+
+// Generator is an image generation endpoint: StabilityV3 (SD3), core.Core or ultra.Ultra.
+// Code written against it switches endpoints by changing only the constructor.
+type Generator interface {
+	SetPrompt(prompt string)
+	SetNegativePrompt(negativePrompt string)
+	SetAspectRatio(aspectRatio string)
+	SetSeed(seed int)
+	SetOutputFormat(outputFormat string)
+	Generate() (*Response, error)
+}
+
+var _ Generator = (*StabilityV3)(nil)
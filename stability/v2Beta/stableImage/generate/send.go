@@ -0,0 +1,157 @@
+package generate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/rmrfslashbin/ami/instrument"
+	"github.com/rmrfslashbin/ami/stability"
+)
+
+// SendOptions describes the image a request generates.
+type SendOptions struct {
+	// Model names the image model, or the operation, in spans and logs.
+	Model string
+
+	// Credits is the price recorded on success.
+	Credits float64
+
+	// OutputFormat is the requested image format.
+	OutputFormat string
+
+	// Seed is the requested seed.
+	Seed int
+
+	// Output receives the raw image bytes of a request accepting image/*; the finish
+	// reason and seed are then read from the response headers. If nil, the JSON response
+	// is decoded.
+	Output io.Writer
+}
+
+// Send executes a request to any endpoint answering with a generated image, e.g. the
+// generate or edit endpoints, and decodes the response.
+func Send(s *stability.Stability, log *slog.Logger, form *stability.Request, options *SendOptions) (_ *Response, err error) {
+	// trace the generation, including image attributes unknown to the HTTP span
+	instrumentation := s.GetInstrumentation()
+	ctx, span := instrumentation.Start(context.Background(), "generate "+options.Model,
+		instrument.String(instrument.ATTR_GEN_AI_SYSTEM, stability.GEN_AI_SYSTEM),
+		instrument.String(instrument.ATTR_GEN_AI_OPERATION_NAME, "generate"),
+		instrument.String(instrument.ATTR_STABILITY_IMAGE_MODEL, options.Model),
+		instrument.Int(instrument.ATTR_STABILITY_SEED, options.Seed))
+	var response *Response
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.End()
+			return
+		}
+		if response.Seed != nil {
+			span.SetAttributes(instrument.Int(instrument.ATTR_STABILITY_SEED, *response.Seed))
+		}
+		if response.FinishReason != nil {
+			span.SetAttributes(instrument.String(instrument.ATTR_STABILITY_FINISH_REASON, *response.FinishReason))
+		}
+		span.SetAttributes(instrument.Float64(instrument.ATTR_STABILITY_CREDITS, options.Credits))
+		span.End()
+		instrumentation.Add(ctx, instrument.METRIC_CREDITS, options.Credits,
+			instrument.String(instrument.ATTR_STABILITY_IMAGE_MODEL, options.Model))
+	}()
+
+	// Execute the request
+	var res *stability.StabilityResponse
+	if options.Output != nil {
+		res, err = s.DoStream(ctx, form, options.Output)
+	} else {
+		res, err = s.DoContext(ctx, form)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Output != nil {
+		response, err = DecodeRaw(res, form.URL)
+	} else {
+		response, err = Decode(res, form.URL)
+	}
+	if err != nil {
+		return response, err
+	}
+
+	attrs := []any{
+		slog.String("model", options.Model),
+		slog.String("output_format", options.OutputFormat),
+	}
+	if options.Output != nil {
+		attrs = append(attrs, slog.Int64("bytes", res.Written))
+	}
+	if response.Seed != nil {
+		attrs = append(attrs, slog.Int("seed", *response.Seed))
+	}
+	if response.FinishReason != nil {
+		attrs = append(attrs, slog.String("finish_reason", *response.FinishReason))
+	}
+	log.Info("image generated", attrs...)
+
+	return response, nil
+}
+
+// Decode decodes the JSON body of a generated image. Error statuses are decoded into
+// Response.Errors and returned with an ErrHTTP.
+func Decode(res *stability.StabilityResponse, url string) (*Response, error) {
+	// check if response is nil
+	if res == nil {
+		return nil, &ErrEmptyResponse{}
+	}
+
+	// create a response object
+	response := &Response{}
+
+	// errors come back as a top level {id, name, errors} object
+	if res.StatusCode != http.StatusOK {
+		response.Errors = &ResponseErrors{}
+		if err := json.Unmarshal(res.Body, response.Errors); err != nil {
+			return nil, &ErrUnableToParseResponse{Err: err, Response: res.Body}
+		}
+		return response, &stability.ErrHTTP{
+			StatusCode: res.StatusCode,
+			Url:        url,
+			Err:        errors.New(strings.Join(response.Errors.Errors, "; ")),
+		}
+	}
+
+	// Unmarshal the response body
+	if err := json.Unmarshal(res.Body, response); err != nil {
+		return nil, &ErrUnableToParseResponse{Err: err, Response: res.Body}
+	}
+
+	return response, nil
+}
+
+// DecodeRaw decodes the headers of an image streamed with stability.DoStream. Error
+// statuses are handled as in Decode.
+func DecodeRaw(res *stability.StabilityResponse, url string) (*Response, error) {
+	if res == nil || res.StatusCode != http.StatusOK {
+		return Decode(res, url)
+	}
+
+	response := &Response{}
+	finishReason := res.FinishReason()
+	if finishReason == "" {
+		return nil, &ErrFetchingReturnHeader{Header: "finish-reason"}
+	}
+	response.FinishReason = &finishReason
+	if res.Header("seed") != "" {
+		seed, ok := res.Seed()
+		if !ok {
+			return nil, &ErrFetchingReturnHeader{Header: "seed", Err: fmt.Errorf("not a number: %s", res.Header("seed"))}
+		}
+		response.Seed = &seed
+	}
+	return response, nil
+}
@@ -1,13 +1,25 @@
 package generate
 
+import (
+	"fmt"
+
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/validate"
+)
+
+// Request holds the form fields of a generate request. The tags are checked by validate.Struct.
 type Request struct {
-	Prompt         string `json:"prompt"`
-	AspectRatio    string `json:"aspect_ratio"`
-	Mode           string `json:"mode"`
-	NegativePrompt string `json:"negative_prompt"`
-	Model          string `json:"model"`
-	Seed           int    `json:"seed"`
-	OutputFormat   string `json:"output_format"`
+	Prompt         string  `json:"prompt" required:"true" min:"1" max:"10000"`
+	AspectRatio    string  `json:"aspect_ratio" enum:"@stability.aspect_ratios"`
+	Mode           string  `json:"mode" enum:"text-to-image,image-to-image"`
+	NegativePrompt *string `json:"negative_prompt" min:"1" max:"10000"`
+	Model          string  `json:"model" enum:"@stability.models"`
+	Seed           int     `json:"seed" min:"0" max:"4294967294"`
+	OutputFormat   string  `json:"output_format" enum:"@stability.output_formats"`
+
+	// Image and Strength are only sent in image-to-image mode.
+	Image    *stability.FilePart `json:"image" require_if:"Mode=image-to-image"`
+	Strength *float64            `json:"strength" require_if:"Mode=image-to-image" min:"0" max:"1"`
 }
 
 type Response struct {
@@ -23,3 +35,25 @@ type ResponseErrors struct {
 	Id     string   `json:"id,omitempty"`
 	Name   string   `json:"name,omitempty"`
 }
+
+// fieldErrors maps request fields to this package's typed errors.
+var fieldErrors = validate.ErrorMap{
+	"prompt":          func(err error) error { return &ErrInvalidPromptLength{Err: err} },
+	"negative_prompt": func(err error) error { return &ErrInvalidNegativePromptLength{Err: err} },
+	"aspect_ratio":    func(err error) error { return &ErrInvalidAspectRatio{Err: err} },
+	"model":           func(err error) error { return &ErrInvalidModel{Err: err} },
+	"seed":            func(err error) error { return &ErrInvalidSeed{Err: err} },
+	"output_format":   func(err error) error { return &ErrInvalidOutputFormat{Err: err} },
+	"image":           func(err error) error { return &ErrMissingImage{Err: err} },
+	"strength":        func(err error) error { return &ErrInvalidStrength{Err: err} },
+}
+
+// Validate checks the request against its tags and maps every violation onto this
+// package's typed errors. All errors are returned joined.
+func (r *Request) Validate() error {
+	errs := []error{}
+	if r.Mode == MODE_IMAGE_TO_IMAGE && r.AspectRatio != "" {
+		errs = append(errs, &ErrAspectRatioWithImage{Err: fmt.Errorf("aspect_ratio is %s", r.AspectRatio)})
+	}
+	return validate.StructErrors(r, fieldErrors, errs...)
+}
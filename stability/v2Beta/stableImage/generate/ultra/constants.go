@@ -0,0 +1,12 @@
+package ultra
+
+import "github.com/rmrfslashbin/ami/stability"
+
+// PATH is the path of Ultra requests
+const PATH = "/v2beta/stable-image/generate/ultra"
+
+// ENDPOINT is the endpoint for Ultra requests
+var ENDPOINT = stability.ENDPOINT_ROOT + PATH
+
+// MODEL names Ultra in logs, spans and generate.CREDITS
+const MODEL = "ultra"
@@ -0,0 +1,248 @@
+package ultra
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate"
+)
+
+//https://platform.stability.ai/docs/api-reference#tag/Generate/paths/~1v2beta~1stable-image~1generate~1ultra/post
+
+/*
+Method: POST
+Headers:
+- authorization: Bearer ${API_KEY}
+- content-type: multipart/form-data
+- accept: image/* -- OR -- application/json to receive image as base64 string
+
+Body: form-data
+Required:
+- prompt ([1 .. 10000] characters)
+
+Optional:
+- aspect_ratio (default 1:1; enum)
+- negative_prompt (< 10000 chars)
+- seed (0 .. 4294967294)
+- output_format (default png; enum)
+- image (file) starting point of the generation
+- strength ([0 .. 1]) required with image
+
+Credits:
+- Flat rate of 8 credits per successful generation. You will not be charged for failed generations.
+*/
+
+// MODULE_NAME is the module name
+const MODULE_NAME = "ultra"
+
+// Option is a function that takes a pointer to a Config struct and sets a value.
+type Option func(config *Ultra)
+
+// Configuration structure.
+type Ultra struct {
+	log            *slog.Logger
+	stability      *stability.Stability
+	prompt         *string
+	aspectRatio    *string
+	negativePrompt *string
+	seed           *int
+	image          *stability.FilePart
+	strength       *float64
+	outputFormat   *string
+}
+
+var _ generate.Generator = (*Ultra)(nil)
+
+// New creates a new Ultra instance.
+func New(opts ...func(*Ultra)) (*Ultra, error) {
+	config := &Ultra{}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.stability == nil {
+		return nil, &generate.ErrMissingStability{}
+	}
+
+	if config.log == nil {
+		return nil, &generate.ErrMissingLogger{}
+	}
+
+	return config, nil
+}
+
+// WithLogger sets the logger for the Ultra instance.
+func WithLogger(log *slog.Logger) Option {
+	return func(config *Ultra) {
+		moduleLogger := log.With(
+			slog.Group("module_info",
+				slog.String("module", MODULE_NAME),
+			),
+		)
+		config.log = moduleLogger
+	}
+}
+
+// WithStability sets the stability instance for the Ultra instance.
+func WithStability(stability *stability.Stability) Option {
+	return func(config *Ultra) {
+		config.stability = stability
+	}
+}
+
+// WithPrompt sets the prompt for the Ultra instance.
+func WithPrompt(prompt string) Option {
+	return func(config *Ultra) {
+		config.prompt = &prompt
+	}
+}
+
+// WithAspectRatio sets the aspect ratio for the Ultra instance.
+func WithAspectRatio(aspectRatio string) Option {
+	return func(config *Ultra) {
+		config.aspectRatio = &aspectRatio
+	}
+}
+
+// WithNegativePrompt sets the negative prompt for the Ultra instance.
+func WithNegativePrompt(negativePrompt string) Option {
+	return func(config *Ultra) {
+		config.negativePrompt = &negativePrompt
+	}
+}
+
+// WithSeed sets the seed for the Ultra instance.
+func WithSeed(seed int) Option {
+	return func(config *Ultra) {
+		config.seed = &seed
+	}
+}
+
+// WithImage sets a starting image, read from a path.
+func WithImage(path string) Option {
+	return func(config *Ultra) {
+		config.image = &stability.FilePart{Path: path}
+	}
+}
+
+// WithImageBytes sets a starting image.
+func WithImageBytes(data []byte) Option {
+	return func(config *Ultra) {
+		config.image = &stability.FilePart{Data: data}
+	}
+}
+
+// WithImageReader sets a starting image, streamed from r. r is read once, so the
+// instance can only generate once.
+func WithImageReader(r io.Reader) Option {
+	return func(config *Ultra) {
+		config.image = &stability.FilePart{Reader: r}
+	}
+}
+
+// WithStrength sets how much the starting image is changed, from 0 (an identical image)
+// to 1 (the image is ignored). It is required with an image.
+func WithStrength(strength float64) Option {
+	return func(config *Ultra) {
+		config.strength = &strength
+	}
+}
+
+// WithOutputFormat sets the output format for the Ultra instance.
+func WithOutputFormat(outputFormat string) Option {
+	return func(config *Ultra) {
+		config.outputFormat = &outputFormat
+	}
+}
+
+// SetPrompt sets the prompt for the Ultra instance.
+func (c *Ultra) SetPrompt(prompt string) {
+	c.prompt = &prompt
+}
+
+// SetNegativePrompt sets the negative prompt for the Ultra instance.
+func (c *Ultra) SetNegativePrompt(negativePrompt string) {
+	c.negativePrompt = &negativePrompt
+}
+
+// SetAspectRatio sets the aspect ratio for the Ultra instance.
+func (c *Ultra) SetAspectRatio(aspectRatio string) {
+	c.aspectRatio = &aspectRatio
+}
+
+// SetSeed sets the seed for the Ultra instance.
+func (c *Ultra) SetSeed(seed int) {
+	c.seed = &seed
+}
+
+// SetImage sets the starting image. A nil image generates from the prompt alone.
+func (c *Ultra) SetImage(image *stability.FilePart) {
+	c.image = image
+}
+
+// SetStrength sets the strength applied to the starting image.
+func (c *Ultra) SetStrength(strength float64) {
+	c.strength = &strength
+}
+
+// SetOutputFormat sets the output format for the Ultra instance.
+func (c *Ultra) SetOutputFormat(outputFormat string) {
+	c.outputFormat = &outputFormat
+}
+
+// Generate generates an image using the Ultra instance.
+func (c *Ultra) Generate() (*generate.Response, error) {
+	// validate prompt
+	if c.prompt == nil {
+		return nil, &generate.ErrMissingPrompt{}
+	}
+
+	// apply defaults without changing the configuration, so it can be reused
+	request := &Request{
+		Prompt:         *c.prompt,
+		AspectRatio:    generate.DEFAULT_ASPECT_RATIO,
+		NegativePrompt: c.negativePrompt,
+		Seed:           generate.DEFAULT_SEED,
+		Image:          c.image,
+		Strength:       c.strength,
+		OutputFormat:   generate.DEFAULT_OUTPUT_FORMAT,
+	}
+	if c.aspectRatio != nil {
+		request.AspectRatio = *c.aspectRatio
+	}
+	if c.seed != nil {
+		request.Seed = *c.seed
+	}
+	if c.outputFormat != nil {
+		request.OutputFormat = *c.outputFormat
+	}
+
+	// validate the request, reporting every invalid field
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	form := c.stability.NewRequest(stability.METHOD_POST, PATH)
+	form.AddFormPart("prompt", request.Prompt)
+	if request.NegativePrompt != nil {
+		form.AddFormPart("negative_prompt", *request.NegativePrompt)
+	}
+	form.AddFormPart("aspect_ratio", request.AspectRatio)
+	form.AddFormPart("seed", request.Seed)
+	if request.Image != nil {
+		form.AddFormPart("image", request.Image)
+		form.AddFormPart("strength", *request.Strength)
+	}
+	form.AddFormPart("output_format", request.OutputFormat)
+	form.AddHeader("accept", "application/json")
+
+	return generate.Send(c.stability, c.log, form, &generate.SendOptions{
+		Model:        MODEL,
+		Credits:      generate.CREDITS[MODEL],
+		OutputFormat: request.OutputFormat,
+		Seed:         request.Seed,
+	})
+}
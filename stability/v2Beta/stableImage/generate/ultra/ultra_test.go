@@ -0,0 +1,86 @@
+package ultra_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/rmrfslashbin/ami/stability/stabilitytest"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate/ultra"
+)
+
+var log = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// pngImage returns a small PNG.
+func pngImage(t *testing.T) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("encoding PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func newUltra(t *testing.T, opts ...func(*ultra.Ultra)) (*stabilitytest.Server, *ultra.Ultra) {
+	t.Helper()
+	server, err := stabilitytest.New()
+	if err != nil {
+		t.Fatalf("stabilitytest.New: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	s, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	opts = append([]func(*ultra.Ultra){ultra.WithStability(s), ultra.WithLogger(log)}, opts...)
+	u, err := ultra.New(opts...)
+	if err != nil {
+		t.Fatalf("ultra.New: %v", err)
+	}
+	return server, u
+}
+
+func TestGenerateWithImage(t *testing.T) {
+	img := pngImage(t)
+	server, u := newUltra(t, ultra.WithPrompt("a lighthouse"), ultra.WithImageBytes(img), ultra.WithStrength(0.4))
+
+	res, err := u.Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if res.Image == nil || *res.Image == "" {
+		t.Errorf("Generate = %+v, want an image", res)
+	}
+
+	received := server.LastRequest()
+	if received.Path != ultra.PATH || received.Form["strength"][0] != "0.4" {
+		t.Errorf("received %s %v", received.Path, received.Form)
+	}
+	if files := received.Files["image"]; len(files) != 1 || files[0].Size != int64(len(img)) {
+		t.Errorf("image files = %+v", files)
+	}
+	if want := stabilitytest.DEFAULT_CREDITS - generate.CREDITS[ultra.MODEL]; server.Credits() != want {
+		t.Errorf("Credits = %g, want %g", server.Credits(), want)
+	}
+	if err := server.Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestGenerateImageWithoutStrength(t *testing.T) {
+	server, u := newUltra(t, ultra.WithPrompt("a lighthouse"), ultra.WithImageBytes(pngImage(t)))
+
+	var strength *generate.ErrInvalidStrength
+	if _, err := u.Generate(); !errors.As(err, &strength) {
+		t.Errorf("Generate = %v, want ErrInvalidStrength", err)
+	}
+	if n := len(server.Requests()); n != 0 {
+		t.Errorf("%d requests sent for an invalid generation", n)
+	}
+}
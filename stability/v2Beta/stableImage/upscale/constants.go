@@ -0,0 +1,34 @@
+package upscale
+
+// Paths of the upscale endpoints
+const (
+	PATH_CONSERVATIVE = "/v2beta/stable-image/upscale/conservative"
+	PATH_FAST         = "/v2beta/stable-image/upscale/fast"
+	PATH_CREATIVE     = "/v2beta/stable-image/upscale/creative"
+)
+
+// CREDITS is the price of a successful upscale per operation.
+var CREDITS = map[string]float64{
+	"conservative": 25,
+	"fast":         1,
+	"creative":     25,
+}
+
+// OUTPUT_FORMATS is a list of valid output formats for upscale endpoints
+var OUTPUT_FORMATS = []string{"jpeg", "png", "webp"}
+
+// MAX_PROMPT_LENGTH is the maximum length of a prompt
+const MAX_PROMPT_LENGTH = 10000
+
+// MAX_SEED is the maximum seed value
+const MAX_SEED = 4294967294
+
+// MIN_CONSERVATIVE_CREATIVITY and MAX_CONSERVATIVE_CREATIVITY bound the creativity of a
+// conservative upscale
+const MIN_CONSERVATIVE_CREATIVITY = 0.2
+const MAX_CONSERVATIVE_CREATIVITY = 0.5
+
+// MAX_CREATIVE_CREATIVITY bounds the creativity of a creative upscale
+const MAX_CREATIVE_CREATIVITY = 0.35
+
+const DEFAULT_OUTPUT_FORMAT = "png"
@@ -0,0 +1,152 @@
+package upscale
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate/core"
+)
+
+type ErrMissingStability struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingStability) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing stability- use WithStability to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrMissingLogger struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingLogger) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing logger- use WithLogger to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrMissingImage struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrMissingImage) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing image- use WithImage or SetImage to set it"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidPromptLength struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidPromptLength) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid prompt- use WithPrompt or SetPrompt to set it. Must be between 1 and %d characters", MAX_PROMPT_LENGTH)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidNegativePromptLength struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidNegativePromptLength) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid negative prompt- use WithNegativePrompt or SetNegativePrompt to set it. If set, the prompt must be between 1 and %d characters", MAX_PROMPT_LENGTH)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidCreativity struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidCreativity) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid creativity- use WithCreativity or SetCreativity to set it. Must be between %g and %g for conservative and at most %g for creative upscales", MIN_CONSERVATIVE_CREATIVITY, MAX_CONSERVATIVE_CREATIVITY, MAX_CREATIVE_CREATIVITY)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidStylePreset struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidStylePreset) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid style preset- use WithStylePreset or SetStylePreset to set it. Must be one of " + strings.Join(core.STYLE_PRESETS, ", ")
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidSeed struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidSeed) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = fmt.Sprintf("invalid seed- use WithSeed or SetSeed to set it. Must be between 0 and %d", MAX_SEED)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+type ErrInvalidOutputFormat struct {
+	Err error
+	Msg string
+}
+
+func (e *ErrInvalidOutputFormat) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid output format- use WithOutputFormat or SetOutputFormat to set it. Must be one of " + strings.Join(OUTPUT_FORMATS, ", ")
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
@@ -0,0 +1,63 @@
+package upscale
+
+import (
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/validate"
+)
+
+// ConservativeRequest holds the form fields of a conservative upscale. The tags are
+// checked by validate.Struct.
+type ConservativeRequest struct {
+	Image          *stability.FilePart `json:"image" required:"true"`
+	Prompt         string              `json:"prompt" required:"true" min:"1" max:"10000"`
+	NegativePrompt *string             `json:"negative_prompt" min:"1" max:"10000"`
+	Creativity     *float64            `json:"creativity" min:"0.2" max:"0.5"`
+	Seed           *int                `json:"seed" min:"0" max:"4294967294"`
+	OutputFormat   string              `json:"output_format" enum:"@stability.upscale.output_formats"`
+}
+
+// FastRequest holds the form fields of a fast upscale.
+type FastRequest struct {
+	Image        *stability.FilePart `json:"image" required:"true"`
+	OutputFormat string              `json:"output_format" enum:"@stability.upscale.output_formats"`
+}
+
+// CreativeRequest holds the form fields of a creative upscale.
+type CreativeRequest struct {
+	Image          *stability.FilePart `json:"image" required:"true"`
+	Prompt         string              `json:"prompt" required:"true" min:"1" max:"10000"`
+	NegativePrompt *string             `json:"negative_prompt" min:"1" max:"10000"`
+	Creativity     *float64            `json:"creativity" min:"0" max:"0.35"`
+	StylePreset    *string             `json:"style_preset" enum:"@stability.style_presets"`
+	Seed           *int                `json:"seed" min:"0" max:"4294967294"`
+	OutputFormat   string              `json:"output_format" enum:"@stability.upscale.output_formats"`
+}
+
+// Validate checks the request against its tags and maps every violation onto this
+// package's typed errors.
+func (r *ConservativeRequest) Validate() error {
+	return validate.StructErrors(r, fieldErrors)
+}
+
+// Validate checks the request against its tags and maps every violation onto this
+// package's typed errors.
+func (r *FastRequest) Validate() error {
+	return validate.StructErrors(r, fieldErrors)
+}
+
+// Validate checks the request against its tags and maps every violation onto this
+// package's typed errors.
+func (r *CreativeRequest) Validate() error {
+	return validate.StructErrors(r, fieldErrors)
+}
+
+// fieldErrors maps request fields to this package's typed errors.
+var fieldErrors = validate.ErrorMap{
+	"image":           func(err error) error { return &ErrMissingImage{Err: err} },
+	"prompt":          func(err error) error { return &ErrInvalidPromptLength{Err: err} },
+	"negative_prompt": func(err error) error { return &ErrInvalidNegativePromptLength{Err: err} },
+	"creativity":      func(err error) error { return &ErrInvalidCreativity{Err: err} },
+	"style_preset":    func(err error) error { return &ErrInvalidStylePreset{Err: err} },
+	"seed":            func(err error) error { return &ErrInvalidSeed{Err: err} },
+	"output_format":   func(err error) error { return &ErrInvalidOutputFormat{Err: err} },
+}
@@ -0,0 +1,371 @@
+package upscale
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/rmrfslashbin/ami/instrument"
+	"github.com/rmrfslashbin/ami/stability"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/results"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/generate"
+	"github.com/rmrfslashbin/ami/validate"
+)
+
+//https://platform.stability.ai/docs/api-reference#tag/Upscale
+
+/*
+Method: POST
+Headers:
+- authorization: Bearer ${API_KEY}
+- content-type: multipart/form-data
+- accept: image/* -- OR -- application/json to receive image as base64 string
+
+Body: form-data, every operation requires an image (file)
+- conservative: prompt, negative_prompt, creativity ([0.2 .. 0.5]), seed, output_format. Up to 4K, synchronous.
+- fast: output_format. 4x, synchronous.
+- creative: prompt, negative_prompt, creativity ([0 .. 0.35]), style_preset, seed, output_format.
+  Up to 4K, asynchronous: returns {"id"}, fetched from /v2beta/results/{id}.
+
+Credits: see CREDITS. You will not be charged for failed upscales.
+*/
+
+// MODULE_NAME is the module name
+const MODULE_NAME = "upscale"
+
+// Option is a function that takes a pointer to a Config struct and sets a value.
+type Option func(config *Upscale)
+
+// Configuration structure. The same configuration serves every operation; each operation
+// sends only the fields it accepts.
+type Upscale struct {
+	log            *slog.Logger
+	stability      *stability.Stability
+	image          *stability.FilePart
+	prompt         *string
+	negativePrompt *string
+	creativity     *float64
+	stylePreset    *string
+	seed           *int
+	outputFormat   *string
+	pollInterval   time.Duration
+	pollTimeout    time.Duration
+}
+
+func init() {
+	validate.RegisterEnum("stability.upscale.output_formats", OUTPUT_FORMATS)
+}
+
+// New creates a new Upscale instance.
+func New(opts ...func(*Upscale)) (*Upscale, error) {
+	config := &Upscale{
+		pollInterval: results.DEFAULT_INTERVAL,
+		pollTimeout:  results.DEFAULT_TIMEOUT,
+	}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.stability == nil {
+		return nil, &ErrMissingStability{}
+	}
+
+	if config.log == nil {
+		return nil, &ErrMissingLogger{}
+	}
+
+	return config, nil
+}
+
+// WithLogger sets the logger for the Upscale instance.
+func WithLogger(log *slog.Logger) Option {
+	return func(config *Upscale) {
+		moduleLogger := log.With(
+			slog.Group("module_info",
+				slog.String("module", MODULE_NAME),
+			),
+		)
+		config.log = moduleLogger
+	}
+}
+
+// WithStability sets the stability instance for the Upscale instance.
+func WithStability(stability *stability.Stability) Option {
+	return func(config *Upscale) {
+		config.stability = stability
+	}
+}
+
+// WithImage sets the image to upscale, read from a path.
+func WithImage(path string) Option {
+	return func(config *Upscale) {
+		config.image = &stability.FilePart{Path: path}
+	}
+}
+
+// WithImageBytes sets the image to upscale.
+func WithImageBytes(data []byte) Option {
+	return func(config *Upscale) {
+		config.image = &stability.FilePart{Data: data}
+	}
+}
+
+// WithImageReader sets the image to upscale, streamed from r. r is read once, so the
+// instance can only upscale once.
+func WithImageReader(r io.Reader) Option {
+	return func(config *Upscale) {
+		config.image = &stability.FilePart{Reader: r}
+	}
+}
+
+// WithPrompt sets the prompt for the Upscale instance.
+func WithPrompt(prompt string) Option {
+	return func(config *Upscale) {
+		config.prompt = &prompt
+	}
+}
+
+// WithNegativePrompt sets the negative prompt for the Upscale instance.
+func WithNegativePrompt(negativePrompt string) Option {
+	return func(config *Upscale) {
+		config.negativePrompt = &negativePrompt
+	}
+}
+
+// WithCreativity sets how much detail conservative and creative upscales add.
+func WithCreativity(creativity float64) Option {
+	return func(config *Upscale) {
+		config.creativity = &creativity
+	}
+}
+
+// WithStylePreset guides a creative upscale towards a style, one of core.STYLE_PRESETS.
+func WithStylePreset(stylePreset string) Option {
+	return func(config *Upscale) {
+		config.stylePreset = &stylePreset
+	}
+}
+
+// WithSeed sets the seed for the Upscale instance.
+func WithSeed(seed int) Option {
+	return func(config *Upscale) {
+		config.seed = &seed
+	}
+}
+
+// WithOutputFormat sets the output format for the Upscale instance.
+func WithOutputFormat(outputFormat string) Option {
+	return func(config *Upscale) {
+		config.outputFormat = &outputFormat
+	}
+}
+
+// WithPollInterval sets the time between polls for creative upscale results. The default
+// is results.DEFAULT_INTERVAL.
+func WithPollInterval(interval time.Duration) Option {
+	return func(config *Upscale) {
+		config.pollInterval = interval
+	}
+}
+
+// WithPollTimeout sets how long Creative waits for its result. The default is
+// results.DEFAULT_TIMEOUT.
+func WithPollTimeout(timeout time.Duration) Option {
+	return func(config *Upscale) {
+		config.pollTimeout = timeout
+	}
+}
+
+// SetImage sets the image to upscale.
+func (c *Upscale) SetImage(image *stability.FilePart) {
+	c.image = image
+}
+
+// SetPrompt sets the prompt for the Upscale instance.
+func (c *Upscale) SetPrompt(prompt string) {
+	c.prompt = &prompt
+}
+
+// SetNegativePrompt sets the negative prompt for the Upscale instance.
+func (c *Upscale) SetNegativePrompt(negativePrompt string) {
+	c.negativePrompt = &negativePrompt
+}
+
+// SetCreativity sets how much detail conservative and creative upscales add.
+func (c *Upscale) SetCreativity(creativity float64) {
+	c.creativity = &creativity
+}
+
+// SetStylePreset sets the style preset of creative upscales.
+func (c *Upscale) SetStylePreset(stylePreset string) {
+	c.stylePreset = &stylePreset
+}
+
+// SetSeed sets the seed for the Upscale instance.
+func (c *Upscale) SetSeed(seed int) {
+	c.seed = &seed
+}
+
+// SetOutputFormat sets the output format for the Upscale instance.
+func (c *Upscale) SetOutputFormat(outputFormat string) {
+	c.outputFormat = &outputFormat
+}
+
+// Conservative upscales to up to 4K, preserving the image.
+func (c *Upscale) Conservative() (*generate.Response, error) {
+	request := &ConservativeRequest{
+		Image:          c.image,
+		Prompt:         value(c.prompt),
+		NegativePrompt: c.negativePrompt,
+		Creativity:     c.creativity,
+		Seed:           c.seed,
+		OutputFormat:   c.format(),
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	form := c.stability.NewRequest(stability.METHOD_POST, PATH_CONSERVATIVE)
+	form.AddFormPart("image", request.Image)
+	form.AddFormPart("prompt", request.Prompt)
+	addField(form, "negative_prompt", request.NegativePrompt)
+	addField(form, "creativity", request.Creativity)
+	addField(form, "seed", request.Seed)
+	form.AddFormPart("output_format", request.OutputFormat)
+	form.AddHeader("accept", "application/json")
+
+	return generate.Send(c.stability, c.log, form, &generate.SendOptions{
+		Model:        "conservative",
+		Credits:      CREDITS["conservative"],
+		OutputFormat: request.OutputFormat,
+		Seed:         value(request.Seed),
+	})
+}
+
+// Fast upscales 4x, in about a second.
+func (c *Upscale) Fast() (*generate.Response, error) {
+	request := &FastRequest{
+		Image:        c.image,
+		OutputFormat: c.format(),
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	form := c.stability.NewRequest(stability.METHOD_POST, PATH_FAST)
+	form.AddFormPart("image", request.Image)
+	form.AddFormPart("output_format", request.OutputFormat)
+	form.AddHeader("accept", "application/json")
+
+	return generate.Send(c.stability, c.log, form, &generate.SendOptions{
+		Model:        "fast",
+		Credits:      CREDITS["fast"],
+		OutputFormat: request.OutputFormat,
+	})
+}
+
+// Creative upscales to up to 4K, reimagining degraded images, and waits for the result.
+func (c *Upscale) Creative(ctx context.Context) (*generate.Response, error) {
+	id, err := c.StartCreative(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.Result(ctx, id)
+}
+
+// StartCreative starts a creative upscale and returns its generation id. Fetch the image
+// with Result.
+func (c *Upscale) StartCreative(ctx context.Context) (string, error) {
+	request := &CreativeRequest{
+		Image:          c.image,
+		Prompt:         value(c.prompt),
+		NegativePrompt: c.negativePrompt,
+		Creativity:     c.creativity,
+		StylePreset:    c.stylePreset,
+		Seed:           c.seed,
+		OutputFormat:   c.format(),
+	}
+	if err := request.Validate(); err != nil {
+		return "", err
+	}
+
+	form := c.stability.NewRequest(stability.METHOD_POST, PATH_CREATIVE)
+	form.AddFormPart("image", request.Image)
+	form.AddFormPart("prompt", request.Prompt)
+	addField(form, "negative_prompt", request.NegativePrompt)
+	addField(form, "creativity", request.Creativity)
+	addField(form, "style_preset", request.StylePreset)
+	addField(form, "seed", request.Seed)
+	form.AddFormPart("output_format", request.OutputFormat)
+
+	res, err := c.stability.DoContext(ctx, form)
+	if err != nil {
+		return "", err
+	}
+	id, err := results.DecodeId(res)
+	if err != nil {
+		return "", err
+	}
+
+	c.log.Info("creative upscale started", slog.String("id", id))
+	return id, nil
+}
+
+// Result waits for the image of a creative upscale started with StartCreative.
+func (c *Upscale) Result(ctx context.Context, id string) (*generate.Response, error) {
+	poller, err := results.New(
+		results.WithStability(c.stability),
+		results.WithLogger(c.log),
+		results.WithInterval(c.pollInterval),
+		results.WithTimeout(c.pollTimeout),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := poller.Wait(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	response, err := generate.Decode(res, c.stability.URL(results.PATH+id))
+	if err != nil {
+		return response, err
+	}
+
+	attrs := []any{slog.String("id", id), slog.String("model", "creative")}
+	if response.FinishReason != nil {
+		attrs = append(attrs, slog.String("finish_reason", *response.FinishReason))
+	}
+	c.log.Info("image generated", attrs...)
+
+	c.stability.GetInstrumentation().Add(ctx, instrument.METRIC_CREDITS, CREDITS["creative"],
+		instrument.String(instrument.ATTR_STABILITY_IMAGE_MODEL, "creative"))
+	return response, nil
+}
+
+// format returns the output format, or the default.
+func (c *Upscale) format() string {
+	if c.outputFormat == nil {
+		return DEFAULT_OUTPUT_FORMAT
+	}
+	return *c.outputFormat
+}
+
+// addField appends a form field if it is set.
+func addField[T any](form *stability.Request, key string, field *T) {
+	if field != nil {
+		form.AddFormPart(key, *field)
+	}
+}
+
+// value dereferences an optional field, returning the zero value if it is unset.
+func value[T any](field *T) T {
+	var zero T
+	if field == nil {
+		return zero
+	}
+	return *field
+}
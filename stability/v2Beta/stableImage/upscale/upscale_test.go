@@ -0,0 +1,137 @@
+package upscale_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/rmrfslashbin/ami/stability/stabilitytest"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/results"
+	"github.com/rmrfslashbin/ami/stability/v2Beta/stableImage/upscale"
+)
+
+var log = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// pngImage returns a small PNG.
+func pngImage(t *testing.T) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("encoding PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func newUpscale(t *testing.T, server *stabilitytest.Server, opts ...func(*upscale.Upscale)) *upscale.Upscale {
+	t.Helper()
+	s, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	opts = append([]func(*upscale.Upscale){
+		upscale.WithStability(s),
+		upscale.WithLogger(log),
+		upscale.WithImageBytes(pngImage(t)),
+		upscale.WithPollInterval(time.Millisecond),
+	}, opts...)
+	u, err := upscale.New(opts...)
+	if err != nil {
+		t.Fatalf("upscale.New: %v", err)
+	}
+	return u
+}
+
+func newServer(t *testing.T, opts ...func(*stabilitytest.Server)) *stabilitytest.Server {
+	t.Helper()
+	server, err := stabilitytest.New(opts...)
+	if err != nil {
+		t.Fatalf("stabilitytest.New: %v", err)
+	}
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestConservativeAndFast(t *testing.T) {
+	server := newServer(t)
+	u := newUpscale(t, server, upscale.WithPrompt("a sharp cat"), upscale.WithCreativity(0.3))
+
+	if res, err := u.Conservative(); err != nil || res.Image == nil {
+		t.Fatalf("Conservative = %+v, %v", res, err)
+	}
+	if path := server.LastRequest().Path; path != upscale.PATH_CONSERVATIVE {
+		t.Errorf("Conservative sent to %s", path)
+	}
+
+	// fast takes no prompt; the configured one is not sent
+	if res, err := u.Fast(); err != nil || res.Image == nil {
+		t.Fatalf("Fast = %+v, %v", res, err)
+	}
+	if received := server.LastRequest(); received.Path != upscale.PATH_FAST || len(received.Form["prompt"]) != 0 {
+		t.Errorf("Fast sent %s %v", received.Path, received.Form)
+	}
+
+	if want := stabilitytest.DEFAULT_CREDITS - upscale.CREDITS["conservative"] - upscale.CREDITS["fast"]; server.Credits() != want {
+		t.Errorf("Credits = %g, want %g", server.Credits(), want)
+	}
+	if err := server.Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestCreative(t *testing.T) {
+	server := newServer(t, stabilitytest.WithPendingPolls(2))
+	u := newUpscale(t, server, upscale.WithPrompt("a sharp cat"), upscale.WithStylePreset("anime"))
+
+	res, err := u.Creative(context.Background())
+	if err != nil {
+		t.Fatalf("Creative: %v", err)
+	}
+	if res.Image == nil || *res.Image == "" {
+		t.Errorf("Creative = %+v, want an image", res)
+	}
+
+	// the start request, two pending polls and the result
+	requests := server.Requests()
+	if len(requests) != 4 || requests[0].Path != upscale.PATH_CREATIVE || requests[3].Method != "GET" {
+		t.Errorf("server received %d requests", len(requests))
+	}
+	if want := stabilitytest.DEFAULT_CREDITS - upscale.CREDITS["creative"]; server.Credits() != want {
+		t.Errorf("Credits = %g, want %g", server.Credits(), want)
+	}
+	if err := server.Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestCreativeFailed(t *testing.T) {
+	server := newServer(t)
+	server.Enqueue(stabilitytest.Moderated())
+	u := newUpscale(t, server, upscale.WithPrompt("a sharp cat"))
+
+	var failed *results.ErrFailed
+	if _, err := u.StartCreative(context.Background()); !errors.As(err, &failed) || failed.Name != "content_moderation" {
+		t.Errorf("StartCreative = %v, want ErrFailed content_moderation", err)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	server := newServer(t)
+
+	var creativity *upscale.ErrInvalidCreativity
+	if _, err := newUpscale(t, server, upscale.WithPrompt("a cat"), upscale.WithCreativity(0.1)).Conservative(); !errors.As(err, &creativity) {
+		t.Errorf("Conservative = %v, want ErrInvalidCreativity", err)
+	}
+	var prompt *upscale.ErrInvalidPromptLength
+	if _, err := newUpscale(t, server).StartCreative(context.Background()); !errors.As(err, &prompt) {
+		t.Errorf("StartCreative = %v, want ErrInvalidPromptLength", err)
+	}
+	if n := len(server.Requests()); n != 0 {
+		t.Errorf("%d requests sent for invalid upscales", n)
+	}
+}
@@ -0,0 +1,28 @@
+package validate
+
+import "errors"
+
+// ErrorMap maps field paths to constructors of a package's own typed errors, e.g.
+// "seed": func(err error) error { return &ErrInvalidSeed{Err: err} }.
+type ErrorMap map[string]func(err error) error
+
+// StructErrors validates v like Struct and converts every violation with the constructor
+// registered for its field in errs. Violations of other fields are returned unchanged.
+// extra holds errors from checks tags cannot express and is returned first. All errors
+// are returned joined, or nil.
+func StructErrors(v interface{}, errs ErrorMap, extra ...error) error {
+	var violations ValidationErrors
+	if err := Struct(v); err != nil && !errors.As(err, &violations) {
+		return err
+	}
+
+	joined := append([]error{}, extra...)
+	for _, violation := range violations {
+		if constructor, ok := errs[violation.Field]; ok {
+			joined = append(joined, constructor(violation))
+		} else {
+			joined = append(joined, violation)
+		}
+	}
+	return errors.Join(joined...)
+}
@@ -0,0 +1,84 @@
+package validate
+
+import "strings"
+
+// ValidationError is a single violation of a validation rule.
+type ValidationError struct {
+	// Field is the path to the field, using JSON names where available, e.g. messages[1].content[0].type.
+	Field string
+
+	// Rule is the tag that was violated, e.g. required, enum or max.
+	Rule string
+
+	// Message describes the violation.
+	Message string
+
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	msg := e.Field + " " + e.Message
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors is the list of every violation found in a value.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap lets errors.As and errors.Is inspect the individual violations.
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Fields returns the violations for a single field path.
+func (e ValidationErrors) Fields(field string) ValidationErrors {
+	found := ValidationErrors{}
+	for _, err := range e {
+		if err.Field == field {
+			found = append(found, err)
+		}
+	}
+	return found
+}
+
+type ErrInvalidTag struct {
+	Err   error
+	Msg   string
+	Field string
+	Tag   string
+}
+
+func (e *ErrInvalidTag) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "invalid validation tag"
+	}
+	if e.Tag != "" {
+		msg += " " + e.Tag
+	}
+	if e.Field != "" {
+		msg += " on " + e.Field
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
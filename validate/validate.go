@@ -0,0 +1,338 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+/*
+Struct tags understood by the validator:
+
+	required:"true"         the field must not be the zero value
+	require_if:"Field=val"  the field is required when the sibling Field (Go or JSON name) equals val
+	enum:"a,b,c"            the value must be one of the listed values
+	enum:"@name"            the value must be one of the values registered with RegisterEnum
+	min:"n"                 minimum value for numbers, minimum length for strings, slices and maps
+	max:"n"                 maximum value for numbers, maximum length for strings, slices and maps
+	len:"n"                 exact length for strings, slices and maps
+	regex:"expr"            strings must match the regular expression
+
+Nil pointers are only checked for required and require_if. Every other rule applies to
+the value the pointer points to. Nested structs, pointers to structs and slices of them
+are validated recursively.
+*/
+
+// Validater is an interface for structs with checks that cannot be expressed as tags.
+type Validater interface {
+	Validate() error
+}
+
+var (
+	enumsMu sync.RWMutex
+	enums   = map[string][]string{}
+
+	regexMu sync.Mutex
+	regexes = map[string]*regexp.Regexp{}
+)
+
+// RegisterEnum registers a named set of values for use as enum:"@name". This lets
+// packages keep their allowed values in a single exported slice.
+func RegisterEnum(name string, values []string) {
+	enumsMu.Lock()
+	defer enumsMu.Unlock()
+	enums[name] = values
+}
+
+// Struct validates v, a struct or pointer to a struct, against its field tags and returns
+// every violation found as ValidationErrors, or nil.
+func Struct(v interface{}) error {
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Pointer || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+
+	w := &walker{visited: map[uintptr]struct{}{}}
+	w.walkStruct(val, "")
+	if len(w.errs) == 0 {
+		return nil
+	}
+	return w.errs
+}
+
+// Validate validates v's tags and then calls its Validate method, returning all
+// violations together.
+func Validate(v Validater) error {
+	errs := ValidationErrors{}
+
+	var tagErrs ValidationErrors
+	if err := Struct(v); errors.As(err, &tagErrs) {
+		errs = append(errs, tagErrs...)
+	}
+
+	if err := v.Validate(); err != nil {
+		var custom ValidationErrors
+		var single *ValidationError
+		switch {
+		case errors.As(err, &custom):
+			errs = append(errs, custom...)
+		case errors.As(err, &single):
+			errs = append(errs, single)
+		default:
+			if len(errs) == 0 {
+				return err
+			}
+			errs = append(errs, &ValidationError{Message: "is invalid", Err: err})
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// walker carries the state of a single Struct call.
+type walker struct {
+	errs ValidationErrors
+
+	// visited are the pointers on the path being walked, so a pointer shared by two
+	// fields is checked under both while a cycle is not followed.
+	visited map[uintptr]struct{}
+}
+
+// fail records a violation.
+func (w *walker) fail(field string, rule string, format string, args ...interface{}) {
+	w.errs = append(w.errs, &ValidationError{Field: field, Rule: rule, Message: fmt.Sprintf(format, args...)})
+}
+
+// walkStruct checks the fields of a struct value.
+func (w *walker) walkStruct(val reflect.Value, path string) {
+	typ := val.Type()
+	for i := 0; i < val.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := fieldName(field)
+		if path != "" {
+			name = path + "." + name
+		}
+
+		w.checkField(val, field, val.Field(i), name)
+		w.walkValue(val.Field(i), name)
+	}
+}
+
+// walkValue descends into nested structs, pointers and slices.
+func (w *walker) walkValue(val reflect.Value, path string) {
+	switch val.Kind() {
+	case reflect.Pointer:
+		if val.IsNil() {
+			return
+		}
+		// guard against cycles
+		ptr := val.Pointer()
+		if _, ok := w.visited[ptr]; ok {
+			return
+		}
+		w.visited[ptr] = struct{}{}
+		w.walkValue(val.Elem(), path)
+		delete(w.visited, ptr)
+	case reflect.Interface:
+		if !val.IsNil() {
+			w.walkValue(val.Elem(), path)
+		}
+	case reflect.Struct:
+		w.walkStruct(val, path)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			w.walkValue(val.Index(i), fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+// checkField applies the tags of a single field.
+func (w *walker) checkField(parent reflect.Value, field reflect.StructField, val reflect.Value, path string) {
+	tag := field.Tag
+
+	if tag.Get("required") == "true" && val.IsZero() {
+		w.fail(path, "required", "is required")
+		return
+	}
+
+	if requireIf := tag.Get("require_if"); requireIf != "" {
+		otherName, otherValue, ok := strings.Cut(requireIf, "=")
+		if !ok {
+			w.invalidTag(path, "require_if", requireIf)
+		} else if other := siblingField(parent, otherName); other.IsValid() {
+			if stringify(indirect(other)) == otherValue && val.IsZero() {
+				w.fail(path, "require_if", "is required when %s is %s", otherName, otherValue)
+				return
+			}
+		} else {
+			w.invalidTag(path, "require_if", requireIf)
+		}
+	}
+
+	// optional fields are only checked when set
+	val = indirect(val)
+	if !val.IsValid() {
+		return
+	}
+
+	if enumTag := tag.Get("enum"); enumTag != "" && !val.IsZero() {
+		values := enumValues(enumTag)
+		if values == nil {
+			w.invalidTag(path, "enum", enumTag)
+		} else if !slices.Contains(values, stringify(val)) {
+			w.fail(path, "enum", "must be one of %s", strings.Join(values, ", "))
+		}
+	}
+
+	for _, rule := range []string{"min", "max", "len"} {
+		ruleTag := tag.Get(rule)
+		if ruleTag == "" {
+			continue
+		}
+		limit, err := strconv.ParseFloat(ruleTag, 64)
+		if err != nil {
+			w.invalidTag(path, rule, ruleTag)
+			continue
+		}
+		w.checkBound(val, rule, limit, path)
+	}
+
+	if regexTag := tag.Get("regex"); regexTag != "" && val.Kind() == reflect.String && !val.IsZero() {
+		re, err := compile(regexTag)
+		if err != nil {
+			w.invalidTag(path, "regex", regexTag)
+		} else if !re.MatchString(val.String()) {
+			w.fail(path, "regex", "must match %s", regexTag)
+		}
+	}
+}
+
+// checkBound applies a min, max or len rule.
+func (w *walker) checkBound(val reflect.Value, rule string, limit float64, path string) {
+	var n float64
+	var what string
+
+	switch val.Kind() {
+	case reflect.String:
+		n, what = float64(utf8.RuneCountInString(val.String())), "length"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, what = float64(val.Len()), "length"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, what = float64(val.Int()), "value"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, what = float64(val.Uint()), "value"
+	case reflect.Float32, reflect.Float64:
+		n, what = val.Float(), "value"
+	default:
+		return
+	}
+
+	limitText := strconv.FormatFloat(limit, 'f', -1, 64)
+	switch {
+	case rule == "min" && n < limit:
+		w.fail(path, rule, "%s must be at least %s", what, limitText)
+	case rule == "max" && n > limit:
+		w.fail(path, rule, "%s must be at most %s", what, limitText)
+	case rule == "len" && n != limit:
+		w.fail(path, rule, "%s must be exactly %s", what, limitText)
+	}
+}
+
+// invalidTag records a malformed tag.
+func (w *walker) invalidTag(path string, rule string, tag string) {
+	w.errs = append(w.errs, &ValidationError{
+		Field:   path,
+		Rule:    rule,
+		Message: "has an invalid validation tag",
+		Err:     &ErrInvalidTag{Field: path, Tag: rule + ":" + strconv.Quote(tag)},
+	})
+}
+
+// fieldName returns the JSON name of a field, falling back to the Go name.
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// siblingField finds a field of parent by Go name or JSON name.
+func siblingField(parent reflect.Value, name string) reflect.Value {
+	if field := parent.FieldByName(name); field.IsValid() {
+		return field
+	}
+	typ := parent.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).IsExported() && fieldName(typ.Field(i)) == name {
+			return parent.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// indirect dereferences pointers and interfaces, returning the zero Value for nil.
+func indirect(val reflect.Value) reflect.Value {
+	for val.Kind() == reflect.Pointer || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return reflect.Value{}
+		}
+		val = val.Elem()
+	}
+	return val
+}
+
+// stringify formats a value for enum and require_if comparisons.
+func stringify(val reflect.Value) string {
+	if !val.IsValid() {
+		return ""
+	}
+	if val.Kind() == reflect.String {
+		return val.String()
+	}
+	return fmt.Sprint(val.Interface())
+}
+
+// enumValues resolves an enum tag to its list of values.
+func enumValues(tag string) []string {
+	if name, ok := strings.CutPrefix(tag, "@"); ok {
+		enumsMu.RLock()
+		defer enumsMu.RUnlock()
+		return enums[name]
+	}
+	return strings.Split(tag, ",")
+}
+
+// compile compiles and caches a regular expression.
+func compile(expr string) (*regexp.Regexp, error) {
+	regexMu.Lock()
+	defer regexMu.Unlock()
+	if re, ok := regexes[expr]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexes[expr] = re
+	return re, nil
+}
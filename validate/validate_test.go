@@ -0,0 +1,315 @@
+package validate_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/ami/validate"
+)
+
+type item struct {
+	Name  string `json:"name" required:"true"`
+	Count int    `json:"count" min:"1" max:"3"`
+}
+
+type sample struct {
+	Name   string   `json:"name" required:"true" max:"5"`
+	Mode   string   `json:"mode" enum:"a,b"`
+	Format string   `json:"format" enum:"@validate_test.formats"`
+	Image  *string  `json:"image" require_if:"Mode=b"`
+	Code   string   `json:"code" len:"3"`
+	Slug   *string  `json:"slug" regex:"^[a-z]+$"`
+	Ratio  *float64 `json:"ratio" min:"0" max:"1"`
+	Items  []item   `json:"items" max:"2"`
+	Nested *item    `json:"nested"`
+	Plain  string
+}
+
+type badTag struct {
+	Count int    `json:"count" min:"one"`
+	Kind  string `json:"kind" enum:"@validate_test.unregistered"`
+	Image string `json:"image" require_if:"Mode"`
+}
+
+type custom struct {
+	Name string `json:"name" required:"true"`
+	err  error
+}
+
+func (c *custom) Validate() error {
+	return c.err
+}
+
+func init() {
+	validate.RegisterEnum("validate_test.formats", []string{"png", "jpeg"})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// valid returns a sample that passes every rule.
+func valid() *sample {
+	return &sample{
+		Name:   "ok",
+		Mode:   "a",
+		Format: "png",
+		Code:   "abc",
+		Items:  []item{{Name: "one", Count: 1}},
+	}
+}
+
+// violations returns the "field rule" pairs of a ValidationErrors.
+func violations(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var errs validate.ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got %T %v, want ValidationErrors", err, err)
+	}
+	found := []string{}
+	for _, violation := range errs {
+		// violations from a plain Validate error have no field or rule
+		rule := violation.Rule
+		if rule == "" {
+			rule = violation.Message
+		}
+		found = append(found, strings.TrimSpace(violation.Field+" "+rule))
+	}
+	return found
+}
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(s *sample)
+		want   []string
+	}{
+		{name: "valid", modify: func(s *sample) {}},
+		{name: "required missing", modify: func(s *sample) { s.Name = "" }, want: []string{"name required"}},
+		{name: "string too long", modify: func(s *sample) { s.Name = "toolong" }, want: []string{"name max"}},
+		{name: "string length counts runes", modify: func(s *sample) { s.Name = "ééééé" }},
+		{name: "enum list", modify: func(s *sample) { s.Mode = "c" }, want: []string{"mode enum"}},
+		{name: "enum empty is optional", modify: func(s *sample) { s.Mode = "" }},
+		{name: "enum registered", modify: func(s *sample) { s.Format = "gif" }, want: []string{"format enum"}},
+		{name: "require_if unmet", modify: func(s *sample) { s.Mode = "b" }, want: []string{"image require_if"}},
+		{name: "require_if met", modify: func(s *sample) { s.Mode = "b"; s.Image = ptr("cat.png") }},
+		{name: "len", modify: func(s *sample) { s.Code = "ab" }, want: []string{"code len"}},
+		{name: "regex mismatch", modify: func(s *sample) { s.Slug = ptr("Not A Slug") }, want: []string{"slug regex"}},
+		{name: "regex match", modify: func(s *sample) { s.Slug = ptr("slug") }},
+		{name: "regex skips empty optional string", modify: func(s *sample) { s.Slug = ptr("") }},
+		{name: "pointer below min", modify: func(s *sample) { s.Ratio = ptr(-0.5) }, want: []string{"ratio min"}},
+		{name: "pointer above max", modify: func(s *sample) { s.Ratio = ptr(1.5) }, want: []string{"ratio max"}},
+		{name: "pointer zero within bounds", modify: func(s *sample) { s.Ratio = ptr(0.0) }},
+		{name: "slice too long", modify: func(s *sample) {
+			s.Items = []item{{Name: "a", Count: 1}, {Name: "b", Count: 1}, {Name: "c", Count: 1}}
+		}, want: []string{"items max"}},
+		{name: "slice elements", modify: func(s *sample) {
+			s.Items = []item{{Name: "a", Count: 1}, {Count: 4}}
+		}, want: []string{"items[1].name required", "items[1].count max"}},
+		{name: "nested pointer", modify: func(s *sample) { s.Nested = &item{Name: "n"} }, want: []string{"nested.count min"}},
+		{name: "every violation", modify: func(s *sample) {
+			s.Name = ""
+			s.Mode = "b"
+			s.Format = "gif"
+		}, want: []string{"name required", "format enum", "image require_if"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid()
+			tt.modify(s)
+			got := violations(t, validate.Struct(s))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("violations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type shared struct {
+	First  *item `json:"first"`
+	Second *item `json:"second"`
+}
+
+type node struct {
+	Name string `json:"name" required:"true"`
+	Next *node  `json:"next"`
+}
+
+func TestStructSharedPointers(t *testing.T) {
+	// a pointer reached twice is checked on both paths
+	bad := &item{Count: 1}
+	want := []string{"first.name required", "second.name required"}
+	if got := violations(t, validate.Struct(&shared{First: bad, Second: bad})); !slices.Equal(got, want) {
+		t.Errorf("violations = %v, want %v", got, want)
+	}
+
+	// a cycle is walked once
+	cycle := &node{}
+	cycle.Next = cycle
+	want = []string{"name required", "next.name required"}
+	if got := violations(t, validate.Struct(cycle)); !slices.Equal(got, want) {
+		t.Errorf("violations = %v, want %v", got, want)
+	}
+}
+
+func TestStructNonStruct(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{name: "nil", value: nil},
+		{name: "nil pointer", value: (*sample)(nil)},
+		{name: "string", value: "not a struct"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validate.Struct(tt.value); err != nil {
+				t.Errorf("Struct(%v) = %v, want nil", tt.value, err)
+			}
+		})
+	}
+}
+
+func TestStructInvalidTags(t *testing.T) {
+	err := validate.Struct(&badTag{Count: 1, Kind: "x"})
+	want := []string{"count min", "kind enum", "image require_if"}
+	if got := violations(t, err); !slices.Equal(got, want) {
+		t.Fatalf("violations = %v, want %v", got, want)
+	}
+
+	var invalidTag *validate.ErrInvalidTag
+	if !errors.As(err, &invalidTag) {
+		t.Errorf("got %v, want ErrInvalidTag", err)
+	}
+}
+
+func TestValidationErrorsFields(t *testing.T) {
+	s := valid()
+	s.Name = ""
+	s.Items = []item{{Count: 0}}
+
+	var errs validate.ValidationErrors
+	if !errors.As(validate.Struct(s), &errs) {
+		t.Fatalf("want ValidationErrors")
+	}
+	if got := len(errs.Fields("items[0].name")); got != 1 {
+		t.Errorf("Fields(items[0].name) has %d violations, want 1", got)
+	}
+	if got := len(errs.Fields("missing")); got != 0 {
+		t.Errorf("Fields(missing) has %d violations, want 0", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	plain := errors.New("plain")
+
+	tests := []struct {
+		name  string
+		value *custom
+		want  []string
+		plain bool
+	}{
+		{name: "valid", value: &custom{Name: "ok"}},
+		{name: "tag violation", value: &custom{}, want: []string{"name required"}},
+		{name: "custom violation", value: &custom{Name: "ok", err: &validate.ValidationError{Field: "name", Rule: "custom"}},
+			want: []string{"name custom"}},
+		{name: "tag and custom violations", value: &custom{err: validate.ValidationErrors{{Field: "other", Rule: "custom"}}},
+			want: []string{"name required", "other custom"}},
+		{name: "plain error alone", value: &custom{Name: "ok", err: plain}, plain: true},
+		{name: "plain error with tag violation", value: &custom{err: plain}, want: []string{"name required", "is invalid"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate.Validate(tt.value)
+			if tt.plain {
+				if err != plain {
+					t.Fatalf("got %v, want the plain error unchanged", err)
+				}
+				return
+			}
+			if got := violations(t, err); !slices.Equal(got, tt.want) {
+				t.Errorf("violations = %v, want %v", got, tt.want)
+			}
+			if tt.value.err == plain && !errors.Is(err, plain) {
+				t.Errorf("got %v, want it to wrap the plain error", err)
+			}
+		})
+	}
+}
+
+type errName struct{ Err error }
+
+func (e *errName) Error() string { return "bad name: " + e.Err.Error() }
+func (e *errName) Unwrap() error { return e.Err }
+
+type errExtra struct{}
+
+func (e *errExtra) Error() string { return "checked by hand" }
+
+type errMode struct{ Err error }
+
+func (e *errMode) Error() string { return "bad mode: " + e.Err.Error() }
+func (e *errMode) Unwrap() error { return e.Err }
+
+func TestStructErrors(t *testing.T) {
+	fieldErrors := validate.ErrorMap{
+		"name": func(err error) error { return &errName{Err: err} },
+		"mode": func(err error) error { return &errMode{Err: err} },
+	}
+	extra := &errExtra{}
+
+	tests := []struct {
+		name   string
+		modify func(s *sample)
+		extra  []error
+		want   []string
+	}{
+		{name: "valid", modify: func(s *sample) {}},
+		{name: "mapped field", modify: func(s *sample) { s.Name = "" }, want: []string{"*validate_test.errName"}},
+		{name: "unmapped field", modify: func(s *sample) { s.Code = "" }, want: []string{"*validate.ValidationError"}},
+		{name: "extra only", modify: func(s *sample) {}, extra: []error{extra}, want: []string{"*validate_test.errExtra"}},
+		{name: "extra first", modify: func(s *sample) { s.Mode = "c"; s.Code = "" }, extra: []error{extra},
+			want: []string{"*validate_test.errExtra", "*validate_test.errMode", "*validate.ValidationError"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid()
+			tt.modify(s)
+			err := validate.StructErrors(s, fieldErrors, tt.extra...)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("got %v, want nil", err)
+				}
+				return
+			}
+
+			joined, ok := err.(interface{ Unwrap() []error })
+			if !ok {
+				t.Fatalf("got %T, want joined errors", err)
+			}
+			got := []string{}
+			for _, e := range joined.Unwrap() {
+				got = append(got, fmt.Sprintf("%T", e))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("errors = %v, want %v", got, tt.want)
+			}
+
+			// the mapped errors still expose the violation
+			var violation *validate.ValidationError
+			if len(s.Name) == 0 && !errors.As(err, &violation) {
+				t.Errorf("got %v, want it to wrap a ValidationError", err)
+			}
+		})
+	}
+}